@@ -3,6 +3,9 @@ package configs
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -30,4 +33,261 @@ func EnvRazorpayKeySecret() string {
 		log.Fatal("Error loading .env file")
 	}
 	return os.Getenv("RAZORPAY_KEY_SECRET")
-}
\ No newline at end of file
+}
+func EnvJwtSecret() string {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	return os.Getenv("JWT_SECRET")
+}
+
+// envOrDefault returns the value of key, or def when it is unset. Unlike the
+// required settings above, optional settings do not need a .env file.
+func envOrDefault(key, def string) string {
+	godotenv.Load()
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// envDuration reads an optional duration such as "720h" and falls back to
+// def when the value is unset or malformed.
+func envDuration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(envOrDefault(key, ""))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// EnvGuestCartTTL is how long an untouched guest cart is kept.
+func EnvGuestCartTTL() time.Duration {
+	return envDuration("GUEST_CART_TTL", 30*24*time.Hour)
+}
+
+// EnvGSTOriginState is the state goods ship from. Shipments within it are
+// charged CGST and SGST, shipments elsewhere IGST.
+func EnvGSTOriginState() string {
+	return envOrDefault("GST_ORIGIN_STATE", "")
+}
+
+// EnvGSTDefaultRate is the GST percentage used for products with no HSN or
+// category rate configured.
+func EnvGSTDefaultRate() float64 {
+	rate, err := strconv.ParseFloat(envOrDefault("GST_DEFAULT_RATE", "18"), 64)
+	if err != nil || rate < 0 {
+		return 18
+	}
+	return rate
+}
+
+// EnvShippingDefaultWeightGrams is the parcel weight assumed for products
+// without a weight.
+func EnvShippingDefaultWeightGrams() int {
+	weight, err := strconv.Atoi(envOrDefault("SHIPPING_DEFAULT_WEIGHT_GRAMS", "1000"))
+	if err != nil || weight <= 0 {
+		return 1000
+	}
+	return weight
+}
+
+// EnvShippingProcessingDays is how many days an order takes to leave the
+// warehouse before the zone's transit time starts.
+func EnvShippingProcessingDays() int {
+	days, err := strconv.Atoi(envOrDefault("SHIPPING_PROCESSING_DAYS", "1"))
+	if err != nil || days < 0 {
+		return 1
+	}
+	return days
+}
+
+// EnvBaseCurrency is the currency product prices, promotions, tax slabs and
+// shipping rates are entered in. Changing it does not convert stored prices.
+func EnvBaseCurrency() string {
+	return strings.ToUpper(envOrDefault("BASE_CURRENCY", "INR"))
+}
+
+// EnvIdempotencyKeyTTL is how long a stored response can be replayed for a
+// repeated Idempotency-Key.
+func EnvIdempotencyKeyTTL() time.Duration {
+	return envDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+}
+
+// EnvReconcileInterval is how often the order reconciliation job runs.
+func EnvReconcileInterval() time.Duration {
+	return envDuration("RECONCILE_INTERVAL", 5*time.Minute)
+}
+
+// EnvOrderStaleAfter is how long an order may wait for payment verification
+// before the reconciliation job asks the payment provider about it.
+func EnvOrderStaleAfter() time.Duration {
+	return envDuration("ORDER_STALE_AFTER", 15*time.Minute)
+}
+
+// EnvOrderExpireAfter is how long an unpaid order holds its stock and
+// coupons before it is expired.
+func EnvOrderExpireAfter() time.Duration {
+	return envDuration("ORDER_EXPIRE_AFTER", 2*time.Hour)
+}
+
+// EnvCODMaxOrderValue is the largest order total, in the base currency, that
+// can be paid cash on delivery. 0 removes the cap.
+func EnvCODMaxOrderValue() float64 {
+	value, err := strconv.ParseFloat(envOrDefault("COD_MAX_ORDER_VALUE", "50000"), 64)
+	if err != nil || value < 0 {
+		return 50000
+	}
+	return value
+}
+
+// EnvCODMaxOpenOrders is how many cash on delivery orders a user may have
+// awaiting collection at once. 0 removes the limit.
+func EnvCODMaxOpenOrders() int {
+	limit, err := strconv.Atoi(envOrDefault("COD_MAX_OPEN_ORDERS", "2"))
+	if err != nil || limit < 0 {
+		return 2
+	}
+	return limit
+}
+
+// EnvFakeCarrierSecret signs webhooks from the fake carrier. The fake carrier
+// is only accepted when it is set.
+func EnvFakeCarrierSecret() string {
+	return envOrDefault("FAKE_CARRIER_SECRET", "")
+}
+
+// EnvReturnWindow is how long after delivery items can be returned or
+// exchanged.
+func EnvReturnWindow() time.Duration {
+	return envDuration("RETURN_WINDOW", 7*24*time.Hour)
+}
+
+// EnvSellerName, EnvSellerGSTIN and EnvSellerAddress are the seller details
+// printed on tax invoices. The seller's state is EnvGSTOriginState.
+func EnvSellerName() string {
+	return envOrDefault("SELLER_NAME", "")
+}
+
+func EnvSellerGSTIN() string {
+	return envOrDefault("SELLER_GSTIN", "")
+}
+
+func EnvSellerAddress() string {
+	return envOrDefault("SELLER_ADDRESS", "")
+}
+
+// EnvInvoicePrefix starts every invoice number.
+func EnvInvoicePrefix() string {
+	return envOrDefault("INVOICE_PREFIX", "INV")
+}
+
+// EnvReportRollupInterval is how often the daily sales rollups are rebuilt.
+func EnvReportRollupInterval() time.Duration {
+	return envDuration("REPORT_ROLLUP_INTERVAL", time.Hour)
+}
+
+// EnvReportRollupDays is how many recent days each rollup run rebuilds, so
+// late payments, cancellations and returns reach the reports.
+func EnvReportRollupDays() int {
+	days, err := strconv.Atoi(envOrDefault("REPORT_ROLLUP_DAYS", "14"))
+	if err != nil || days < 1 {
+		return 14
+	}
+	return days
+}
+
+// EnvMediaStore picks where uploaded images are stored: "local" or "s3".
+func EnvMediaStore() string {
+	return envOrDefault("MEDIA_STORE", "local")
+}
+
+// EnvMediaDir and EnvMediaBaseURL are the directory the local store writes
+// to and the URL prefix the app serves it at.
+func EnvMediaDir() string {
+	return envOrDefault("MEDIA_DIR", "uploads")
+}
+
+func EnvMediaBaseURL() string {
+	return envOrDefault("MEDIA_BASE_URL", "/media")
+}
+
+// EnvS3Endpoint and the settings below address the bucket of the s3 store.
+// EnvS3PublicURL is where its files are read from, such as a CDN, and
+// defaults to the bucket on the endpoint.
+func EnvS3Endpoint() string {
+	return envOrDefault("S3_ENDPOINT", "")
+}
+
+func EnvS3Region() string {
+	return envOrDefault("S3_REGION", "us-east-1")
+}
+
+func EnvS3Bucket() string {
+	return envOrDefault("S3_BUCKET", "")
+}
+
+func EnvS3AccessKeyID() string {
+	return envOrDefault("S3_ACCESS_KEY_ID", "")
+}
+
+func EnvS3SecretAccessKey() string {
+	return envOrDefault("S3_SECRET_ACCESS_KEY", "")
+}
+
+func EnvS3PublicURL() string {
+	return envOrDefault("S3_PUBLIC_URL", "")
+}
+
+// EnvMaxImageBytes caps the size of an uploaded image file.
+func EnvMaxImageBytes() int {
+	limit, err := strconv.Atoi(envOrDefault("MAX_IMAGE_BYTES", "5242880"))
+	if err != nil || limit <= 0 {
+		return 5 << 20
+	}
+	return limit
+}
+
+// EnvMaxBodyBytes caps the size of any request body, such as a batch of
+// image uploads or a product import file.
+func EnvMaxBodyBytes() int {
+	limit, err := strconv.Atoi(envOrDefault("MAX_BODY_BYTES", "16777216"))
+	if err != nil || limit <= 0 {
+		return 16 << 20
+	}
+	return limit
+}
+
+// EnvHomeFeedTTL is how long an assembled home feed is reused.
+func EnvHomeFeedTTL() time.Duration {
+	return envDuration("HOME_FEED_TTL", time.Minute)
+}
+
+// EnvReviewReportThreshold is how many shopper reports send a shown review
+// back to moderation.
+func EnvReviewReportThreshold() int {
+	threshold, err := strconv.Atoi(envOrDefault("REVIEW_REPORT_THRESHOLD", "3"))
+	if err != nil || threshold < 1 {
+		return 3
+	}
+	return threshold
+}
+
+// EnvWishlistAlertInterval is how often wishlists are checked for price
+// drops and restocks.
+func EnvWishlistAlertInterval() time.Duration {
+	return envDuration("WISHLIST_ALERT_INTERVAL", 30*time.Minute)
+}
+
+// EnvRestockAlertInterval is how often back-in-stock subscriptions are
+// checked for restocks that were not notified when they happened.
+func EnvRestockAlertInterval() time.Duration {
+	return envDuration("RESTOCK_ALERT_INTERVAL", 15*time.Minute)
+}
+
+// EnvPreOrderAuthValidity is how long an authorized payment can wait for
+// capture. Pre-orders releasing later than this are charged upfront.
+func EnvPreOrderAuthValidity() time.Duration {
+	return envDuration("PREORDER_AUTH_VALIDITY", 120*time.Hour)
+}
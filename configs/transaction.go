@@ -0,0 +1,178 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactions are retried this many times when the server reports a
+// transient error such as a write conflict, waiting twice as long each time.
+const (
+	transactionAttempts = 5
+	transactionBackoff  = 50 * time.Millisecond
+)
+
+// Detection of transaction support is tried this many times at startup,
+// each attempt with its own timeout.
+const (
+	detectAttempts = 3
+	detectTimeout  = 5 * time.Second
+	detectBackoff  = time.Second
+)
+
+var (
+	transactionsMu        sync.Mutex
+	transactionsDetected  bool
+	transactionsSupported bool
+)
+
+// detectTransactions asks the server whether it accepts multi-document
+// transactions. Replica sets and sharded clusters do; the standalone servers
+// often used in development do not.
+func detectTransactions(ctx context.Context) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := DB.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// remember records the detected support. Only a successful lookup is kept.
+func remember(supported bool) {
+	transactionsMu.Lock()
+	defer transactionsMu.Unlock()
+	if !transactionsDetected && !supported {
+		log.Println("MongoDB is a standalone server, running without transactions")
+	}
+	transactionsDetected = true
+	transactionsSupported = supported
+}
+
+// DetectTransactions looks up whether the server supports transactions. It
+// is meant to run once at startup, retrying a few times, and returns an
+// error when the server could not tell, so the process does not start
+// without knowing whether checkout is atomic.
+func DetectTransactions() error {
+	backoff := detectBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), detectTimeout)
+		supported, err := detectTransactions(ctx)
+		cancel()
+		if err == nil {
+			remember(supported)
+			return nil
+		}
+		if attempt == detectAttempts {
+			return fmt.Errorf("detecting transaction support: %w", err)
+		}
+		log.Printf("Could not detect transaction support, retrying: %v", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// TransactionsSupported reports whether the server accepts multi-document
+// transactions, as found by DetectTransactions. If that has not succeeded
+// yet the server is asked again, and a failed lookup is returned rather
+// than remembered.
+func TransactionsSupported(ctx context.Context) (bool, error) {
+	transactionsMu.Lock()
+	detected, supported := transactionsDetected, transactionsSupported
+	transactionsMu.Unlock()
+	if detected {
+		return supported, nil
+	}
+
+	supported, err := detectTransactions(ctx)
+	if err != nil {
+		return false, err
+	}
+	remember(supported)
+	return supported, nil
+}
+
+func hasErrorLabel(err error, label string) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorLabel(label)
+}
+
+// wait sleeps for the backoff or until ctx is done.
+func wait(ctx context.Context, backoff time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(backoff):
+		return nil
+	}
+}
+
+// WithTransaction runs fn in a multi-document transaction, retrying with
+// backoff on transient errors. fn must use the context it is given for every
+// operation that should be part of the transaction. atomic is false when the
+// server does not support transactions; fn then runs once without one and
+// must undo its own partial writes on failure.
+func WithTransaction(ctx context.Context, fn func(ctx context.Context, atomic bool) error) error {
+	supported, err := TransactionsSupported(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fn(ctx, false)
+	}
+
+	session, err := DB.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	backoff := transactionBackoff
+	for attempt := 1; ; attempt++ {
+		err = mongo.WithSession(ctx, session, func(sessionCtx mongo.SessionContext) error {
+			if err := session.StartTransaction(); err != nil {
+				return err
+			}
+			if err := fn(sessionCtx, true); err != nil {
+				session.AbortTransaction(context.Background())
+				return err
+			}
+			return commit(sessionCtx, session)
+		})
+		if err == nil || !hasErrorLabel(err, "TransientTransactionError") || attempt == transactionAttempts {
+			return err
+		}
+
+		if err := wait(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// commit commits the transaction, retrying when the outcome of the commit is
+// unknown, for example after a network error.
+func commit(ctx context.Context, session mongo.Session) error {
+	backoff := transactionBackoff
+	for attempt := 1; ; attempt++ {
+		err := session.CommitTransaction(ctx)
+		if err == nil || !hasErrorLabel(err, "UnknownTransactionCommitResult") || attempt == transactionAttempts {
+			return err
+		}
+
+		if err := wait(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
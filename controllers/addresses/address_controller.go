@@ -5,6 +5,7 @@ import (
 	"fiber-mongo-api/configs"
 	"fiber-mongo-api/models"
 	"fiber-mongo-api/responses"
+	orderService "fiber-mongo-api/services/orders"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -235,6 +236,16 @@ func DeleteAddress(c *fiber.Ctx) error {
 		})
 	}
 
+	// Orders keep their own copy of the address, so it can go once any
+	// older order still pointing at it has one
+	if status, message := snapshotForOrders(ctx, objId, userObjId); status != fiber.StatusOK {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
 	// Find and delete address ensuring it belongs to the user
 	result, err := addressCollection.DeleteOne(ctx, bson.M{
 		"_id":    objId,
@@ -336,6 +347,15 @@ func EditAddress(c *fiber.Ctx) error {
 		"zipCode":       reqBody.ZipCode,
 	}
 
+	// Keep orders placed to the old address shipping there
+	if status, message := snapshotForOrders(ctx, addressObjId, userObjId); status != fiber.StatusOK {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
 	filter := bson.M{"_id": addressObjId, "userId": userObjId}
 	result, err := addressCollection.UpdateOne(ctx, filter, bson.M{"$set": update})
 	if err != nil {
@@ -474,3 +494,22 @@ func SelectAddress(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// snapshotForOrders copies the address onto orders that still only reference
+// it, before it is edited or deleted. It returns fiber.StatusOK when the
+// address can be changed.
+func snapshotForOrders(ctx context.Context, addressId, userId primitive.ObjectID) (int, string) {
+	var address models.Address
+	err := addressCollection.FindOne(ctx, bson.M{"_id": addressId, "userId": userId}).Decode(&address)
+	if err == mongo.ErrNoDocuments {
+		return fiber.StatusNotFound, "Address not found or you don't have permission to change it"
+	}
+	if err != nil {
+		return fiber.StatusInternalServerError, "Error fetching address"
+	}
+
+	if err := orderService.SnapshotAddress(ctx, address); err != nil {
+		return fiber.StatusInternalServerError, "Error saving address on orders"
+	}
+	return fiber.StatusOK, ""
+}
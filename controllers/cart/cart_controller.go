@@ -5,6 +5,9 @@ import (
 	"fiber-mongo-api/configs"
 	"fiber-mongo-api/models"
 	"fiber-mongo-api/responses"
+	cartService "fiber-mongo-api/services/cart"
+	promotionService "fiber-mongo-api/services/promotions"
+	"fiber-mongo-api/services/sizes"
 	"strconv"
 	"time"
 
@@ -47,13 +50,7 @@ func AddtoCart(c *fiber.Ctx) error {
 	}
 
 	//Validate region and size of the product
-	sizeMap := map[string]map[int]string{
-		"EU": {38: "S", 39: "M", 40: "L", 41: "XL", 42: "XXL", 43: "XXXL"},
-		"US": {5: "S", 6: "M", 7: "L", 8: "XL", 9: "XXL", 10: "XXXL"},
-		"UK": {4: "S", 5: "M", 6: "L", 7: "XL", 8: "XXL", 9: "XXXL"},
-	}
-
-	sizeCategory, valid := sizeMap[request.Region][request.Size]
+	sizeCategory, valid := sizes.FromRegion(request.Region, request.Size)
 	if !valid {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
 			Status:  fiber.StatusBadRequest,
@@ -78,54 +75,37 @@ func AddtoCart(c *fiber.Ctx) error {
 		})
 	}
 
-	userId, ok := c.Locals("userId").(string)
-	if !ok || userId == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User ID not found in token",
-		})
-	}
-
-	// Convert userId to ObjectID
-	userObjectID, err := primitive.ObjectIDFromHex(userId)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid User ID format",
-		})
-	}
-
-	var user models.User
-	if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "User not found",
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
 
 	// Check if the product is already in the cart
 	found := false
-	for i, cartItem := range user.Cart {
+	for i, cartItem := range owner.Cart {
 		if cartItem.Product.ID == productID && cartItem.Product.Size == sizeCategory {
 			// Product is found, increment quantity
-			user.Cart[i].Quantity += 1
+			owner.Cart[i].Quantity += 1
 			found = true
 			break
 		}
 	}
 	// If the product was not found, add it to the cart with a quantity of 1
 	if !found {
-		product.InCart = true
 		product.Size = sizeCategory
-		user.Cart = append(user.Cart, models.CartItem{
+		owner.Cart = append(owner.Cart, models.CartItem{
+			LineID:   primitive.NewObjectID(),
 			Product:  product,
 			Quantity: 1,
 		})
 	}
 
-	// Update the user in the database
-	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjectID}, bson.M{"$set": bson.M{"cart": user.Cart}})
+	// Update the cart in the database
+	err = owner.save(ctx)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -139,15 +119,14 @@ func AddtoCart(c *fiber.Ctx) error {
 		Message: "Successfully added to cart",
 		Result: &fiber.Map{
 			"status":    "success",
-			"cartCount": len(user.Cart),
+			"cartCount": len(owner.Cart),
 		},
 	})
 
 }
 
 type AddToCartFromCartRequest struct {
-	ProductID string `json:"id" validate:"required"`
-	Size      string `json:"size" validate:"required"`
+	CartLineRef
 }
 
 func AddToCartFromCart(c *fiber.Ctx) error {
@@ -163,60 +142,28 @@ func AddToCartFromCart(c *fiber.Ctx) error {
 		})
 	}
 
-	userId, ok := c.Locals("userId").(string)
-	if !ok || userId == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User ID not found in token",
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
 
-	userObjectID, err := primitive.ObjectIDFromHex(userId)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid User ID format",
-		})
-	}
-
-	productID, err := primitive.ObjectIDFromHex(request.ProductID)
+	index, err := findCartLine(owner.Cart, request.CartLineRef)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
-			Status:  fiber.StatusBadRequest,
-			Message: "Invalid product Id",
+		status, message := cartLineErrorStatus(err)
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
+	owner.Cart[index].Quantity += 1
 
-	var user models.User
-	if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "User not found",
-			Result:  nil,
-		})
-	}
-
-	found := false
-	for i, cartItem := range user.Cart {
-		if cartItem.Product.ID == productID && cartItem.Product.Size == request.Size {
-			user.Cart[i].Quantity += 1
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
-			Status:  fiber.StatusNotFound,
-			Message: "Product with specified size not found in cart",
-			Result:  nil,
-		})
-	}
-
-	// Update the user's cart in the database
-	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjectID}, bson.M{"$set": bson.M{"cart": user.Cart}})
+	// Update the cart in the database
+	err = owner.save(ctx)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -230,14 +177,15 @@ func AddToCartFromCart(c *fiber.Ctx) error {
 		Message: "Successfully added to cart",
 		Result: &fiber.Map{
 			"status":    "success",
-			"cartCount": len(user.Cart),
+			"cartCount": len(owner.Cart),
+			"cartItem":  owner.Cart[index],
 		},
 	})
 
 }
 
 type RemoveFromCartRequest struct {
-	ProductID string `json:"id" validate:"required"`
+	CartLineRef
 }
 
 func RemoveFromCart(c *fiber.Ctx) error {
@@ -253,88 +201,123 @@ func RemoveFromCart(c *fiber.Ctx) error {
 		})
 	}
 
-	productID, err := primitive.ObjectIDFromHex(request.ProductID)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
-			Status:  fiber.StatusBadRequest,
-			Message: "Invalid product Id",
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
 
-	userId, ok := c.Locals("userId").(string)
-	if !ok || userId == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User ID not found in token",
+	index, err := findCartLine(owner.Cart, request.CartLineRef)
+	if err != nil {
+		status, message := cartLineErrorStatus(err)
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
 		})
 	}
+	owner.Cart = append(owner.Cart[:index], owner.Cart[index+1:]...)
 
-	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	err = owner.save(ctx)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid User ID format",
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update cart",
+			Result:  nil,
 		})
 	}
 
-	var user models.User
-	if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "User not found",
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Successfully removed from cart",
+		Result: &fiber.Map{
+			"status":    "success",
+			"cartCount": len(owner.Cart),
+		},
+	})
+
+}
+
+//Decrement an item from cart
+
+type DecrementFromCartRequest struct {
+	CartLineRef
+}
+
+func DecrementFromCart(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request DecrementFromCartRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request",
 			Result:  nil,
 		})
 	}
 
-	for i, cartItem := range user.Cart {
-		if cartItem.Product.ID == productID {
-			user.Cart = append(user.Cart[:i], user.Cart[i+1:]...)
-			break
-		}
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
 	}
 
-	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjectID}, bson.M{"$set": bson.M{"cart": user.Cart}})
+	index, err := findCartLine(owner.Cart, request.CartLineRef)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to update cart",
+		status, message := cartLineErrorStatus(err)
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
+	if owner.Cart[index].Quantity > 1 {
+		owner.Cart[index].Quantity -= 1
+	} else {
+		owner.Cart = append(owner.Cart[:index], owner.Cart[index+1:]...)
+	}
 
-	// Set the InCart flag to false in the product collection
-	_, err = productCollection.UpdateOne(ctx, bson.M{"_id": productID}, bson.M{"$set": bson.M{"inCart": false}})
+	err = owner.save(ctx)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to update product status",
+			Message: "Failed to update cart",
 			Result:  nil,
 		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
-		Message: "Successfully removed from cart",
+		Message: "Successfully removed 1 item from cart",
 		Result: &fiber.Map{
 			"status":    "success",
-			"cartCount": len(user.Cart),
+			"cartCount": len(owner.Cart),
 		},
 	})
 
 }
 
-//Decrement an item from cart
+// Set the quantity of a single cart line
 
-type DecrementFromCartRequest struct {
-	ProductID string `json:"id" validate:"required"`
+type SetCartItemQuantityRequest struct {
+	CartLineRef
+	Quantity int `json:"quantity" validate:"min=0"`
 }
 
-func DecrementFromCart(c *fiber.Ctx) error {
+// SetCartItemQuantity sets a cart line to an explicit quantity. A quantity of
+// zero removes the line.
+func SetCartItemQuantity(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var request DecrementFromCartRequest
+	var request SetCartItemQuantityRequest
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
 			Status:  fiber.StatusBadRequest,
@@ -343,54 +326,40 @@ func DecrementFromCart(c *fiber.Ctx) error {
 		})
 	}
 
-	productID, err := primitive.ObjectIDFromHex(request.ProductID)
-	if err != nil {
+	if request.Quantity < 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
 			Status:  fiber.StatusBadRequest,
-			Message: "Invalid product id",
+			Message: "Quantity cannot be negative",
 			Result:  nil,
 		})
 	}
 
-	userId, ok := c.Locals("userId").(string)
-	if !ok || userId == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "UserId is not found in the token",
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
 
-	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	index, err := findCartLine(owner.Cart, request.CartLineRef)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid User ID format",
-			Result:  nil,
-		})
-	}
-
-	var user models.User
-	if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "User not found",
+		status, message := cartLineErrorStatus(err)
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
 
-	for i, cartItem := range user.Cart {
-		if cartItem.Product.ID == productID {
-			if cartItem.Quantity > 1 {
-				user.Cart[i].Quantity -= 1
-			} else {
-				user.Cart = append(user.Cart[:i], user.Cart[i+1:]...)
-			}
-			break
-		}
+	if request.Quantity == 0 {
+		owner.Cart = append(owner.Cart[:index], owner.Cart[index+1:]...)
+	} else {
+		owner.Cart[index].Quantity = request.Quantity
 	}
 
-	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjectID}, bson.M{"$set": bson.M{"cart": user.Cart}})
+	err = owner.save(ctx)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -399,60 +368,108 @@ func DecrementFromCart(c *fiber.Ctx) error {
 		})
 	}
 
-	// Optionally, update the product's inCart flag if it's completely removed
-	productStillInCart := false
-	for _, cartItem := range user.Cart {
-		if cartItem.Product.ID == productID {
-			productStillInCart = true
-			break
-		}
-	}
-
-	if !productStillInCart {
-		_, err = productCollection.UpdateOne(ctx, bson.M{"_id": productID}, bson.M{"$set": bson.M{"inCart": false}})
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
-				Status:  fiber.StatusInternalServerError,
-				Message: "Failed to update product status",
-				Result:  nil,
-			})
-		}
-	}
-
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
-		Message: "Successfully removed 1 item from cart",
+		Message: "Successfully updated cart quantity",
 		Result: &fiber.Map{
 			"status":    "success",
-			"cartCount": len(user.Cart),
+			"cartCount": len(owner.Cart),
+			"cartItems": owner.Cart,
 		},
 	})
+}
+
+// Remove several cart lines in one request
 
+type RemoveCartItemsRequest struct {
+	Items []CartLineRef `json:"items" validate:"required,min=1"`
 }
 
-func GetAllCarts(c *fiber.Ctx) error {
+// RemoveCartItems removes every referenced line. If any reference does not
+// resolve, nothing is removed and the unresolved references are returned.
+func RemoveCartItems(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Retrieve and validate the user ID from Locals
-	userId, ok := c.Locals("userId").(string)
-	if !ok || userId == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User ID not found in token",
+	var request RemoveCartItemsRequest
+	if err := c.BodyParser(&request); err != nil || len(request.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request",
+			Result:  nil,
+		})
+	}
+
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
 
-	// Convert userId to ObjectID
-	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	remove := make(map[int]bool)
+	var unresolved []fiber.Map
+	errStatus := 0
+	for _, ref := range request.Items {
+		index, err := findCartLine(owner.Cart, ref)
+		if err != nil {
+			status, message := cartLineErrorStatus(err)
+			if errStatus == 0 {
+				errStatus = status
+			}
+			unresolved = append(unresolved, fiber.Map{
+				"item":  ref,
+				"error": message,
+			})
+			continue
+		}
+		remove[index] = true
+	}
+
+	if len(unresolved) > 0 {
+		return c.Status(errStatus).JSON(responses.UserResponse{
+			Status:  errStatus,
+			Message: "Some cart items could not be removed",
+			Result: &fiber.Map{
+				"unresolved": unresolved,
+			},
+		})
+	}
+
+	remaining := make([]models.CartItem, 0, len(owner.Cart))
+	for i, cartItem := range owner.Cart {
+		if !remove[i] {
+			remaining = append(remaining, cartItem)
+		}
+	}
+	owner.Cart = remaining
+
+	err := owner.save(ctx)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid User ID format",
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update cart",
+			Result:  nil,
 		})
 	}
 
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Successfully removed items from cart",
+		Result: &fiber.Map{
+			"status":       "success",
+			"removedCount": len(remove),
+			"cartCount":    len(owner.Cart),
+		},
+	})
+}
+
+func GetAllCarts(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	pageStr := c.Query("page", "1")
 	limitStr := c.Query("limit", "10")
 
@@ -466,16 +483,34 @@ func GetAllCarts(c *fiber.Ctx) error {
 		limit = 10
 	}
 
-	var user models.User
-	if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user); err != nil {
+	// Loading through loadCart also backfills line ids on older carts
+	owner, userStatus, message := loadCart(ctx, c)
+	if userStatus != 0 {
+		return c.Status(userStatus).JSON(responses.UserResponse{
+			Status:  userStatus,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	rate, rateStatus, message := currencyRate(ctx, c)
+	if rateStatus != 0 {
+		return c.Status(rateStatus).JSON(responses.UserResponse{
+			Status:  rateStatus,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	if err := owner.revalidate(ctx, rate); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
-			Message: "User not found",
+			Message: "Failed to revalidate cart",
 			Result:  nil,
 		})
 	}
 
-	totalCartItems := int64(len(user.Cart))
+	totalCartItems := int64(len(owner.Cart))
 	totalPages := (totalCartItems + limit - 1) / limit
 
 	// Calculate start and end indexes for the slice
@@ -488,10 +523,11 @@ func GetAllCarts(c *fiber.Ctx) error {
 		end = totalCartItems
 	}
 
-	// Paginate the cart items
-	paginatedCartItems := user.Cart[start:end]
-	// Modify the product object to include cartItemCount
+	// Paginate the cart items, priced in the shopper's currency
+	paginatedCartItems := rate.PriceCart(owner.Cart[start:end])
+	// Modify the product object to include the cart state of this line
 	for i := range paginatedCartItems {
+		paginatedCartItems[i].Product.InCart = true
 		paginatedCartItems[i].Product.CartItemCount = paginatedCartItems[i].Quantity
 	}
 
@@ -504,11 +540,14 @@ func GetAllCarts(c *fiber.Ctx) error {
 		Status:  fiber.StatusOK,
 		Message: "Successfully fetched cart items",
 		Result: &fiber.Map{
-			"status":         status,
-			"currentPage":    page,
-			"totalPages":     totalPages,
-			"totalCartItems": totalCartItems,
-			"cartItems":      paginatedCartItems,
+			"status":          status,
+			"currentPage":     page,
+			"totalPages":      totalPages,
+			"totalCartItems":  totalCartItems,
+			"cartItems":       paginatedCartItems,
+			"currency":        rate.Currency,
+			"hasNotices":      cartService.HasNotices(owner.Cart),
+			"preOrderRelease": cartService.PreOrderRelease(owner.Cart),
 		},
 	})
 
@@ -519,54 +558,340 @@ func GetCartTotals(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Retrieve and validate the user ID from Locals
-	userId, ok := c.Locals("userId").(string)
-	if !ok || userId == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User ID not found in token",
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	rate, status, message := currencyRate(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	// Totals are always computed from current prices
+	if err := owner.revalidate(ctx, rate); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to revalidate cart",
+			Result:  nil,
+		})
+	}
+
+	// Tax and shipping depend on where the order ships
+	address, err := owner.shippingAddress(ctx, c.Query("addressId"))
+	if err != nil {
+		if err == errAddressNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Address not found",
+				Result:  nil,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching address",
+			Result:  nil,
+		})
+	}
+
+	// Calculate subtotal, discounts, tax, shipping, platform fee and grand total
+	totals, err := owner.totals(ctx, address, rate)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to calculate cart totals",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Successfully calculated cart totals",
+		Result: &fiber.Map{
+			"totalPrice":      totals.Subtotal,
+			"discounts":       totals.Discounts,
+			"discountTotal":   totals.DiscountTotal,
+			"couponCode":      totals.CouponCode,
+			"couponError":     totals.CouponError,
+			"tax":             totals.Tax,
+			"shipping":        totals.Shipping,
+			"shippingError":   totals.ShippingError,
+			"platformFee":     totals.PlatformFee,
+			"grandTotal":      totals.GrandTotal,
+			"currency":        totals.Currency,
+			"hasNotices":      cartService.HasNotices(owner.Cart),
+			"preOrderRelease": cartService.PreOrderRelease(owner.Cart),
+		},
+	})
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// ApplyCoupon validates a coupon against the cart and applies it, replacing
+// any coupon applied before. Only one coupon can be applied at a time.
+func ApplyCoupon(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request ApplyCouponRequest
+	if err := c.BodyParser(&request); err != nil || promotionService.NormalizeCode(request.Code) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Coupon code is required",
+			Result:  nil,
+		})
+	}
+
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
 			Result:  nil,
 		})
 	}
 
-	// Convert userId to ObjectID
-	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	rate, status, message := currencyRate(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	if len(owner.Cart) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Cart is empty",
+			Result:  nil,
+		})
+	}
+
+	discount, err := promotionService.ValidateCoupon(ctx, request.Code, rate.PriceCart(owner.Cart), owner.userID(), rate)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid User ID format",
+		status := fiber.StatusUnprocessableEntity
+		if err == promotionService.ErrCouponNotFound {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: err.Error(),
+			Result:  nil,
 		})
 	}
 
-	// Fetch the user
-	var user models.User
-	if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user); err != nil {
+	owner.CouponCode = discount.Code
+	if err := owner.saveCoupon(ctx); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
-			Message: "User not found",
+			Message: "Failed to apply coupon",
 			Result:  nil,
 		})
 	}
 
-	// Calculate the total price
-	var totalPrice float64
-	for _, cartItem := range user.Cart {
-		totalPrice += float64(cartItem.Product.Price) * float64(cartItem.Quantity)
+	address, err := owner.shippingAddress(ctx, "")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching address",
+			Result:  nil,
+		})
 	}
 
-	// Calculate platform fee (2% of the total item)
-	platformFee := float64(totalPrice) * 0.002
+	totals, err := owner.totals(ctx, address, rate)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to calculate cart totals",
+			Result:  nil,
+		})
+	}
 
-	// Calculate the grand total
-	grandTotal := totalPrice + platformFee
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Coupon applied",
+		Result: &fiber.Map{
+			"status": "success",
+			"totals": totals,
+		},
+	})
+}
+
+// RemoveCoupon removes the coupon applied to the cart, if any.
+func RemoveCoupon(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	rate, status, message := currencyRate(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	if owner.CouponCode == "" {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "No coupon applied to cart",
+			Result:  nil,
+		})
+	}
+
+	owner.CouponCode = ""
+	if err := owner.saveCoupon(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to remove coupon",
+			Result:  nil,
+		})
+	}
+
+	address, err := owner.shippingAddress(ctx, "")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching address",
+			Result:  nil,
+		})
+	}
+
+	totals, err := owner.totals(ctx, address, rate)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to calculate cart totals",
+			Result:  nil,
+		})
+	}
 
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
-		Message: "Successfully calculated cart totals",
+		Message: "Coupon removed",
+		Result: &fiber.Map{
+			"status": "success",
+			"totals": totals,
+		},
+	})
+}
+
+// CreateGuestCart starts an anonymous cart and returns the guest token that
+// identifies it. Clients send the token in the X-Guest-Token header on cart
+// requests and on sign-in, where the guest cart is merged into the user's.
+func CreateGuestCart(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	guestCart, err := cartService.CreateGuestCart(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to create guest cart",
+			Result:  nil,
+		})
+	}
+
+	guestToken, err := cartService.IssueGuestToken(guestCart.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error while generating guest token",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Guest cart created",
+		Result: &fiber.Map{
+			"guestToken": guestToken,
+			"expiresAt":  guestCart.ExpiresAt,
+		},
+	})
+}
+
+type AcknowledgeCartNoticesRequest struct {
+	LineIDs []string `json:"lineIds"`
+}
+
+// AcknowledgeCartNotices clears change notices on the given cart lines, or on
+// every line when no lineIds are sent. Checkout is refused until the notices
+// raised by revalidation have been acknowledged.
+func AcknowledgeCartNotices(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request AcknowledgeCartNoticesRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request",
+			Result:  nil,
+		})
+	}
+
+	owner, status, message := loadCart(ctx, c)
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	var lineIDs []primitive.ObjectID
+	for _, lineId := range request.LineIDs {
+		index, err := findCartLine(owner.Cart, CartLineRef{LineID: lineId})
+		if err != nil {
+			status, message := cartLineErrorStatus(err)
+			return c.Status(status).JSON(responses.UserResponse{
+				Status:  status,
+				Message: message,
+				Result:  nil,
+			})
+		}
+		lineIDs = append(lineIDs, owner.Cart[index].LineID)
+	}
+
+	cleared := cartService.AcknowledgeNotices(owner.Cart, lineIDs)
+	if cleared > 0 {
+		if err := owner.save(ctx); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Failed to update cart",
+				Result:  nil,
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Cart notices acknowledged",
 		Result: &fiber.Map{
-			"totalPrice":  totalPrice,
-			"platformFee": platformFee,
-			"grandTotal":  grandTotal,
+			"status":       "success",
+			"clearedCount": cleared,
+			"hasNotices":   cartService.HasNotices(owner.Cart),
 		},
 	})
 }
@@ -0,0 +1,267 @@
+package cartController
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/models"
+	cartService "fiber-mongo-api/services/cart"
+	currencyService "fiber-mongo-api/services/currency"
+
+	"fiber-mongo-api/configs"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var guestCartCollection *mongo.Collection = configs.GetCollection(configs.DB, "guestCarts")
+var addressCollection *mongo.Collection = configs.GetCollection(configs.DB, "addresses")
+
+// CartLineRef identifies a single cart line. Clients should send the lineId
+// returned by fetchCartItems; the product id and size pair is accepted as a
+// fallback for older clients.
+type CartLineRef struct {
+	LineID    string `json:"lineId"`
+	ProductID string `json:"id"`
+	Size      string `json:"size"`
+}
+
+var (
+	errInvalidCartLine   = errors.New("invalid cart line reference")
+	errCartLineNotFound  = errors.New("cart line not found")
+	errCartLineAmbiguous = errors.New("product has more than one size in cart, specify lineId or size")
+	errAddressNotFound   = errors.New("address not found")
+)
+
+// findCartLine returns the index of the cart line referenced by ref.
+// A reference with only a product id resolves when the product has a single
+// line in the cart and is rejected as ambiguous otherwise.
+func findCartLine(cart []models.CartItem, ref CartLineRef) (int, error) {
+	if ref.LineID != "" {
+		lineID, err := primitive.ObjectIDFromHex(ref.LineID)
+		if err != nil {
+			return -1, errInvalidCartLine
+		}
+		for i, cartItem := range cart {
+			if cartItem.LineID == lineID {
+				return i, nil
+			}
+		}
+		return -1, errCartLineNotFound
+	}
+
+	productID, err := primitive.ObjectIDFromHex(ref.ProductID)
+	if err != nil {
+		return -1, errInvalidCartLine
+	}
+
+	index := -1
+	for i, cartItem := range cart {
+		if cartItem.Product.ID != productID {
+			continue
+		}
+		if ref.Size != "" {
+			if cartItem.Product.Size == ref.Size {
+				return i, nil
+			}
+			continue
+		}
+		if index != -1 {
+			return -1, errCartLineAmbiguous
+		}
+		index = i
+	}
+
+	if index == -1 {
+		return -1, errCartLineNotFound
+	}
+	return index, nil
+}
+
+// cartLineErrorStatus maps a findCartLine error to an HTTP status and message.
+func cartLineErrorStatus(err error) (int, string) {
+	switch err {
+	case errCartLineNotFound:
+		return fiber.StatusNotFound, "Cart item not found"
+	case errCartLineAmbiguous:
+		return fiber.StatusConflict, "Product has more than one size in cart, specify lineId or size"
+	default:
+		return fiber.StatusBadRequest, "Invalid cart item reference"
+	}
+}
+
+// ensureCartLineIDs assigns a LineID to cart lines written before line ids
+// existed. It reports whether any line was changed so callers can persist it.
+func ensureCartLineIDs(cart []models.CartItem) bool {
+	changed := false
+	for i := range cart {
+		if cart[i].LineID.IsZero() {
+			cart[i].LineID = primitive.NewObjectID()
+			changed = true
+		}
+	}
+	return changed
+}
+
+// cartOwner is the cart a request acts on: either a signed-in user's cart,
+// stored on the user document, or an anonymous guest cart.
+type cartOwner struct {
+	ID         primitive.ObjectID
+	Guest      bool
+	Cart       []models.CartItem
+	CouponCode string
+}
+
+// userID returns the signed-in user's id, or nil for a guest cart.
+func (o *cartOwner) userID() *primitive.ObjectID {
+	if o.Guest {
+		return nil
+	}
+	return &o.ID
+}
+
+// saveCoupon stores the coupon applied to the cart; an empty code removes it.
+func (o *cartOwner) saveCoupon(ctx context.Context) error {
+	collection := userCollection
+	if o.Guest {
+		collection = guestCartCollection
+	}
+
+	update := bson.M{"$unset": bson.M{"couponCode": ""}}
+	if o.CouponCode != "" {
+		update = bson.M{"$set": bson.M{"couponCode": o.CouponCode}}
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": o.ID}, update)
+	return err
+}
+
+// shippingAddress returns the address the cart is priced for: the user's
+// address with the given id, or their selected address when addressId is
+// empty. Guests and users without a selected address get nil.
+func (o *cartOwner) shippingAddress(ctx context.Context, addressId string) (*models.Address, error) {
+	if o.Guest {
+		if addressId != "" {
+			return nil, errAddressNotFound
+		}
+		return nil, nil
+	}
+
+	filter := bson.M{"userId": o.ID, "isUserSelected": true}
+	if addressId != "" {
+		addressObjectID, err := primitive.ObjectIDFromHex(addressId)
+		if err != nil {
+			return nil, errAddressNotFound
+		}
+		filter = bson.M{"_id": addressObjectID, "userId": o.ID}
+	}
+
+	var address models.Address
+	if err := addressCollection.FindOne(ctx, filter).Decode(&address); err != nil {
+		if err == mongo.ErrNoDocuments {
+			if addressId != "" {
+				return nil, errAddressNotFound
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &address, nil
+}
+
+// totals prices the cart with its applied coupon for the given address, in
+// the currency of rate.
+func (o *cartOwner) totals(ctx context.Context, address *models.Address, rate currencyService.Rate) (cartService.Totals, error) {
+	return cartService.ComputeTotals(ctx, cartService.TotalsInput{
+		Cart:       o.Cart,
+		CouponCode: o.CouponCode,
+		UserID:     o.userID(),
+		Address:    address,
+		Currency:   rate.Currency,
+	})
+}
+
+// currencyRate resolves the currency the shopper asked for in the currency
+// query parameter, defaulting to the base currency. On failure it returns a
+// non-zero status and a message.
+func currencyRate(ctx context.Context, c *fiber.Ctx) (currencyService.Rate, int, string) {
+	rate, err := currencyService.LoadRate(ctx, c.Query("currency"))
+	if err == currencyService.ErrUnsupportedCurrency || err == currencyService.ErrNoExchangeRate {
+		return rate, fiber.StatusBadRequest, err.Error()
+	} else if err != nil {
+		return rate, fiber.StatusInternalServerError, "Error fetching exchange rate"
+	}
+	return rate, 0, ""
+}
+
+// save writes the owner's cart back to where it was loaded from.
+func (o *cartOwner) save(ctx context.Context) error {
+	if o.Guest {
+		return cartService.SaveGuestCart(ctx, o.ID, o.Cart)
+	}
+	_, err := userCollection.UpdateOne(ctx, bson.M{"_id": o.ID}, bson.M{"$set": bson.M{"cart": o.Cart}})
+	return err
+}
+
+// loadCart resolves the cart for the request from the userId or guestCartId
+// set by CartAuthMiddleware. On failure it returns a non-zero status and a
+// message.
+func loadCart(ctx context.Context, c *fiber.Ctx) (*cartOwner, int, string) {
+	owner := &cartOwner{}
+
+	if userId, ok := c.Locals("userId").(string); ok && userId != "" {
+		userObjectID, err := primitive.ObjectIDFromHex(userId)
+		if err != nil {
+			return nil, fiber.StatusUnauthorized, "Invalid User ID format"
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, fiber.StatusNotFound, "User not found"
+			}
+			return nil, fiber.StatusInternalServerError, "Error fetching user"
+		}
+		owner.ID = user.Id
+		owner.Cart = user.Cart
+		owner.CouponCode = user.CouponCode
+	} else if guestCartId, ok := c.Locals("guestCartId").(string); ok && guestCartId != "" {
+		guestCartID, err := primitive.ObjectIDFromHex(guestCartId)
+		if err != nil {
+			return nil, fiber.StatusUnauthorized, "Invalid guest token"
+		}
+
+		guestCart, err := cartService.FindGuestCart(ctx, guestCartID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, fiber.StatusNotFound, "Guest cart has expired, request a new guest token"
+			}
+			return nil, fiber.StatusInternalServerError, "Error fetching guest cart"
+		}
+		owner.ID = guestCart.ID
+		owner.Guest = true
+		owner.Cart = guestCart.Cart
+		owner.CouponCode = guestCart.CouponCode
+	} else {
+		return nil, fiber.StatusUnauthorized, "User ID not found in token"
+	}
+
+	// Persist backfilled line ids right away so ids handed to the client on a
+	// read stay valid for the next mutation.
+	if ensureCartLineIDs(owner.Cart) {
+		if err := owner.save(ctx); err != nil {
+			return nil, fiber.StatusInternalServerError, "Failed to update cart"
+		}
+	}
+	return owner, 0, ""
+}
+
+// revalidate refreshes the cart against the live catalogue and persists any
+// notices raised, with prices compared in the rate's currency.
+func (o *cartOwner) revalidate(ctx context.Context, rate currencyService.Rate) error {
+	changed, err := cartService.RevalidateCart(ctx, o.Cart, rate)
+	if err != nil || !changed {
+		return err
+	}
+	return o.save(ctx)
+}
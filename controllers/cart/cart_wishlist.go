@@ -0,0 +1,231 @@
+package cartController
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	"fiber-mongo-api/services/sizes"
+	wishlistService "fiber-mongo-api/services/wishlist"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MoveToWishlistRequest struct {
+	CartLineRef
+}
+
+type MoveToCartRequest struct {
+	ProductID string `json:"id" validate:"required"`
+	Size      string `json:"size"`     // Size of the wishlist item, empty for any size
+	CartSize  string `json:"cartSize"` // Size to buy, required when the item has no size
+}
+
+// wishlistErrorStatus maps a wishlist service error to an HTTP status and
+// message.
+func wishlistErrorStatus(err error) (int, string) {
+	switch err {
+	case wishlistService.ErrProductNotFound, wishlistService.ErrItemNotFound:
+		return fiber.StatusNotFound, err.Error()
+	case sizes.ErrInvalid:
+		return fiber.StatusBadRequest, err.Error()
+	case wishlistService.ErrWishlistFull:
+		return fiber.StatusConflict, err.Error()
+	}
+	return fiber.StatusInternalServerError, "Error updating wishlist"
+}
+
+// MoveToWishlist saves a cart line to the user's wishlist and takes it out
+// of the cart.
+func MoveToWishlist(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request MoveToWishlistRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request",
+			Result:  nil,
+		})
+	}
+
+	owner, status, message := loadCart(ctx, c)
+	if status == 0 && owner.Guest {
+		status, message = fiber.StatusUnauthorized, "Sign in to use the wishlist"
+	}
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	index, err := findCartLine(owner.Cart, request.CartLineRef)
+	if err != nil {
+		status, message := cartLineErrorStatus(err)
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+	line := owner.Cart[index]
+
+	// Save it first, so a failure leaves it in the cart rather than nowhere
+	if _, err := wishlistService.Add(ctx, owner.ID, line.Product.ID, line.Product.Size); err != nil {
+		status, message := wishlistErrorStatus(err)
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	owner.Cart = append(owner.Cart[:index], owner.Cart[index+1:]...)
+	if err := owner.save(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update cart",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Moved to wishlist",
+		Result: &fiber.Map{
+			"status":    "success",
+			"cartCount": len(owner.Cart),
+		},
+	})
+}
+
+// MoveToCart adds a wishlist item to the cart, one unit in its size or in
+// cartSize, and takes it off the wishlist.
+func MoveToCart(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request MoveToCartRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request",
+			Result:  nil,
+		})
+	}
+	productID, err := primitive.ObjectIDFromHex(request.ProductID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid product Id",
+			Result:  nil,
+		})
+	}
+	size, err := sizes.Normalize(request.Size)
+	if err == nil && request.CartSize != "" {
+		request.CartSize, err = sizes.Normalize(request.CartSize)
+	}
+	if err != nil {
+		status, message := wishlistErrorStatus(err)
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	owner, status, message := loadCart(ctx, c)
+	if status == 0 && owner.Guest {
+		status, message = fiber.StatusUnauthorized, "Sign in to use the wishlist"
+	}
+	if status != 0 {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	if _, err := wishlistService.Find(ctx, owner.ID, productID, size); err != nil {
+		status, message := wishlistErrorStatus(err)
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+	cartSize := size
+	if cartSize == "" {
+		cartSize = request.CartSize
+	}
+	if cartSize == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Choose a size to add to the cart",
+			Result:  nil,
+		})
+	}
+
+	var product models.Product
+	if err := productCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&product); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Product not found",
+				Result:  nil,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching product details",
+			Result:  nil,
+		})
+	}
+
+	found := false
+	for i, cartItem := range owner.Cart {
+		if cartItem.Product.ID == productID && cartItem.Product.Size == cartSize {
+			owner.Cart[i].Quantity += 1
+			found = true
+			break
+		}
+	}
+	if !found {
+		product.Size = cartSize
+		owner.Cart = append(owner.Cart, models.CartItem{
+			LineID:   primitive.NewObjectID(),
+			Product:  product,
+			Quantity: 1,
+		})
+	}
+	if err := owner.save(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update cart",
+			Result:  nil,
+		})
+	}
+
+	if err := wishlistService.Remove(ctx, owner.ID, productID, size); err != nil && err != wishlistService.ErrItemNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Added to cart but failed to update wishlist",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Moved to cart",
+		Result: &fiber.Map{
+			"status":    "success",
+			"cartCount": len(owner.Cart),
+		},
+	})
+}
@@ -0,0 +1,229 @@
+package categoryController
+
+import (
+	"context"
+	"fiber-mongo-api/responses"
+	categoryService "fiber-mongo-api/services/categories"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CategoryRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+	ParentID    string `json:"parentId"`
+	Position    int    `json:"position"`
+}
+
+type ProductCategoriesRequest struct {
+	CategoryIDs []string `json:"categoryIds"`
+}
+
+// categoryError responds with the status for an error from the category
+// service.
+func categoryError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := "Error saving category"
+	switch err {
+	case categoryService.ErrCategoryNotFound, categoryService.ErrProductNotFound:
+		status, message = fiber.StatusNotFound, err.Error()
+	case categoryService.ErrSlugTaken, categoryService.ErrHasChildren:
+		status, message = fiber.StatusConflict, err.Error()
+	case categoryService.ErrParentNotFound, categoryService.ErrNameRequired, categoryService.ErrInvalidSlug,
+		categoryService.ErrInvalidImage, categoryService.ErrCycle:
+		status, message = fiber.StatusBadRequest, err.Error()
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+// input reads the category from the request body. The status is
+// fiber.StatusOK when it could be read.
+func input(c *fiber.Ctx) (categoryService.Input, int, string) {
+	var request CategoryRequest
+	if err := c.BodyParser(&request); err != nil {
+		return categoryService.Input{}, fiber.StatusBadRequest, "Invalid request body"
+	}
+
+	in := categoryService.Input{
+		Name:        request.Name,
+		Slug:        request.Slug,
+		Description: request.Description,
+		Image:       request.Image,
+		Position:    request.Position,
+	}
+	if request.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(request.ParentID)
+		if err != nil {
+			return in, fiber.StatusBadRequest, "Invalid parent ID"
+		}
+		in.ParentID = &parentID
+	}
+	return in, fiber.StatusOK, ""
+}
+
+// The whole category tree, each level in display order.
+func GetCategoryTree(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tree, err := categoryService.Tree(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching categories",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched categories",
+		Result: &fiber.Map{
+			"categories": tree,
+		},
+	})
+}
+
+// Only for admin
+func CreateCategory(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	in, status, message := input(c)
+	if status != fiber.StatusOK {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	category, err := categoryService.Create(ctx, in)
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(responses.UserResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Category created",
+		Result: &fiber.Map{
+			"category": category,
+		},
+	})
+}
+
+// Only for admin. Replaces a category's details; a new parentId moves it
+// with its subcategories.
+func UpdateCategory(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid category ID",
+			Result:  nil,
+		})
+	}
+	in, status, message := input(c)
+	if status != fiber.StatusOK {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	category, err := categoryService.Update(ctx, id, in)
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Category updated",
+		Result: &fiber.Map{
+			"category": category,
+		},
+	})
+}
+
+// Only for admin. Deletes a category that has no subcategories.
+func DeleteCategory(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid category ID",
+			Result:  nil,
+		})
+	}
+
+	if err := categoryService.Delete(ctx, id); err != nil {
+		return categoryError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Category deleted",
+		Result:  nil,
+	})
+}
+
+// Only for admin. Places a product in exactly the given categories.
+func SetProductCategories(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid product ID",
+			Result:  nil,
+		})
+	}
+
+	var request ProductCategoriesRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Result:  nil,
+		})
+	}
+	ids := make([]primitive.ObjectID, 0, len(request.CategoryIDs))
+	for _, value := range request.CategoryIDs {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid category ID",
+				Result:  nil,
+			})
+		}
+		ids = append(ids, id)
+	}
+
+	if err := categoryService.SetProductCategories(ctx, productID, ids); err != nil {
+		return categoryError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Product categories updated",
+		Result:  nil,
+	})
+}
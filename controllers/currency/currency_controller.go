@@ -0,0 +1,372 @@
+package currencyController
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	currencyService "fiber-mongo-api/services/currency"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var exchangeRateCollection *mongo.Collection = configs.GetCollection(configs.DB, "exchangeRates")
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+
+type ExchangeRateRequest struct {
+	Currency string  `json:"currency" validate:"required"`
+	Rate     float64 `json:"rate" validate:"required,gt=0"`
+}
+
+// PriceListEntry sets a product's price in the price list's currency, in
+// minor units. An amount of 0 removes the entry so the converted base price
+// applies again.
+type PriceListEntry struct {
+	ProductID string `json:"productId" validate:"required"`
+	Amount    int64  `json:"amount" validate:"min=0"`
+}
+
+type PriceListRequest struct {
+	Currency string           `json:"currency" validate:"required"`
+	Prices   []PriceListEntry `json:"prices" validate:"required,min=1,dive"`
+}
+
+// GetCurrencies lists the currencies shoppers can choose: the base currency
+// and every supported currency with an exchange rate.
+func GetCurrencies(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var exchangeRates []models.ExchangeRate
+	cursor, err := exchangeRateCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching exchange rates",
+			Result:  nil,
+		})
+	}
+	if err = cursor.All(ctx, &exchangeRates); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error parsing exchange rates",
+			Result:  nil,
+		})
+	}
+
+	base := configs.EnvBaseCurrency()
+	currencies := []currencyService.Currency{}
+	if currency, ok := currencyService.Lookup(base); ok {
+		currencies = append(currencies, currency)
+	}
+	for _, exchangeRate := range exchangeRates {
+		if currency, ok := currencyService.Lookup(exchangeRate.Currency); ok && currency.Code != base {
+			currencies = append(currencies, currency)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched currencies",
+		Result: &fiber.Map{
+			"baseCurrency": base,
+			"currencies":   currencies,
+		},
+	})
+}
+
+// Only for admin. Creates or replaces the rate for a currency.
+func UpsertExchangeRate(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request ExchangeRateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing exchange rate data",
+			Result:  nil,
+		})
+	}
+
+	request.Currency = currencyService.NormalizeCode(request.Currency)
+	if _, ok := currencyService.Lookup(request.Currency); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: currencyService.ErrUnsupportedCurrency.Error(),
+			Result:  nil,
+		})
+	}
+
+	if request.Currency == configs.EnvBaseCurrency() {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "The base currency does not need an exchange rate",
+			Result:  nil,
+		})
+	}
+
+	if request.Rate <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Rate must be greater than 0",
+			Result:  nil,
+		})
+	}
+
+	var exchangeRate models.ExchangeRate
+	err := exchangeRateCollection.FindOneAndUpdate(ctx, bson.M{"currency": request.Currency},
+		bson.M{
+			"$set":         bson.M{"rate": request.Rate, "updatedAt": time.Now()},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&exchangeRate)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error saving exchange rate",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Exchange rate saved successfully",
+		Result: &fiber.Map{
+			"exchangeRate": exchangeRate,
+		},
+	})
+}
+
+// Only for admin
+func GetExchangeRates(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var exchangeRates []models.ExchangeRate
+	cursor, err := exchangeRateCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "currency", Value: 1}}))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching exchange rates",
+			Result:  nil,
+		})
+	}
+	if err = cursor.All(ctx, &exchangeRates); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error parsing exchange rates",
+			Result:  nil,
+		})
+	}
+
+	supported := currencyService.Supported()
+	sort.Slice(supported, func(i, j int) bool { return supported[i].Code < supported[j].Code })
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched exchange rates",
+		Result: &fiber.Map{
+			"baseCurrency":  configs.EnvBaseCurrency(),
+			"supported":     supported,
+			"exchangeRates": exchangeRates,
+		},
+	})
+}
+
+// Only for admin. Shoppers can no longer pay in the currency afterwards.
+func DeleteExchangeRate(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	currency := currencyService.NormalizeCode(c.Query("currency"))
+	result, err := exchangeRateCollection.DeleteOne(ctx, bson.M{"currency": currency})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error deleting exchange rate",
+			Result:  nil,
+		})
+	}
+
+	if result.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Exchange rate not found",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Exchange rate deleted successfully",
+		Result:  nil,
+	})
+}
+
+// Only for admin. Sets price list entries for one currency in bulk.
+func UpdatePriceList(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var request PriceListRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing price list data",
+			Result:  nil,
+		})
+	}
+
+	request.Currency = currencyService.NormalizeCode(request.Currency)
+	if _, ok := currencyService.Lookup(request.Currency); !ok || request.Currency == configs.EnvBaseCurrency() {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Price lists can only be set for supported currencies other than the base currency",
+			Result:  nil,
+		})
+	}
+
+	if len(request.Prices) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "No prices provided",
+			Result:  nil,
+		})
+	}
+
+	field := "prices." + request.Currency
+	writes := make([]mongo.WriteModel, 0, len(request.Prices))
+	for _, entry := range request.Prices {
+		productObjId, err := primitive.ObjectIDFromHex(entry.ProductID)
+		if err != nil || entry.Amount < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid price list entry for product " + entry.ProductID,
+				Result:  nil,
+			})
+		}
+
+		update := bson.M{"$set": bson.M{field: entry.Amount}}
+		if entry.Amount == 0 {
+			update = bson.M{"$unset": bson.M{field: ""}}
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": productObjId}).SetUpdate(update))
+	}
+
+	result, err := productCollection.BulkWrite(ctx, writes)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error saving price list",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Price list saved successfully",
+		Result: &fiber.Map{
+			"matched":  result.MatchedCount,
+			"modified": result.ModifiedCount,
+		},
+	})
+}
+
+// Only for admin. Lists the products with a price list entry in a currency.
+func GetPriceList(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	currency := currencyService.NormalizeCode(c.Query("currency"))
+	if _, ok := currencyService.Lookup(currency); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: currencyService.ErrUnsupportedCurrency.Error(),
+			Result:  nil,
+		})
+	}
+
+	pageStr := c.Query("page", "1")
+	limitStr := c.Query("limit", "50")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+
+	skip := (page - 1) * limit
+
+	field := "prices." + currency
+	filter := bson.M{field: bson.M{"$exists": true}}
+
+	totalProducts, err := productCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error counting products",
+			Result:  nil,
+		})
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(skip)
+	findOptions.SetLimit(limit)
+	findOptions.SetSort(bson.D{{Key: "name", Value: 1}})
+	findOptions.SetProjection(bson.M{"name": 1, "brand": 1, "price": 1, field: 1})
+
+	var products []models.Product
+	cursor, err := productCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching price list",
+			Result:  nil,
+		})
+	}
+	if err = cursor.All(ctx, &products); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error parsing price list",
+			Result:  nil,
+		})
+	}
+
+	prices := make([]fiber.Map, 0, len(products))
+	for _, product := range products {
+		prices = append(prices, fiber.Map{
+			"productId": product.ID.Hex(),
+			"name":      product.Name,
+			"brand":     product.Brand,
+			"basePrice": product.Price,
+			"amount":    product.Prices[currency],
+		})
+	}
+
+	totalPages := (totalProducts + limit - 1) / limit
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched price list",
+		Result: &fiber.Map{
+			"currency":      currency,
+			"currentPage":   page,
+			"totalPages":    totalPages,
+			"totalProducts": totalProducts,
+			"prices":        prices,
+		},
+	})
+}
@@ -0,0 +1,78 @@
+package invoiceController
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	invoiceService "fiber-mongo-api/services/invoices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var invoiceCollection *mongo.Collection = configs.GetCollection(configs.DB, "invoices")
+
+// Only for admin. Lists the invoices of a financial year, such as 2025-26,
+// in number order. It defaults to the current financial year.
+func GetInvoices(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	page, err := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.Query("limit", "50"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+	skip := (page - 1) * limit
+
+	filter := bson.M{"financialYear": c.Query("financialYear", invoiceService.FinancialYear(time.Now()))}
+
+	totalInvoices, err := invoiceCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error counting invoices",
+			Result:  nil,
+		})
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(skip)
+	findOptions.SetLimit(limit)
+	findOptions.SetSort(bson.D{{Key: "sequence", Value: 1}})
+
+	invoices := []models.Invoice{}
+	cursor, err := invoiceCollection.Find(ctx, filter, findOptions)
+	if err == nil {
+		err = cursor.All(ctx, &invoices)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching invoices",
+			Result:  nil,
+		})
+	}
+
+	totalPages := (totalInvoices + limit - 1) / limit
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched invoices",
+		Result: &fiber.Map{
+			"financialYear": filter["financialYear"],
+			"currentPage":   page,
+			"totalPages":    totalPages,
+			"totalInvoices": totalInvoices,
+			"invoices":      invoices,
+		},
+	})
+}
@@ -0,0 +1,90 @@
+package jobController
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var jobRunCollection *mongo.Collection = configs.GetCollection(configs.DB, "jobRuns")
+
+// Only for admin. Lists background job runs, newest first.
+func GetJobRuns(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageStr := c.Query("page", "1")
+	limitStr := c.Query("limit", "20")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	skip := (page - 1) * limit
+
+	filter := bson.M{}
+	if job := c.Query("job"); job != "" {
+		filter["job"] = job
+	}
+	if failed := c.Query("failed"); failed != "" {
+		filter["succeeded"] = failed != "true"
+	}
+
+	totalRuns, err := jobRunCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error counting job runs",
+			Result:  nil,
+		})
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(skip)
+	findOptions.SetLimit(limit)
+	findOptions.SetSort(bson.D{{Key: "startedAt", Value: -1}})
+
+	var runs []models.JobRun
+	cursor, err := jobRunCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching job runs",
+			Result:  nil,
+		})
+	}
+	if err = cursor.All(ctx, &runs); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error parsing job runs",
+			Result:  nil,
+		})
+	}
+
+	totalPages := (totalRuns + limit - 1) / limit
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched job runs",
+		Result: &fiber.Map{
+			"currentPage": page,
+			"totalPages":  totalPages,
+			"totalRuns":   totalRuns,
+			"runs":        runs,
+		},
+	})
+}
@@ -0,0 +1,272 @@
+package mediaController
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	mediaService "fiber-mongo-api/services/media"
+	reviewService "fiber-mongo-api/services/reviews"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+var userCollection *mongo.Collection = configs.GetCollection(configs.DB, "users")
+
+// maxImagesPerUpload caps the product images sent in one request.
+const maxImagesPerUpload = 10
+
+// readFile reads an uploaded file, refusing files over the image size limit.
+func readFile(header *multipart.FileHeader) ([]byte, error) {
+	limit := configs.EnvMaxImageBytes()
+	if header.Size > int64(limit) {
+		return nil, mediaService.ErrTooLarge
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, mediaService.ErrTooLarge
+	}
+	return data, nil
+}
+
+// uploadError responds with the status for an error from reading or
+// uploading an image.
+func uploadError(c *fiber.Ctx, err error, filename string) error {
+	status := fiber.StatusInternalServerError
+	message := "Error storing image"
+	switch err {
+	case mediaService.ErrTooLarge:
+		status, message = fiber.StatusRequestEntityTooLarge, err.Error()
+	case mediaService.ErrUnsupportedType:
+		status, message = fiber.StatusUnsupportedMediaType, err.Error()
+	case mediaService.ErrNoFile, mediaService.ErrInvalidImage, mediaService.ErrInvalidDimensions:
+		status, message = fiber.StatusBadRequest, err.Error()
+	}
+	if filename != "" {
+		message = filename + ": " + message
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+// Only for admin. Uploads product images sent as multipart "images" files.
+// With a product id they are added to the product; without one the URLs
+// are returned for a product about to be added.
+func UploadProductImages(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	owner := "unassigned"
+	var productID primitive.ObjectID
+	if id := c.Query("id"); id != "" {
+		var err error
+		productID, err = primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid product ID",
+				Result:  nil,
+			})
+		}
+		count, err := productCollection.CountDocuments(ctx, bson.M{"_id": productID})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Error fetching product",
+				Result:  nil,
+			})
+		}
+		if count == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Product not found",
+				Result:  nil,
+			})
+		}
+		owner = productID.Hex()
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["images"]) == 0 {
+		return uploadError(c, mediaService.ErrNoFile, "")
+	}
+	files := form.File["images"]
+	if len(files) > maxImagesPerUpload {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Too many images in one upload",
+			Result:  nil,
+		})
+	}
+
+	images := []models.Image{}
+	urls := []string{}
+	for _, header := range files {
+		data, err := readFile(header)
+		if err == nil {
+			var image models.Image
+			image, err = mediaService.Upload(ctx, mediaService.ProductImage, owner, data)
+			images = append(images, image)
+			urls = append(urls, image.URL)
+		}
+		if err != nil {
+			return uploadError(c, err, header.Filename)
+		}
+	}
+
+	if !productID.IsZero() {
+		_, err := productCollection.UpdateOne(ctx, bson.M{"_id": productID}, bson.M{
+			"$push": bson.M{
+				"images":        bson.M{"$each": urls},
+				"imageVariants": bson.M{"$each": images},
+			},
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Error adding images to product",
+				Result:  nil,
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Images uploaded",
+		Result: &fiber.Map{
+			"images": images,
+		},
+	})
+}
+
+// Uploads the user's profile picture, sent as a multipart "image" file, and
+// makes it their profile image.
+func UploadProfileImage(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	userId, ok := c.Locals("userId").(string)
+	if !ok || userId == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+			Result:  nil,
+		})
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "Invalid UserID format",
+			Result:  nil,
+		})
+	}
+
+	header, err := c.FormFile("image")
+	if err != nil {
+		return uploadError(c, mediaService.ErrNoFile, "")
+	}
+	data, err := readFile(header)
+	if err != nil {
+		return uploadError(c, err, "")
+	}
+	image, err := mediaService.Upload(ctx, mediaService.ProfileImage, userId, data)
+	if err != nil {
+		return uploadError(c, err, "")
+	}
+
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": userObjectID}, bson.M{
+		"$set": bson.M{"profileImage": image.URL},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error updating profile image",
+			Result:  nil,
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "User not found",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Profile image updated",
+		Result: &fiber.Map{
+			"profileImage": image.URL,
+			"image":        image,
+		},
+	})
+}
+
+// Uploads photos for a review, sent as multipart "photos" files. The URLs
+// returned go in the review's photos.
+func UploadReviewPhotos(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	userId, ok := c.Locals("userId").(string)
+	if !ok || userId == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+			Result:  nil,
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["photos"]) == 0 {
+		return uploadError(c, mediaService.ErrNoFile, "")
+	}
+	files := form.File["photos"]
+	if len(files) > reviewService.MaxPhotos {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: reviewService.ErrTooManyPhotos.Error(),
+			Result:  nil,
+		})
+	}
+
+	images := []models.Image{}
+	for _, header := range files {
+		data, err := readFile(header)
+		if err == nil {
+			var image models.Image
+			image, err = mediaService.Upload(ctx, mediaService.ReviewImage, userId, data)
+			images = append(images, image)
+		}
+		if err != nil {
+			return uploadError(c, err, header.Filename)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Photos uploaded",
+		Result: &fiber.Map{
+			"images": images,
+		},
+	})
+}
@@ -0,0 +1,401 @@
+package merchandisingController
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	merchandisingService "fiber-mongo-api/services/merchandising"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ist is Indian Standard Time, which preview dates are read in.
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+type CollectionRequest struct {
+	Name       string                 `json:"name" validate:"required"`
+	Slug       string                 `json:"slug"`
+	Type       string                 `json:"type" validate:"required"`
+	ProductIDs []primitive.ObjectID   `json:"productIds"`
+	Rule       *models.CollectionRule `json:"rule"`
+}
+
+type SectionRequest struct {
+	Title        string              `json:"title" validate:"required"`
+	Type         string              `json:"type" validate:"required"`
+	CollectionID *primitive.ObjectID `json:"collectionId"`
+	Limit        int                 `json:"limit"`
+	Banners      []models.Banner     `json:"banners"`
+	Position     int                 `json:"position"`
+	StartsAt     *time.Time          `json:"startsAt"`
+	EndsAt       *time.Time          `json:"endsAt"`
+}
+
+// merchandisingError responds with the status for an error from the
+// merchandising service.
+func merchandisingError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	switch err {
+	case merchandisingService.ErrCollectionNotFound, merchandisingService.ErrSectionNotFound:
+		status = fiber.StatusNotFound
+	case merchandisingService.ErrSlugTaken, merchandisingService.ErrCollectionInUse:
+		status = fiber.StatusConflict
+	case merchandisingService.ErrNameRequired, merchandisingService.ErrInvalidSlug, merchandisingService.ErrInvalidType,
+		merchandisingService.ErrNoProducts, merchandisingService.ErrTooManyProducts, merchandisingService.ErrProductNotFound,
+		merchandisingService.ErrRuleRequired, merchandisingService.ErrInvalidSort, merchandisingService.ErrInvalidPriceRange,
+		merchandisingService.ErrCategoryNotFound, merchandisingService.ErrTitleRequired, merchandisingService.ErrInvalidSectionType,
+		merchandisingService.ErrCollectionRequired, merchandisingService.ErrBannersRequired, merchandisingService.ErrTooManyBanners,
+		merchandisingService.ErrInvalidBanner, merchandisingService.ErrInvalidWindow, merchandisingService.ErrInvalidSectionLimit:
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error saving merchandising",
+			Result:  nil,
+		})
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: err.Error(),
+		Result:  nil,
+	})
+}
+
+func invalidID(c *fiber.Ctx, what string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+		Status:  fiber.StatusBadRequest,
+		Message: "Invalid " + what + " ID",
+		Result:  nil,
+	})
+}
+
+func invalidBody(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+		Status:  fiber.StatusBadRequest,
+		Message: "Invalid request body",
+		Result:  nil,
+	})
+}
+
+func collectionInput(request CollectionRequest) merchandisingService.CollectionInput {
+	return merchandisingService.CollectionInput{
+		Name:       request.Name,
+		Slug:       request.Slug,
+		Type:       request.Type,
+		ProductIDs: request.ProductIDs,
+		Rule:       request.Rule,
+	}
+}
+
+func sectionInput(request SectionRequest) merchandisingService.SectionInput {
+	return merchandisingService.SectionInput{
+		Title:        request.Title,
+		Type:         request.Type,
+		CollectionID: request.CollectionID,
+		Limit:        request.Limit,
+		Banners:      request.Banners,
+		Position:     request.Position,
+		StartsAt:     request.StartsAt,
+		EndsAt:       request.EndsAt,
+	}
+}
+
+// Only for admin
+func GetCollections(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collections, err := merchandisingService.ListCollections(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching collections",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched collections",
+		Result: &fiber.Map{
+			"collections": collections,
+		},
+	})
+}
+
+// Only for admin
+func CreateCollection(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request CollectionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBody(c)
+	}
+
+	collection, err := merchandisingService.CreateCollection(ctx, collectionInput(request))
+	if err != nil {
+		return merchandisingError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(responses.UserResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Collection created",
+		Result: &fiber.Map{
+			"collection": collection,
+		},
+	})
+}
+
+// Only for admin
+func UpdateCollection(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "collection")
+	}
+	var request CollectionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBody(c)
+	}
+
+	collection, err := merchandisingService.UpdateCollection(ctx, id, collectionInput(request))
+	if err != nil {
+		return merchandisingError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Collection updated",
+		Result: &fiber.Map{
+			"collection": collection,
+		},
+	})
+}
+
+// Only for admin. Deletes a collection no home section shows.
+func DeleteCollection(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "collection")
+	}
+
+	if err := merchandisingService.DeleteCollection(ctx, id); err != nil {
+		return merchandisingError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Collection deleted",
+		Result:  nil,
+	})
+}
+
+// Products of a collection (slug), up to limit.
+func GetCollectionProducts(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	limit, err := strconv.ParseInt(c.Query("limit", "20"), 10, 64)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	collection, err := merchandisingService.CollectionBySlug(ctx, c.Query("slug"))
+	if err == merchandisingService.ErrCollectionNotFound {
+		return merchandisingError(c, err)
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching collection",
+			Result:  nil,
+		})
+	}
+
+	products, err := merchandisingService.Products(ctx, collection, nil, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching products",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched collection",
+		Result: &fiber.Map{
+			"collection": collection,
+			"products":   products,
+		},
+	})
+}
+
+// Only for admin. Every home section, scheduled or not, in feed order.
+func GetSections(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sections, err := merchandisingService.ListSections(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching home sections",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched home sections",
+		Result: &fiber.Map{
+			"sections": sections,
+		},
+	})
+}
+
+// Only for admin
+func CreateSection(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request SectionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBody(c)
+	}
+
+	section, err := merchandisingService.CreateSection(ctx, sectionInput(request))
+	if err != nil {
+		return merchandisingError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(responses.UserResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Home section created",
+		Result: &fiber.Map{
+			"section": section,
+		},
+	})
+}
+
+// Only for admin
+func UpdateSection(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "section")
+	}
+	var request SectionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBody(c)
+	}
+
+	section, err := merchandisingService.UpdateSection(ctx, id, sectionInput(request))
+	if err != nil {
+		return merchandisingError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Home section updated",
+		Result: &fiber.Map{
+			"section": section,
+		},
+	})
+}
+
+// Only for admin
+func DeleteSection(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "section")
+	}
+
+	if err := merchandisingService.DeleteSection(ctx, id); err != nil {
+		return merchandisingError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Home section deleted",
+		Result:  nil,
+	})
+}
+
+// The home feed: the sections live now, in order. It is the same for every
+// shopper, so clients and proxies may cache it until it changes.
+func GetHomeFeed(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	feed, expires, err := merchandisingService.HomeFeed(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error building home feed",
+			Result:  nil,
+		})
+	}
+
+	maxAge := int(time.Until(expires).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(maxAge))
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched home feed",
+		Result: &fiber.Map{
+			"feed": feed,
+		},
+	})
+}
+
+// Only for admin. The home feed as it will look at a time (at, RFC 3339 or a
+// YYYY-MM-DD date in IST), to check upcoming campaigns.
+func PreviewHomeFeed(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	at, err := time.Parse(time.RFC3339, c.Query("at"))
+	if err != nil {
+		at, err = time.ParseInLocation("2006-01-02", c.Query("at"), ist)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "at must be an RFC 3339 time or a YYYY-MM-DD date",
+			Result:  nil,
+		})
+	}
+
+	feed, err := merchandisingService.BuildFeed(ctx, at)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error building home feed",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Home feed preview",
+		Result: &fiber.Map{
+			"feed": feed,
+		},
+	})
+}
@@ -0,0 +1,127 @@
+package notificationController
+
+import (
+	"context"
+	"fiber-mongo-api/responses"
+	notificationService "fiber-mongo-api/services/notifications"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func userObjectID(c *fiber.Ctx) (primitive.ObjectID, bool) {
+	userId, _ := c.Locals("userId").(string)
+	id, err := primitive.ObjectIDFromHex(userId)
+	return id, err == nil
+}
+
+// GetNotifications lists the user's notifications, newest first, or only
+// the unread ones with unread=true.
+func GetNotifications(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+			Result:  nil,
+		})
+	}
+
+	page, err := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.Query("limit", "20"), 10, 64)
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	notifications, total, unread, err := notificationService.List(ctx, userID, c.Query("unread") == "true", page, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching notifications",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched notifications",
+		Result: &fiber.Map{
+			"currentPage":        page,
+			"totalPages":         (total + limit - 1) / limit,
+			"totalNotifications": total,
+			"unreadCount":        unread,
+			"notifications":      notifications,
+		},
+	})
+}
+
+// MarkNotificationsRead marks the notification with the given id read, or
+// all of the user's notifications without one.
+func MarkNotificationsRead(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+			Result:  nil,
+		})
+	}
+
+	if id := c.Query("id"); id != "" {
+		notificationID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid notification ID format",
+				Result:  nil,
+			})
+		}
+		err = notificationService.MarkRead(ctx, userID, notificationID)
+		if err == notificationService.ErrNotificationNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+				Status:  fiber.StatusNotFound,
+				Message: err.Error(),
+				Result:  nil,
+			})
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Error updating notification",
+				Result:  nil,
+			})
+		}
+		return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+			Status:  fiber.StatusOK,
+			Message: "Notification marked read",
+			Result:  nil,
+		})
+	}
+
+	marked, err := notificationService.MarkAllRead(ctx, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error updating notifications",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Notifications marked read",
+		Result: &fiber.Map{
+			"marked": marked,
+		},
+	})
+}
@@ -0,0 +1,534 @@
+package controllers
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	currencyService "fiber-mongo-api/services/currency"
+	orderService "fiber-mongo-api/services/orders"
+	returnService "fiber-mongo-api/services/returns"
+	shipmentService "fiber-mongo-api/services/shipments"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var paymentProvider = orderService.NewRazorpayProvider()
+
+// orderStatuses are the statuses shown as dashboard tiles.
+var orderStatuses = []string{"pending", "processing", "shipped", "delivered", "cancelled", "expired"}
+
+// maxBulkOrders caps how many orders one bulk status update may change.
+const maxBulkOrders = 100
+
+type BulkStatusRequest struct {
+	OrderIDs []string `json:"orderIds" validate:"required,min=1"`
+	Status   string   `json:"status" validate:"required"`
+	Note     string   `json:"note"`
+}
+
+type OrderNoteRequest struct {
+	Text string `json:"text" validate:"required"`
+}
+
+type RefundRequest struct {
+	Amount float64 `json:"amount" validate:"required,gt=0"` // In the order's currency
+	Reason string  `json:"reason"`
+}
+
+// parseDate reads a date filter as RFC 3339 or YYYY-MM-DD. A bare date used
+// as the end of a range covers the whole day.
+func parseDate(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err == nil && end {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, err
+}
+
+// adminOrderFilter builds the search filter from the query string, leaving
+// out the status so counts per status can use the rest of it. The status is
+// fiber.StatusOK when the filter could be built.
+func adminOrderFilter(ctx context.Context, c *fiber.Ctx) (bson.M, int, string) {
+	filter := bson.M{}
+
+	if paymentStatus := c.Query("paymentStatus"); paymentStatus != "" {
+		filter["paymentStatus"] = paymentStatus
+	}
+
+	if preOrder := c.Query("preOrder"); preOrder != "" {
+		isPreOrder, err := strconv.ParseBool(preOrder)
+		if err != nil {
+			return nil, fiber.StatusBadRequest, "preOrder must be true or false"
+		}
+		filter["preOrder"] = bson.M{"$exists": isPreOrder}
+	}
+
+	createdAt := bson.M{}
+	if from := c.Query("from"); from != "" {
+		t, err := parseDate(from, false)
+		if err != nil {
+			return nil, fiber.StatusBadRequest, "Invalid from date, use YYYY-MM-DD or RFC 3339"
+		}
+		createdAt["$gte"] = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDate(to, true)
+		if err != nil {
+			return nil, fiber.StatusBadRequest, "Invalid to date, use YYYY-MM-DD or RFC 3339"
+		}
+		createdAt["$lte"] = t
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+
+	// The order ID may be ours or the payment gateway's
+	if orderId := strings.TrimSpace(c.Query("orderId")); orderId != "" {
+		if id, err := primitive.ObjectIDFromHex(orderId); err == nil {
+			filter["_id"] = id
+		} else {
+			filter["razorpayId"] = orderId
+		}
+	}
+
+	if email := strings.TrimSpace(c.Query("email")); email != "" {
+		cursor, err := userCollection.Find(ctx,
+			bson.M{"email": bson.M{"$regex": regexp.QuoteMeta(email), "$options": "i"}},
+			options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(100))
+		var users []models.User
+		if err == nil {
+			err = cursor.All(ctx, &users)
+		}
+		if err != nil {
+			return nil, fiber.StatusInternalServerError, "Failed to search customers"
+		}
+		userIDs := make([]primitive.ObjectID, 0, len(users))
+		for _, user := range users {
+			userIDs = append(userIDs, user.Id)
+		}
+		filter["userId"] = bson.M{"$in": userIDs}
+	}
+
+	return filter, fiber.StatusOK, ""
+}
+
+// statusCounts counts the orders matching filter per status.
+func statusCounts(ctx context.Context, filter bson.M) (map[string]int64, error) {
+	cursor, err := orderCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Status string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(orderStatuses))
+	for _, status := range orderStatuses {
+		counts[status] = 0
+	}
+	for _, group := range groups {
+		counts[group.Status] = group.Count
+	}
+	return counts, nil
+}
+
+// customers returns the name and email of the given users by ID.
+func customers(ctx context.Context, userIDs []primitive.ObjectID) (map[primitive.ObjectID]fiber.Map, error) {
+	cursor, err := userCollection.Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}},
+		options.Find().SetProjection(bson.M{"name": 1, "email": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]fiber.Map, len(users))
+	for _, user := range users {
+		byID[user.Id] = fiber.Map{"id": user.Id, "name": user.Name, "email": user.Email}
+	}
+	return byID, nil
+}
+
+// Only for admin. Searches orders across all users by status (comma
+// separated), payment status, pre-order or not, creation date range,
+// customer email and order ID, newest first. statusCounts counts the
+// matches per status ignoring the status filter, for dashboard tiles.
+func AdminGetOrders(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	page, err := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.Query("limit", "20"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	skip := (page - 1) * limit
+
+	filter, status, message := adminOrderFilter(ctx, c)
+	if status != fiber.StatusOK {
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	counts, err := statusCounts(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to count orders",
+			Result:  nil,
+		})
+	}
+
+	if status := c.Query("status"); status != "" {
+		filter["status"] = bson.M{"$in": strings.Split(status, ",")}
+	}
+
+	totalOrders, err := orderCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to count orders",
+			Result:  nil,
+		})
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(skip)
+	findOptions.SetLimit(limit)
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	var orders []models.Order
+	cursor, err := orderCollection.Find(ctx, filter, findOptions)
+	if err == nil {
+		err = cursor.All(ctx, &orders)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch orders",
+			Result:  nil,
+		})
+	}
+
+	userIDs := make([]primitive.ObjectID, 0, len(orders))
+	for _, order := range orders {
+		userIDs = append(userIDs, order.UserID)
+	}
+	byID, err := customers(ctx, userIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch customers",
+			Result:  nil,
+		})
+	}
+
+	results := make([]fiber.Map, 0, len(orders))
+	for _, order := range orders {
+		results = append(results, fiber.Map{
+			"order":    order,
+			"customer": byID[order.UserID],
+			"preOrder": order.PreOrder != nil,
+		})
+	}
+
+	totalPages := (totalOrders + limit - 1) / limit
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Orders fetched successfully",
+		Result: &fiber.Map{
+			"orders":       results,
+			"statusCounts": counts,
+			"currentPage":  page,
+			"totalPages":   totalPages,
+			"totalOrders":  totalOrders,
+		},
+	})
+}
+
+// Only for admin. Returns any order with its internal notes, customer,
+// shipments and returns.
+func AdminGetOrder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderObjectID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID format",
+			Result:  nil,
+		})
+	}
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderObjectID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Order not found",
+				Result:  nil,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch order",
+			Result:  nil,
+		})
+	}
+
+	byID, err := customers(ctx, []primitive.ObjectID{order.UserID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch customer",
+			Result:  nil,
+		})
+	}
+	shipments, err := shipmentService.ForOrder(ctx, order.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch shipments",
+			Result:  nil,
+		})
+	}
+	returns, err := returnService.ForOrder(ctx, order.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch returns",
+			Result:  nil,
+		})
+	}
+
+	notes := order.Notes
+	if notes == nil {
+		notes = []models.OrderNote{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Order fetched successfully",
+		Result: &fiber.Map{
+			"order":     order,
+			"notes":     notes,
+			"customer":  byID[order.UserID],
+			"shipments": shipments,
+			"returns":   returns,
+		},
+	})
+}
+
+// Only for admin. Moves each order to the status if the order state machine
+// allows it; orders that cannot move are reported and left alone.
+func BulkUpdateOrderStatus(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var request BulkStatusRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Result:  nil,
+		})
+	}
+	if len(request.OrderIDs) == 0 || len(request.OrderIDs) > maxBulkOrders || request.Status == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Send a status and between 1 and " + strconv.Itoa(maxBulkOrders) + " order IDs",
+			Result:  nil,
+		})
+	}
+
+	adminID, _ := primitive.ObjectIDFromHex(c.Locals("userId").(string))
+
+	updated := 0
+	results := make([]fiber.Map, 0, len(request.OrderIDs))
+	for _, id := range request.OrderIDs {
+		orderObjectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			results = append(results, fiber.Map{"orderId": id, "updated": false, "error": "Invalid order ID format"})
+			continue
+		}
+
+		order, err := orderService.ChangeStatus(ctx, orderObjectID, request.Status, models.OrderStatusChange{
+			Note: request.Note,
+			By:   &adminID,
+		})
+		switch err {
+		case nil:
+			updated++
+			results = append(results, fiber.Map{"orderId": id, "updated": true, "status": order.Status})
+		case orderService.ErrOrderNotFound, orderService.ErrInvalidStatusChange:
+			results = append(results, fiber.Map{"orderId": id, "updated": false, "status": order.Status, "error": err.Error()})
+		default:
+			results = append(results, fiber.Map{"orderId": id, "updated": false, "error": "Failed to update order"})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: strconv.Itoa(updated) + " of " + strconv.Itoa(len(request.OrderIDs)) + " orders updated",
+		Result: &fiber.Map{
+			"results": results,
+		},
+	})
+}
+
+// Only for admin. Leaves an internal note on an order.
+func AddOrderNote(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderObjectID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID format",
+			Result:  nil,
+		})
+	}
+
+	var request OrderNoteRequest
+	if err := c.BodyParser(&request); err != nil || strings.TrimSpace(request.Text) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Note text is required",
+			Result:  nil,
+		})
+	}
+
+	adminID, _ := primitive.ObjectIDFromHex(c.Locals("userId").(string))
+	note := models.OrderNote{
+		ID:        primitive.NewObjectID(),
+		Text:      strings.TrimSpace(request.Text),
+		AuthorID:  adminID,
+		CreatedAt: time.Now(),
+	}
+	if err := orderService.AddNote(ctx, orderObjectID, note); err != nil {
+		if err == orderService.ErrOrderNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Order not found",
+				Result:  nil,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save note",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(responses.UserResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Note added",
+		Result: &fiber.Map{
+			"note": note,
+		},
+	})
+}
+
+// Only for admin. Refunds part or all of a paid order, through the payment
+// gateway or as a manual payout for cash on delivery.
+func RefundOrder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	orderObjectID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID format",
+			Result:  nil,
+		})
+	}
+
+	var request RefundRequest
+	if err := c.BodyParser(&request); err != nil || request.Amount <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "A positive amount is required",
+			Result:  nil,
+		})
+	}
+
+	// The amount is entered in the order's own currency.
+	var order models.Order
+	err = orderCollection.FindOne(ctx, bson.M{"_id": orderObjectID}, options.FindOne().SetProjection(bson.M{"totalAmount": 1, "currency": 1})).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Order not found",
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching order",
+			Result:  nil,
+		})
+	}
+	currency := order.TotalAmount.Currency
+	amount := models.Money{Amount: currencyService.ToMinor(request.Amount, currency), Currency: currency}
+
+	refund, err := orderService.Refund(ctx, paymentProvider, orderObjectID, amount, request.Reason, nil)
+	switch err {
+	case nil:
+	case orderService.ErrOrderNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Order not found",
+			Result:  nil,
+		})
+	case orderService.ErrNotRefundable, orderService.ErrRefundExceedsPaid, orderService.ErrRefundCurrency:
+		return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Result:  nil,
+		})
+	default:
+		return c.Status(fiber.StatusBadGateway).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadGateway,
+			Message: "Refund failed: " + err.Error(),
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(responses.UserResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Refund issued",
+		Result: &fiber.Map{
+			"refund": refund,
+		},
+	})
+}
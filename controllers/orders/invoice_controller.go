@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fiber-mongo-api/responses"
+	invoiceService "fiber-mongo-api/services/invoices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sendInvoice responds with the order's invoice as a PDF, issuing it first
+// for paid orders that do not have one yet.
+func sendInvoice(ctx context.Context, c *fiber.Ctx, orderID primitive.ObjectID) error {
+	invoice, err := invoiceService.Issue(ctx, orderID)
+	switch err {
+	case nil:
+	case invoiceService.ErrOrderNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Order not found",
+			Result:  nil,
+		})
+	case invoiceService.ErrNotInvoiceable:
+		return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Result:  nil,
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to issue invoice",
+			Result:  nil,
+		})
+	}
+
+	var pdf bytes.Buffer
+	if err := invoiceService.Render(&pdf, invoice); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to render invoice",
+			Result:  nil,
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+strings.ReplaceAll(invoice.Number, "/", "-")+`.pdf"`)
+	return c.Status(fiber.StatusOK).Send(pdf.Bytes())
+}
+
+// DownloadInvoice sends the tax invoice of one of the user's paid orders.
+func DownloadInvoice(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	userId, ok := c.Locals("userId").(string)
+	if !ok || userId == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+			Result:  nil,
+		})
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid user ID format",
+			Result:  nil,
+		})
+	}
+
+	orderObjectID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID format",
+			Result:  nil,
+		})
+	}
+
+	// Only the customer who placed the order gets its invoice
+	count, err := orderCollection.CountDocuments(ctx, bson.M{"_id": orderObjectID, "userId": userObjectID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch order",
+			Result:  nil,
+		})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Order not found",
+			Result:  nil,
+		})
+	}
+
+	return sendInvoice(ctx, c, orderObjectID)
+}
+
+// Only for admin. Sends the tax invoice of any paid order.
+func AdminDownloadInvoice(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	orderObjectID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID format",
+			Result:  nil,
+		})
+	}
+	return sendInvoice(ctx, c, orderObjectID)
+}
@@ -8,6 +8,12 @@ import (
 	"fiber-mongo-api/configs"
 	"fiber-mongo-api/models"
 	"fiber-mongo-api/responses"
+	cartService "fiber-mongo-api/services/cart"
+	currencyService "fiber-mongo-api/services/currency"
+	orderService "fiber-mongo-api/services/orders"
+	promotionService "fiber-mongo-api/services/promotions"
+	returnService "fiber-mongo-api/services/returns"
+	shipmentService "fiber-mongo-api/services/shipments"
 	"strconv"
 	"time"
 
@@ -29,8 +35,10 @@ var razorpayKeySecret = configs.EnvRazorpayKeySecret()
 // CreateOrderRequest holds the data required to create an order
 type CreateOrderRequest struct {
 	AddressID string  `json:"addressId"`
-	Amount    float64 `json:"amount"`
-	Currency  string  `json:"currency"`
+	Amount    float64 `json:"amount"`   // Optional, must match the cart total when sent
+	Currency  string  `json:"currency"` // Optional, defaults to the base currency
+	// Optional, razorpay (the default) or cod
+	PaymentMethod string `json:"paymentMethod"`
 }
 
 // VerifyPaymentRequest holds the data for payment verification
@@ -64,6 +72,17 @@ func CreateOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	if orderReq.PaymentMethod == "" {
+		orderReq.PaymentMethod = models.PaymentRazorpay
+	}
+	if orderReq.PaymentMethod != models.PaymentRazorpay && orderReq.PaymentMethod != models.PaymentCOD {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Payment method must be razorpay or cod",
+			Result:  nil,
+		})
+	}
+
 	// Convert user ID to ObjectID
 	userObjectID, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
@@ -103,6 +122,52 @@ func CreateOrder(c *fiber.Ctx) error {
 		})
 	}
 
+	// Prices are checked in the currency the order is placed in
+	rate, err := currencyService.LoadRate(ctx, orderReq.Currency)
+	if err == currencyService.ErrUnsupportedCurrency || err == currencyService.ErrNoExchangeRate {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching exchange rate",
+			Result:  nil,
+		})
+	}
+
+	// Make sure the cart still matches the catalogue. Any change the shopper
+	// has not acknowledged yet blocks checkout.
+	changed, err := cartService.RevalidateCart(ctx, user.Cart, rate)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to revalidate cart",
+			Result:  nil,
+		})
+	}
+	if changed {
+		if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": userObjectID}, bson.M{"$set": bson.M{"cart": user.Cart}}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Failed to update cart",
+				Result:  nil,
+			})
+		}
+	}
+	if cartService.HasNotices(user.Cart) {
+		return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Cart has changed, review and acknowledge the changes before checkout",
+			Result: &fiber.Map{
+				"cartItems": rate.PriceCart(user.Cart),
+				"currency":  rate.Currency,
+			},
+		})
+	}
+
 	// Validate address belongs to user
 	var address models.Address
 	if err := addressCollection.FindOne(ctx, bson.M{
@@ -121,26 +186,119 @@ func CreateOrder(c *fiber.Ctx) error {
 	for _, cartItem := range user.Cart {
 		orderItems = append(orderItems, models.OrderItem{
 			ProductID: cartItem.Product.ID,
+			LineID:    cartItem.LineID,
 			Product:   cartItem.Product,
 			Quantity:  cartItem.Quantity,
 			Size:      cartItem.Product.Size,
 		})
 	}
 
-	// Initialize Razorpay client
-	client := razorpay.NewClient(razorpayKeyID, razorpayKeySecret)
+	// Price the order on the server so the amount charged matches the cart
+	totals, err := cartService.ComputeTotals(ctx, cartService.TotalsInput{
+		Cart:       user.Cart,
+		CouponCode: user.CouponCode,
+		UserID:     &userObjectID,
+		Address:    &address,
+		Currency:   orderReq.Currency,
+	})
+	if err == currencyService.ErrUnsupportedCurrency || err == currencyService.ErrNoExchangeRate {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to calculate order total",
+			Result:  nil,
+		})
+	}
+
+	if totals.CouponError != "" {
+		return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Coupon can no longer be applied: " + totals.CouponError,
+			Result: &fiber.Map{
+				"totals": totals,
+			},
+		})
+	}
+
+	if totals.ShippingError != "" {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnprocessableEntity,
+			Message: totals.ShippingError,
+			Result:  nil,
+		})
+	}
+
+	// The amount sent by the client is only a check that it showed the
+	// shopper the same total
+	if orderReq.Amount != 0 && currencyService.ToMinor(orderReq.Amount, totals.Currency) != totals.GrandTotal.Amount {
+		return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Order amount does not match cart total",
+			Result: &fiber.Map{
+				"totals": totals,
+			},
+		})
+	}
 
-	// Create order in Razorpay
-	orderAmount := int64(orderReq.Amount * 100) // Convert to paise (Razorpay uses smallest currency unit)
-	currency := "INR"
-	if orderReq.Currency != "" {
-		currency = orderReq.Currency
+	// Keep each line's GST and price on the order for invoicing and refunds
+	for i := range orderItems {
+		orderItems[i].Tax = &totals.ItemTaxes[i]
+		orderItems[i].UnitPrice = &totals.ItemPrices[i]
+		orderItems[i].BaseTotal = totals.ItemBaseTotals[i]
 	}
 
+	// Create order in database
+	now := time.Now()
+	order := models.Order{
+		ID:              primitive.NewObjectID(),
+		UserID:          userObjectID,
+		AddressID:       addressObjectID,
+		ShippingAddress: orderService.AddressSnapshot(address),
+		Items:           orderItems,
+		Subtotal:        totals.Subtotal,
+		Discounts:       totals.Discounts,
+		DiscountTotal:   totals.DiscountTotal,
+		CouponCode:      totals.CouponCode,
+		PlatformFee:     totals.PlatformFee,
+		Tax:             &totals.Tax,
+		Shipping:        totals.Shipping,
+		TotalAmount:     totals.GrandTotal,
+		Currency:        totals.Currency,
+		Base:            &totals.Base,
+		Status:          "pending",
+		PaymentMethod:   orderReq.PaymentMethod,
+		PaymentStatus:   "pending",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	// Pre-ordered items carry their release date, and the order how it is paid
+	orderService.ApplyPreOrder(&order, now)
+
+	if order.PaymentMethod == models.PaymentCOD {
+		return createCODOrder(ctx, c, order)
+	}
+
+	// Initialize Razorpay client
+	client := razorpay.NewClient(razorpayKeyID, razorpayKeySecret)
+
+	// Create order in Razorpay, in the smallest unit of the order currency.
+	// This happens first because it cannot be part of the database
+	// transaction; a Razorpay order whose checkout fails is never paid.
 	data := map[string]interface{}{
-		"amount":   orderAmount,
-		"currency": currency,
-		"receipt":  "receipt_" + primitive.NewObjectID().Hex(),
+		"amount":   totals.GrandTotal.Amount,
+		"currency": totals.GrandTotal.Currency,
+		"receipt":  "receipt_" + order.ID.Hex(),
+	}
+	// A pre-order paid on dispatch is only authorized now and captured when
+	// it ships
+	if orderService.CapturesOnDispatch(order) {
+		data["payment_capture"] = 0
 	}
 
 	razorpayOrder, err := client.Order.Create(data, nil)
@@ -151,41 +309,129 @@ func CreateOrder(c *fiber.Ctx) error {
 			Result:  nil,
 		})
 	}
+	order.RazorpayID = razorpayOrder["id"].(string)
 
-	// Create order in database
-	now := time.Now()
-	order := models.Order{
-		ID:            primitive.NewObjectID(),
-		UserID:        userObjectID,
-		AddressID:     addressObjectID,
-		Items:         orderItems,
-		TotalAmount:   orderReq.Amount,
-		Status:        "pending",
-		PaymentStatus: "pending",
-		RazorpayID:    razorpayOrder["id"].(string),
-		CreatedAt:     now,
-		UpdatedAt:     now,
-	}
-
-	_, err = orderCollection.InsertOne(ctx, order)
-	if err != nil {
+	// Take the promotion uses and hold the stock together with the insert, so
+	// limited coupons and stock cannot be oversold
+	if err := orderService.PlaceOrder(ctx, &order); err != nil {
+		return placeOrderError(c, err)
+	}
+
+	// Return order details to client
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Order created successfully",
+		Result: &fiber.Map{
+			"orderId":       order.ID.Hex(),
+			"paymentMethod": order.PaymentMethod,
+			"razorpayId":    razorpayOrder["id"],
+			"amount":        razorpayOrder["amount"],
+			"currency":      razorpayOrder["currency"],
+			"key_id":        razorpayKeyID,
+			"preOrder":      order.PreOrder,
+		},
+	})
+}
+
+// placeOrderError turns a failure to place an order into a response.
+func placeOrderError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := "Failed to create order in database"
+	if err == promotionService.ErrCouponUsedUp || err == promotionService.ErrCouponUserLimit ||
+		err == orderService.ErrOutOfStock {
+		status = fiber.StatusConflict
+		message = err.Error()
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+// createCODOrder places a cash on delivery order. It skips the gateway and
+// is confirmed at once, with the payment collected on delivery.
+func createCODOrder(ctx context.Context, c *fiber.Ctx, order models.Order) error {
+	if err := orderService.CheckCODEligibility(ctx, order.UserID, order); err != nil {
+		if err == orderService.ErrCODCurrency || err == orderService.ErrCODPinCode ||
+			err == orderService.ErrCODOrderValue || err == orderService.ErrCODUserLimit ||
+			err == orderService.ErrCODPreOrder {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(responses.UserResponse{
+				Status:  fiber.StatusUnprocessableEntity,
+				Message: err.Error(),
+				Result:  nil,
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to create order in database",
+			Message: "Failed to check cash on delivery eligibility",
 			Result:  nil,
 		})
 	}
 
-	// Return order details to client
+	order.Status = "processing"
+	order.PaymentStatus = models.PaymentCODPending
+	if err := orderService.PlaceCODOrder(ctx, &order); err != nil {
+		return placeOrderError(c, err)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
 		Message: "Order created successfully",
 		Result: &fiber.Map{
-			"orderId":    order.ID.Hex(),
-			"razorpayId": razorpayOrder["id"],
-			"amount":     razorpayOrder["amount"],
-			"currency":   razorpayOrder["currency"],
-			"key_id":     razorpayKeyID,
+			"orderId":       order.ID.Hex(),
+			"paymentMethod": order.PaymentMethod,
+			"paymentStatus": order.PaymentStatus,
+			"amount":        order.TotalAmount.Amount,
+			"currency":      order.TotalAmount.Currency,
+		},
+	})
+}
+
+// Only for admin. Records that the cash for a COD order was collected when
+// it was delivered.
+func MarkCODCollected(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderObjectID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID format",
+			Result:  nil,
+		})
+	}
+
+	adminID, _ := primitive.ObjectIDFromHex(c.Locals("userId").(string))
+
+	order, err := orderService.MarkCODCollected(ctx, orderObjectID, adminID)
+	if err == orderService.ErrOrderNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Order not found",
+			Result:  nil,
+		})
+	} else if err == orderService.ErrCODNotCollecting || err == orderService.ErrCODNotDeliverable ||
+		err == orderService.ErrInvalidStatusChange {
+		return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update order",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Cash on delivery collected",
+		Result: &fiber.Map{
+			"order": order,
 		},
 	})
 }
@@ -233,41 +479,53 @@ func VerifyPayment(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update order status in database
-	orderObjectID, err := primitive.ObjectIDFromHex(verifyReq.OrderID)
+	userObjectID, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
 			Status:  fiber.StatusBadRequest,
-			Message: "Invalid order ID format",
+			Message: "Invalid user ID format",
 			Result:  nil,
 		})
 	}
 
-	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	orderObjectID, err := primitive.ObjectIDFromHex(verifyReq.OrderID)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
 			Status:  fiber.StatusBadRequest,
-			Message: "Invalid user ID format",
+			Message: "Invalid order ID format",
 			Result:  nil,
 		})
 	}
 
-	// Update order with payment details
-	update := bson.M{
-		"$set": bson.M{
-			"paymentStatus": "completed",
-			"status":        "processing", // Change order status to processing after payment
-			"paymentId":     verifyReq.PaymentID,
-			"updatedAt":     time.Now(),
-		},
+	var order models.Order
+	err = orderCollection.FindOne(ctx, bson.M{"_id": orderObjectID, "userId": userObjectID}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Order not found or doesn't belong to user",
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch order",
+			Result:  nil,
+		})
 	}
 
-	result, err := orderCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": orderObjectID, "userId": userObjectID},
-		update,
-	)
+	// The signature only vouches for the Razorpay order it was made for
+	if order.RazorpayID != verifyReq.RazorpayID {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Payment does not belong to this order",
+			Result:  nil,
+		})
+	}
 
+	// Only a pending order is moved on, so a repeated call cannot clear a cart
+	// the shopper has filled again since. The ordered items and applied coupon
+	// are removed from the cart together with the payment update.
+	updated, err := orderService.CompletePayment(ctx, order, verifyReq.PaymentID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -276,30 +534,48 @@ func VerifyPayment(c *fiber.Ctx) error {
 		})
 	}
 
-	if result.MatchedCount == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
-			Status:  fiber.StatusNotFound,
-			Message: "Order not found or doesn't belong to user",
-			Result:  nil,
-		})
-	}
+	if !updated {
+		if err := orderCollection.FindOne(ctx, bson.M{"_id": orderObjectID}).Decode(&order); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Failed to fetch order",
+				Result:  nil,
+			})
+		}
 
-	// Clear user's cart after successful payment
-	_, err = userCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": userObjectID},
-		bson.M{"$set": bson.M{"cart": []interface{}{}}},
-	)
+		if order.PaymentStatus != "completed" && order.PaymentStatus != models.PaymentAuthorized {
+			return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+				Status:  fiber.StatusConflict,
+				Message: "Order is no longer awaiting payment",
+				Result:  nil,
+			})
+		}
+		if order.PaymentID != verifyReq.PaymentID {
+			return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+				Status:  fiber.StatusConflict,
+				Message: "Order has already been paid",
+				Result:  nil,
+			})
+		}
 
-	if err != nil {
-		// Log error but don't fail the request
-		// The payment is successful, but cart couldn't be cleared
-		// You might want to handle this in a background job
+		return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+			Status:  fiber.StatusOK,
+			Message: "Payment already verified",
+			Result: &fiber.Map{
+				"orderId":    verifyReq.OrderID,
+				"paymentId":  verifyReq.PaymentID,
+				"razorpayId": verifyReq.RazorpayID,
+			},
+		})
 	}
 
+	message := "Payment verified successfully"
+	if orderService.CapturesOnDispatch(order) {
+		message = "Payment authorized, it will be charged when the order ships"
+	}
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
-		Message: "Payment verified successfully",
+		Message: message,
 		Result: &fiber.Map{
 			"orderId":    verifyReq.OrderID,
 			"paymentId":  verifyReq.PaymentID,
@@ -404,6 +680,7 @@ func GetOrders(c *fiber.Ctx) error {
 			"items":     simplifiedItems,
 			"status":    order.Status,
 			"total":     order.TotalAmount,
+			"currency":  order.Currency,
 			"createdAt": order.CreatedAt,
 		})
 	}
@@ -487,11 +764,40 @@ func GetOrderById(c *fiber.Ctx) error {
 		})
 	}
 
+	// Orders placed before addresses were copied onto them fall back to the
+	// address book entry, if it still exists
+	if order.ShippingAddress == nil {
+		var address models.Address
+		if err := addressCollection.FindOne(ctx, bson.M{"_id": order.AddressID, "userId": userObjectID}).Decode(&address); err == nil {
+			order.ShippingAddress = orderService.AddressSnapshot(address)
+		}
+	}
+
+	shipments, err := shipmentService.ForOrder(ctx, order.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch shipments",
+			Result:  nil,
+		})
+	}
+
+	returns, err := returnService.ForOrder(ctx, order.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch returns",
+			Result:  nil,
+		})
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
 		Message: "Order fetched successfully",
 		Result: &fiber.Map{
-			"order": order,
+			"order":     order,
+			"shipments": shipments,
+			"returns":   returns,
 		},
 	})
 }
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var userCollection *mongo.Collection = configs.GetCollection(configs.DB, "users")
+
+// annotateCartState fills InCart and CartItemCount on each product from the
+// requesting user's cart. Anonymous requests are left untouched, and a
+// failed cart lookup only drops the annotation rather than failing the
+// request.
+func annotateCartState(ctx context.Context, c *fiber.Ctx, products []models.Product) {
+	userId, ok := c.Locals("userId").(string)
+	if !ok || userId == "" || len(products) == 0 {
+		return
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return
+	}
+
+	var user models.User
+	findOptions := options.FindOne().SetProjection(bson.M{"cart": 1})
+	if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}, findOptions).Decode(&user); err != nil {
+		return
+	}
+
+	// A product can sit in the cart in several sizes; count all of them
+	counts := make(map[primitive.ObjectID]int)
+	for _, cartItem := range user.Cart {
+		counts[cartItem.Product.ID] += cartItem.Quantity
+	}
+
+	for i := range products {
+		if count, found := counts[products[i].ID]; found {
+			products[i].InCart = true
+			products[i].CartItemCount = count
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	categoryService "fiber-mongo-api/services/categories"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// browseSorts are the orders a category can be browsed in.
+var browseSorts = map[string]bson.D{
+	"newest":     {{Key: "_id", Value: -1}},
+	"price_asc":  {{Key: "price", Value: 1}, {Key: "_id", Value: 1}},
+	"price_desc": {{Key: "price", Value: -1}, {Key: "_id", Value: 1}},
+}
+
+// Products in a category (slug) and, unless descendants=false, in every
+// category below it, with the category's breadcrumbs.
+func BrowseCategory(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	category, err := categoryService.BySlug(ctx, c.Query("slug"))
+	if err == categoryService.ErrCategoryNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Category not found",
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching category",
+			Result:  nil,
+		})
+	}
+
+	sort, ok := browseSorts[c.Query("sort", "newest")]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "sort must be newest, price_asc or price_desc",
+			Result:  nil,
+		})
+	}
+
+	page, err := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.Query("limit", "10"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	ids := []interface{}{category.ID}
+	if c.Query("descendants") != "false" {
+		subtree, err := categoryService.Subtree(ctx, category.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Error fetching subcategories",
+				Result:  nil,
+			})
+		}
+		ids = ids[:0]
+		for _, id := range subtree {
+			ids = append(ids, id)
+		}
+	}
+	filter := bson.M{"categoryIds": bson.M{"$in": ids}}
+
+	totalProducts, err := productCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error counting products",
+			Result:  nil,
+		})
+	}
+
+	findOptions := options.Find().SetSort(sort).SetSkip((page - 1) * limit).SetLimit(limit)
+	products := []models.Product{}
+	cursor, err := productCollection.Find(ctx, filter, findOptions)
+	if err == nil {
+		err = cursor.All(ctx, &products)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching products",
+			Result:  nil,
+		})
+	}
+	annotateCartState(ctx, c, products)
+
+	breadcrumbs, err := categoryService.Breadcrumbs(ctx, category)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching category",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched products",
+		Result: &fiber.Map{
+			"category":      category,
+			"breadcrumbs":   breadcrumbs,
+			"currentPage":   page,
+			"totalPages":    (totalProducts + limit - 1) / limit,
+			"totalProducts": totalProducts,
+			"products":      products,
+		},
+	})
+}
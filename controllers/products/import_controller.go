@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	productService "fiber-mongo-api/services/products"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// importFormat works out the format of an upload: the format query
+// parameter, then the file extension, then the content type.
+func importFormat(c *fiber.Ctx, filename, contentType string) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return models.ImportCSV
+	case ".ndjson", ".jsonl":
+		return models.ImportNDJSON
+	}
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return models.ImportCSV
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+		return models.ImportNDJSON
+	}
+	return ""
+}
+
+// Only for admin. Queues a CSV or NDJSON import of products, upserted by
+// SKU, sent as a multipart "file" or as the raw body. The import runs in the
+// background; poll it for counts and row errors.
+func ImportProducts(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var data []byte
+	var filename string
+	contentType := string(c.Request().Header.ContentType())
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err == nil {
+			data, err = io.ReadAll(f)
+			f.Close()
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Error reading the uploaded file",
+				Result:  nil,
+			})
+		}
+		filename = file.Filename
+		contentType = file.Header.Get(fiber.HeaderContentType)
+	} else {
+		data = c.Body()
+	}
+
+	format := importFormat(c, filename, contentType)
+	adminID, _ := primitive.ObjectIDFromHex(c.Locals("userId").(string))
+
+	productImport, err := productService.CreateImport(ctx, format, filename, data, adminID)
+	if err == productService.ErrInvalidFormat || err == productService.ErrEmptyFile {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error queueing import",
+			Result:  nil,
+		})
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), productService.ImportLease)
+		defer cancel()
+		if err := productService.Process(ctx, productImport.ID); err != nil {
+			log.Printf("products: import %s: %v", productImport.ID.Hex(), err)
+		}
+	}()
+
+	return c.Status(fiber.StatusAccepted).JSON(responses.UserResponse{
+		Status:  fiber.StatusAccepted,
+		Message: "Import queued",
+		Result: &fiber.Map{
+			"import": productImport,
+		},
+	})
+}
+
+// Only for admin. Imports, newest first, without their row errors.
+func GetProductImports(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	page, err := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.Query("limit", "10"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	imports, total, err := productService.ListImports(ctx, page, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching imports",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched imports",
+		Result: &fiber.Map{
+			"currentPage":  page,
+			"totalPages":   (total + limit - 1) / limit,
+			"totalImports": total,
+			"imports":      imports,
+		},
+	})
+}
+
+// Only for admin. One import with its progress and row errors.
+func GetProductImport(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid import ID",
+			Result:  nil,
+		})
+	}
+
+	productImport, err := productService.GetImport(ctx, id)
+	if err == productService.ErrImportNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Import not found",
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching import",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched import",
+		Result: &fiber.Map{
+			"import": productImport,
+		},
+	})
+}
+
+// Only for admin. Streams the whole catalogue as CSV or NDJSON (format), in
+// the columns an import reads.
+func ExportProducts(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format", models.ImportCSV))
+	if !productService.ValidFormat(format) {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: productService.ErrInvalidFormat.Error(),
+			Result:  nil,
+		})
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == models.ImportNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="products.`+format+`"`)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		// The status has been sent by now, so a failure can only cut the file short
+		if err := productService.Export(ctx, w, format); err != nil {
+			log.Printf("products: export: %v", err)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	productService "fiber-mongo-api/services/products"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PreOrderRequest struct {
+	ReleaseDate time.Time `json:"releaseDate" validate:"required"`
+	Payment     string    `json:"payment"` // upfront (the default) or authorize
+}
+
+// preOrderError maps pre-order errors to responses.
+func preOrderError(c *fiber.Ctx, err error) error {
+	status, message := fiber.StatusInternalServerError, "Error updating pre-order"
+	switch err {
+	case productService.ErrProductNotFound:
+		status, message = fiber.StatusNotFound, err.Error()
+	case productService.ErrReleaseDatePassed, productService.ErrInvalidPreOrderPayment:
+		status, message = fiber.StatusBadRequest, err.Error()
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+// Only for admin. Opens a product (id) for pre-order until its release
+// date, paid upfront or authorized and captured at dispatch.
+func SetPreOrder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid product Id",
+			Result:  nil,
+		})
+	}
+	var request PreOrderRequest
+	if err := c.BodyParser(&request); err != nil || request.ReleaseDate.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body, releaseDate is required in RFC 3339",
+			Result:  nil,
+		})
+	}
+
+	product, err := productService.SetPreOrder(ctx, productID, models.PreOrder{
+		ReleaseDate: request.ReleaseDate,
+		Payment:     request.Payment,
+	})
+	if err != nil {
+		return preOrderError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Product is open for pre-order",
+		Result: &fiber.Map{
+			"product": product,
+		},
+	})
+}
+
+// Only for admin. Ends a product's (id) pre-order; it is sold as an
+// ordinary product again.
+func ClearPreOrder(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid product Id",
+			Result:  nil,
+		})
+	}
+
+	product, err := productService.ClearPreOrder(ctx, productID)
+	if err != nil {
+		return preOrderError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Pre-order ended",
+		Result: &fiber.Map{
+			"product": product,
+		},
+	})
+}
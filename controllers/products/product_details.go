@@ -6,6 +6,9 @@ import (
 	"time"
 
 	"fiber-mongo-api/responses"
+	currencyService "fiber-mongo-api/services/currency"
+	reviewService "fiber-mongo-api/services/reviews"
+	shippingService "fiber-mongo-api/services/shipping"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -47,14 +50,84 @@ func FetchProductDetails(c *fiber.Ctx) error {
 		})
 	}
 
+	annotated := []models.Product{product}
+	annotateCartState(ctx, c, annotated)
+	product = annotated[0]
+
 	// If product is found, return the product details
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
 		Message: "Product fetched successfully",
 		Result: &fiber.Map{
-			"status":  "success",
-			"product": product,
+			"status":        "success",
+			"product":       product,
+			"ratingSummary": reviewService.Summary(product.Rating),
 		},
 	})
 
 }
+
+// CheckPinCode tells a shopper on the product page whether the product can be
+// delivered to their PIN code, what one unit costs to ship and when it would
+// arrive.
+func CheckPinCode(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objectId, err := primitive.ObjectIDFromHex(c.Query("productId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid product ID format",
+			Result:  nil,
+		})
+	}
+
+	pinCode := shippingService.NormalizePinCode(c.Query("pinCode"))
+	if !shippingService.ValidPinCode(pinCode) {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: shippingService.ErrInvalidPinCode.Error(),
+			Result:  nil,
+		})
+	}
+
+	var product models.Product
+	err = productCollection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Product not found",
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching product details",
+			Result:  nil,
+		})
+	}
+
+	weight := shippingService.ParcelWeight([]models.CartItem{{Product: product, Quantity: 1}})
+	quote, err := shippingService.Quote(ctx, pinCode, weight, currencyService.BasePrice(product))
+	if err != nil && err != shippingService.ErrNotServiceable {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error checking delivery",
+			Result:  nil,
+		})
+	}
+
+	message := "Delivery available"
+	if !quote.Serviceable {
+		message = shippingService.ErrNotServiceable.Error()
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Result: &fiber.Map{
+			"shipping": quote,
+		},
+	})
+}
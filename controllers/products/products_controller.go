@@ -5,7 +5,11 @@ import (
 	"fiber-mongo-api/configs"
 	"fiber-mongo-api/models"
 	"fiber-mongo-api/responses"
+	categoryService "fiber-mongo-api/services/categories"
+	merchandisingService "fiber-mongo-api/services/merchandising"
+	productService "fiber-mongo-api/services/products"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -69,6 +73,8 @@ func GetAllProducts(c *fiber.Ctx) error {
 			Result:  nil,
 		})
 	}
+	annotateCartState(ctx, c, products)
+
 	totalPages := (totalProducts + int64(limit) - 1) / int64(limit)
 
 	//Determine response status
@@ -106,8 +112,41 @@ func AddProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	product.SKU = strings.TrimSpace(product.SKU)
+	product.Rating = nil   // Ratings come from reviews
+	product.PreOrder = nil // Set through the pre-order endpoint, which checks it
+	if errs := productService.Validate(product); len(errs) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid product data",
+			Result: &fiber.Map{
+				"errors": errs,
+			},
+		})
+	}
+
+	if err := categoryService.CheckIDs(ctx, product.CategoryIDs); err == categoryService.ErrCategoryNotFound {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Unknown category in categoryIds",
+			Result:  nil,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error checking categories",
+			Result:  nil,
+		})
+	}
+
 	result, err := productCollection.InsertOne(ctx, product)
-	if err != nil {
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+			Status:  fiber.StatusConflict,
+			Message: "A product with this SKU already exists",
+			Result:  nil,
+		})
+	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
 			Message: "Error inserting product",
@@ -191,6 +230,8 @@ func SearchProducts(c *fiber.Ctx) error {
 		})
 	}
 
+	annotateCartState(ctx, c, products)
+
 	totalPages := (totalProducts + limit - 1) / limit
 
 	//If no products found
@@ -214,6 +255,27 @@ func SearchProducts(c *fiber.Ctx) error {
 	})
 }
 
+// brandShelf returns a brand's products from the merchandising collection
+// slug, or from the legacy category while marketing has not set it up.
+func brandShelf(ctx context.Context, slug, category, brand string, limit int64) ([]models.Product, error) {
+	collection, err := merchandisingService.CollectionBySlug(ctx, slug)
+	if err == nil {
+		return merchandisingService.Products(ctx, collection, bson.M{"brand": brand}, limit)
+	} else if err != merchandisingService.ErrCollectionNotFound {
+		return nil, err
+	}
+
+	products := []models.Product{}
+	cursor, err := productCollection.Find(ctx, bson.M{"brand": brand, "category": category}, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
 func GetPopularProducts(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -227,19 +289,12 @@ func GetPopularProducts(c *fiber.Ctx) error {
 		})
 	}
 
-	// Filter products by brand and Popular Brands
-	popularFilter := bson.M{"brand": brand, "category": "Popular Brands"}
-
-	// Filter products by brand and New Arrivals
-	newArrivalFilter := bson.M{"brand": brand, "category": "New Arrivals"}
-
-	// Set a limit of 10 items
-	findOptions := options.Find()
-	findOptions.SetLimit(10)
+	limit, err := strconv.ParseInt(c.Query("limit", "10"), 10, 64)
+	if err != nil || limit < 1 || limit > 50 {
+		limit = 10
+	}
 
-	// Find popularProducts
-	var popularProducts []models.Product
-	popularCursor, err := productCollection.Find(ctx, popularFilter, findOptions)
+	popularProducts, err := brandShelf(ctx, "popular-brands", "Popular Brands", brand, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -248,17 +303,7 @@ func GetPopularProducts(c *fiber.Ctx) error {
 		})
 	}
 
-	if err = popularCursor.All(ctx, &popularProducts); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Error parsing products",
-			Result:  nil,
-		})
-	}
-
-	// Find newArrivalProducts
-	var newArrivalProducts []models.Product
-	newArrivalCursor, err := productCollection.Find(ctx, newArrivalFilter, findOptions)
+	newArrivalProducts, err := brandShelf(ctx, "new-arrivals", "New Arrivals", brand, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -267,14 +312,6 @@ func GetPopularProducts(c *fiber.Ctx) error {
 		})
 	}
 
-	if err = newArrivalCursor.All(ctx, &newArrivalProducts); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Error parsing products",
-			Result:  nil,
-		})
-	}
-
 	//Return the success data
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
@@ -284,5 +321,4 @@ func GetPopularProducts(c *fiber.Ctx) error {
 			"newArrivals": newArrivalProducts,
 		},
 	})
-
 }
@@ -0,0 +1,293 @@
+package promotionController
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	promotionService "fiber-mongo-api/services/promotions"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var promotionCollection *mongo.Collection = configs.GetCollection(configs.DB, "promotions")
+
+// PromotionRequest is the admin payload for creating or updating a
+// promotion. Leave Code empty and set Automatic for a no-code promotion.
+type PromotionRequest struct {
+	Code         string     `json:"code"`
+	Name         string     `json:"name" validate:"required"`
+	Description  string     `json:"description"`
+	Type         string     `json:"type" validate:"required,oneof=percentage flat"`
+	Value        float64    `json:"value" validate:"required,gt=0"`
+	MaxDiscount  float64    `json:"maxDiscount"`
+	MinCartValue float64    `json:"minCartValue"`
+	Brands       []string   `json:"brands"`
+	Categories   []string   `json:"categories"`
+	UsageLimit   int        `json:"usageLimit"`
+	PerUserLimit int        `json:"perUserLimit"`
+	Automatic    bool       `json:"automatic"`
+	Active       bool       `json:"active"`
+	StartsAt     *time.Time `json:"startsAt"`
+	EndsAt       *time.Time `json:"endsAt"`
+}
+
+// validate checks the rules the validate tags describe plus the ones that
+// span fields. It returns an empty string when the request is valid.
+func (r *PromotionRequest) validate() string {
+	r.Code = promotionService.NormalizeCode(r.Code)
+
+	switch {
+	case r.Name == "":
+		return "Promotion name is required"
+	case r.Type != models.PromotionPercentage && r.Type != models.PromotionFlat:
+		return "Promotion type must be percentage or flat"
+	case r.Value <= 0:
+		return "Promotion value must be greater than 0"
+	case r.Type == models.PromotionPercentage && r.Value > 100:
+		return "Percentage promotions cannot exceed 100"
+	case r.Code == "" && !r.Automatic:
+		return "A promotion needs a coupon code or must be automatic"
+	case r.Code != "" && r.Automatic:
+		return "Automatic promotions cannot have a coupon code"
+	case r.MaxDiscount < 0 || r.MinCartValue < 0 || r.UsageLimit < 0 || r.PerUserLimit < 0:
+		return "Limits cannot be negative"
+	case r.StartsAt != nil && r.EndsAt != nil && !r.EndsAt.After(*r.StartsAt):
+		return "endsAt must be after startsAt"
+	}
+	return ""
+}
+
+// Only for admin
+func CreatePromotion(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request PromotionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing promotion data",
+			Result:  nil,
+		})
+	}
+
+	if message := request.validate(); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	now := time.Now()
+	promotion := models.Promotion{
+		ID:           primitive.NewObjectID(),
+		Code:         request.Code,
+		Name:         request.Name,
+		Description:  request.Description,
+		Type:         request.Type,
+		Value:        request.Value,
+		MaxDiscount:  request.MaxDiscount,
+		MinCartValue: request.MinCartValue,
+		Brands:       request.Brands,
+		Categories:   request.Categories,
+		UsageLimit:   request.UsageLimit,
+		PerUserLimit: request.PerUserLimit,
+		Automatic:    request.Automatic,
+		Active:       request.Active,
+		StartsAt:     request.StartsAt,
+		EndsAt:       request.EndsAt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if _, err := promotionCollection.InsertOne(ctx, promotion); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+				Status:  fiber.StatusConflict,
+				Message: "A promotion with this code already exists",
+				Result:  nil,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error inserting promotion",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Promotion created successfully",
+		Result: &fiber.Map{
+			"promotion": promotion,
+		},
+	})
+}
+
+// Only for admin
+func GetPromotions(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageStr := c.Query("page", "1")
+	limitStr := c.Query("limit", "10")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	skip := (page - 1) * limit
+
+	filter := bson.M{}
+	if active := c.Query("active"); active != "" {
+		filter["active"] = active == "true"
+	}
+
+	totalPromotions, err := promotionCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error counting promotions",
+			Result:  nil,
+		})
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(skip)
+	findOptions.SetLimit(limit)
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	var promotions []models.Promotion
+	cursor, err := promotionCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching promotions",
+			Result:  nil,
+		})
+	}
+	if err = cursor.All(ctx, &promotions); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error parsing promotions",
+			Result:  nil,
+		})
+	}
+
+	totalPages := (totalPromotions + limit - 1) / limit
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched promotions",
+		Result: &fiber.Map{
+			"currentPage":     page,
+			"totalPages":      totalPages,
+			"totalPromotions": totalPromotions,
+			"promotions":      promotions,
+		},
+	})
+}
+
+// Only for admin. Usage counters are kept; set active to false to retire a
+// promotion without losing its redemption history.
+func UpdatePromotion(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	promotionObjId, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid promotion ID format",
+			Result:  nil,
+		})
+	}
+
+	var request PromotionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing promotion data",
+			Result:  nil,
+		})
+	}
+
+	if message := request.validate(); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	set := bson.M{
+		"name":         request.Name,
+		"description":  request.Description,
+		"type":         request.Type,
+		"value":        request.Value,
+		"maxDiscount":  request.MaxDiscount,
+		"minCartValue": request.MinCartValue,
+		"brands":       request.Brands,
+		"categories":   request.Categories,
+		"usageLimit":   request.UsageLimit,
+		"perUserLimit": request.PerUserLimit,
+		"automatic":    request.Automatic,
+		"active":       request.Active,
+		"startsAt":     request.StartsAt,
+		"endsAt":       request.EndsAt,
+		"updatedAt":    time.Now(),
+	}
+	update := bson.M{"$set": set}
+	if request.Code != "" {
+		set["code"] = request.Code
+	} else {
+		update["$unset"] = bson.M{"code": ""}
+	}
+
+	var promotion models.Promotion
+	err = promotionCollection.FindOneAndUpdate(ctx, bson.M{"_id": promotionObjId}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&promotion)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Promotion not found",
+				Result:  nil,
+			})
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+				Status:  fiber.StatusConflict,
+				Message: "A promotion with this code already exists",
+				Result:  nil,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error updating promotion",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Promotion updated successfully",
+		Result: &fiber.Map{
+			"promotion": promotion,
+		},
+	})
+}
@@ -0,0 +1,247 @@
+package reportController
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fiber-mongo-api/responses"
+	reportService "fiber-mongo-api/services/reports"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxRangeDays caps the days a report or rollup rebuild may cover.
+const maxRangeDays = 366
+
+// dateRange reads the from and to query dates (YYYY-MM-DD, IST), both
+// inclusive. It defaults to the last 30 days. The status is fiber.StatusOK
+// when the range is valid.
+func dateRange(c *fiber.Ctx) (time.Time, time.Time, int, string) {
+	to := reportService.StartOfDay(time.Now())
+	if value := c.Query("to"); value != "" {
+		t, err := time.ParseInLocation(reportService.DateLayout, value, reportService.IST)
+		if err != nil {
+			return to, to, fiber.StatusBadRequest, "Invalid to date, use YYYY-MM-DD"
+		}
+		to = t
+	}
+
+	from := to.AddDate(0, 0, -29)
+	if value := c.Query("from"); value != "" {
+		t, err := time.ParseInLocation(reportService.DateLayout, value, reportService.IST)
+		if err != nil {
+			return from, to, fiber.StatusBadRequest, "Invalid from date, use YYYY-MM-DD"
+		}
+		from = t
+	}
+
+	if from.After(to) || to.Sub(from) > maxRangeDays*24*time.Hour {
+		return from, to, fiber.StatusBadRequest, "from must not be after to, and the range is limited to " + strconv.Itoa(maxRangeDays) + " days"
+	}
+	return from, to, fiber.StatusOK, ""
+}
+
+func money(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// sendCSV responds with rows as a CSV download.
+func sendCSV(c *fiber.Ctx, filename string, header []string, rows [][]string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(header)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error writing CSV",
+			Result:  nil,
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+func badRequest(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+		Status:  fiber.StatusBadRequest,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+// Only for admin. Revenue, refunds, orders and average order value per day,
+// week or month (interval), from the daily rollups. format=csv downloads it.
+func GetRevenueReport(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	from, to, status, message := dateRange(c)
+	if status != fiber.StatusOK {
+		return badRequest(c, message)
+	}
+	interval := c.Query("interval", "day")
+
+	points, err := reportService.Revenue(ctx, from, to, interval)
+	if err == reportService.ErrInvalidInterval {
+		return badRequest(c, err.Error())
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error building revenue report",
+			Result:  nil,
+		})
+	}
+
+	if c.Query("format") == "csv" {
+		rows := make([][]string, 0, len(points))
+		for _, point := range points {
+			rows = append(rows, []string{
+				point.Period.Format(reportService.DateLayout),
+				strconv.FormatInt(point.Orders, 10),
+				strconv.FormatInt(point.PaidOrders, 10),
+				strconv.FormatInt(point.Units, 10),
+				money(point.Revenue),
+				money(point.Refunds),
+				money(point.NetRevenue),
+				money(point.AverageOrderValue),
+			})
+		}
+		return sendCSV(c, "revenue-"+interval+".csv",
+			[]string{interval, "orders", "paid_orders", "units", "revenue", "refunds", "net_revenue", "average_order_value"}, rows)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Revenue report",
+		Result: &fiber.Map{
+			"from":     from.Format(reportService.DateLayout),
+			"to":       to.Format(reportService.DateLayout),
+			"interval": interval,
+			"points":   points,
+		},
+	})
+}
+
+// Only for admin. Units sold and revenue per product, brand or size (by),
+// best sellers first. format=csv downloads it.
+func GetUnitsReport(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	from, to, status, message := dateRange(c)
+	if status != fiber.StatusOK {
+		return badRequest(c, message)
+	}
+	by := c.Query("by", "product")
+
+	lines, err := reportService.Units(ctx, from, to, by)
+	if err == reportService.ErrInvalidDimension {
+		return badRequest(c, err.Error())
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error building units report",
+			Result:  nil,
+		})
+	}
+
+	if c.Query("format") == "csv" {
+		rows := make([][]string, 0, len(lines))
+		for _, line := range lines {
+			rows = append(rows, []string{line.Key, line.Name, strconv.FormatInt(line.Units, 10), money(line.Revenue)})
+		}
+		return sendCSV(c, "units-by-"+by+".csv", []string{by, "name", "units", "revenue"}, rows)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Units report",
+		Result: &fiber.Map{
+			"from":  from.Format(reportService.DateLayout),
+			"to":    to.Format(reportService.DateLayout),
+			"by":    by,
+			"lines": lines,
+		},
+	})
+}
+
+// Only for admin. Headline figures and rates for a date range. format=csv
+// downloads it.
+func GetSummaryReport(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	from, to, status, message := dateRange(c)
+	if status != fiber.StatusOK {
+		return badRequest(c, message)
+	}
+
+	summary, err := reportService.GetSummary(ctx, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error building summary report",
+			Result:  nil,
+		})
+	}
+
+	if c.Query("format") == "csv" {
+		rate := func(r float64) string { return strconv.FormatFloat(r, 'f', 4, 64) }
+		return sendCSV(c, "summary.csv",
+			[]string{"from", "to", "currency", "orders", "paid_orders", "units", "revenue", "discounts", "tax", "shipping",
+				"average_order_value", "payment_success_rate", "cancellation_rate", "returns", "return_rate"},
+			[][]string{{
+				from.Format(reportService.DateLayout), to.Format(reportService.DateLayout), summary.Currency,
+				strconv.FormatInt(summary.Orders, 10), strconv.FormatInt(summary.PaidOrders, 10), strconv.FormatInt(summary.Units, 10),
+				money(summary.Revenue), money(summary.Discounts), money(summary.Tax), money(summary.Shipping),
+				money(summary.AverageOrderValue), rate(summary.PaymentSuccessRate), rate(summary.CancellationRate),
+				strconv.FormatInt(summary.Returns, 10), rate(summary.ReturnRate),
+			}})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Summary report",
+		Result: &fiber.Map{
+			"from":    from.Format(reportService.DateLayout),
+			"to":      to.Format(reportService.DateLayout),
+			"summary": summary,
+		},
+	})
+}
+
+// Only for admin. Rebuilds the daily rollups of a date range, for example
+// after correcting old orders. Recent days are rebuilt by the rollup job.
+func RebuildRollups(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	from, to, status, message := dateRange(c)
+	if status != fiber.StatusOK {
+		return badRequest(c, message)
+	}
+
+	days, err := reportService.RollupRange(ctx, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error rebuilding rollups",
+			Result: &fiber.Map{
+				"days": days,
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Rollups rebuilt",
+		Result: &fiber.Map{
+			"days": days,
+		},
+	})
+}
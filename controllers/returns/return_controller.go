@@ -0,0 +1,386 @@
+package returnController
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	orderService "fiber-mongo-api/services/orders"
+	returnService "fiber-mongo-api/services/returns"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var returnCollection *mongo.Collection = configs.GetCollection(configs.DB, "returns")
+
+var paymentProvider = orderService.NewRazorpayProvider()
+
+type ReturnItemRequest struct {
+	ProductID    string `json:"productId" validate:"required"`
+	Size         string `json:"size"`
+	Quantity     int    `json:"quantity" validate:"required,min=1"`
+	ExchangeSize string `json:"exchangeSize"` // Required for exchanges
+}
+
+type CreateReturnRequest struct {
+	Type   string              `json:"type" validate:"required"` // return or exchange
+	Reason string              `json:"reason" validate:"required"`
+	Photos []string            `json:"photos"`
+	Items  []ReturnItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type ReturnActionRequest struct {
+	Note       string `json:"note"`
+	Reason     string `json:"reason"`     // Required to reject
+	Resolution string `json:"resolution"` // On receipt: refund or exchange
+}
+
+type PickupRequest struct {
+	ScheduledFor time.Time `json:"scheduledFor" validate:"required"`
+	Carrier      string    `json:"carrier"`
+	AWB          string    `json:"awb"`
+	Note         string    `json:"note"`
+}
+
+// returnError maps return service errors to responses.
+func returnError(c *fiber.Ctx, err error) error {
+	status, message := fiber.StatusInternalServerError, "Error updating return"
+	switch err {
+	case returnService.ErrOrderNotFound, returnService.ErrReturnNotFound:
+		status, message = fiber.StatusNotFound, err.Error()
+	case returnService.ErrInvalidType, returnService.ErrNoItems, returnService.ErrInvalidItem,
+		returnService.ErrExchangeSize, returnService.ErrReasonRequired, returnService.ErrTooManyPhotos,
+		returnService.ErrInvalidPhoto, returnService.ErrInvalidResolution:
+		status, message = fiber.StatusBadRequest, err.Error()
+	case returnService.ErrNotDelivered, returnService.ErrWindowClosed, returnService.ErrInvalidTransition:
+		status, message = fiber.StatusConflict, err.Error()
+	case orderService.ErrOutOfStock:
+		status, message = fiber.StatusConflict, "Exchange size is out of stock, receive the return with a refund instead"
+	case orderService.ErrNotRefundable, orderService.ErrRefundExceedsPaid:
+		status, message = fiber.StatusConflict, err.Error()
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+func userObjectID(c *fiber.Ctx) (primitive.ObjectID, bool) {
+	userId, _ := c.Locals("userId").(string)
+	id, err := primitive.ObjectIDFromHex(userId)
+	return id, err == nil
+}
+
+// CreateReturn requests a return or size exchange of items of a delivered
+// order.
+func CreateReturn(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+			Result:  nil,
+		})
+	}
+
+	orderID, err := primitive.ObjectIDFromHex(c.Query("orderId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID format",
+			Result:  nil,
+		})
+	}
+
+	var request CreateReturnRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing return data",
+			Result:  nil,
+		})
+	}
+
+	input := returnService.RequestInput{
+		Type:   strings.ToLower(strings.TrimSpace(request.Type)),
+		Reason: request.Reason,
+		Photos: request.Photos,
+	}
+	for _, item := range request.Items {
+		productID, err := primitive.ObjectIDFromHex(item.ProductID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid product ID format",
+				Result:  nil,
+			})
+		}
+		input.Items = append(input.Items, returnService.ItemRequest{
+			ProductID:    productID,
+			Size:         item.Size,
+			Quantity:     item.Quantity,
+			ExchangeSize: item.ExchangeSize,
+		})
+	}
+
+	ret, err := returnService.Create(ctx, userID, orderID, input)
+	if err != nil {
+		return returnError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(responses.UserResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Return requested successfully",
+		Result: &fiber.Map{
+			"return": ret,
+		},
+	})
+}
+
+// listReturns writes a page of the returns matching filter, newest first.
+func listReturns(c *fiber.Ctx, ctx context.Context, filter bson.M) error {
+	page, err := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.Query("limit", "10"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	skip := (page - 1) * limit
+
+	totalReturns, err := returnCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error counting returns",
+			Result:  nil,
+		})
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(skip)
+	findOptions.SetLimit(limit)
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	returns := []models.Return{}
+	cursor, err := returnCollection.Find(ctx, filter, findOptions)
+	if err == nil {
+		err = cursor.All(ctx, &returns)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching returns",
+			Result:  nil,
+		})
+	}
+
+	totalPages := (totalReturns + limit - 1) / limit
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched returns",
+		Result: &fiber.Map{
+			"currentPage":  page,
+			"totalPages":   totalPages,
+			"totalReturns": totalReturns,
+			"returns":      returns,
+		},
+	})
+}
+
+// GetReturns lists the user's returns, optionally for one order.
+func GetReturns(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+			Result:  nil,
+		})
+	}
+
+	filter := bson.M{"userId": userID}
+	if orderId := c.Query("orderId"); orderId != "" {
+		orderID, err := primitive.ObjectIDFromHex(orderId)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid order ID format",
+				Result:  nil,
+			})
+		}
+		filter["orderId"] = orderID
+	}
+	return listReturns(c, ctx, filter)
+}
+
+// CancelReturn withdraws the user's return request before pickup.
+func CancelReturn(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+			Result:  nil,
+		})
+	}
+
+	returnID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid return ID format",
+			Result:  nil,
+		})
+	}
+
+	ret, err := returnService.Cancel(ctx, userID, returnID)
+	if err != nil {
+		return returnError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Return cancelled",
+		Result: &fiber.Map{
+			"return": ret,
+		},
+	})
+}
+
+// Only for admin. Lists returns, optionally by status.
+func GetAllReturns(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	return listReturns(c, ctx, filter)
+}
+
+// updateReturn parses the return ID and action body, and runs the admin
+// action on the return.
+func updateReturn(c *fiber.Ctx, message string, action func(ctx context.Context, id primitive.ObjectID, request ReturnActionRequest) (models.Return, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	returnID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid return ID format",
+			Result:  nil,
+		})
+	}
+
+	var request ReturnActionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Error parsing request",
+				Result:  nil,
+			})
+		}
+	}
+
+	ret, err := action(ctx, returnID, request)
+	if err != nil {
+		return returnError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Result: &fiber.Map{
+			"return": ret,
+		},
+	})
+}
+
+// Only for admin
+func ApproveReturn(c *fiber.Ctx) error {
+	return updateReturn(c, "Return approved", func(ctx context.Context, id primitive.ObjectID, request ReturnActionRequest) (models.Return, error) {
+		return returnService.Approve(ctx, id, request.Note)
+	})
+}
+
+// Only for admin. The reason is shown to the customer.
+func RejectReturn(c *fiber.Ctx) error {
+	return updateReturn(c, "Return rejected", func(ctx context.Context, id primitive.ObjectID, request ReturnActionRequest) (models.Return, error) {
+		if strings.TrimSpace(request.Reason) == "" {
+			return models.Return{}, returnService.ErrReasonRequired
+		}
+		return returnService.Reject(ctx, id, request.Reason)
+	})
+}
+
+// Only for admin. Records that the items arrived, restocks them and refunds
+// or exchanges them; resolution overrides the request type.
+func ReceiveReturn(c *fiber.Ctx) error {
+	return updateReturn(c, "Return received", func(ctx context.Context, id primitive.ObjectID, request ReturnActionRequest) (models.Return, error) {
+		return returnService.Receive(ctx, paymentProvider, id, strings.ToLower(request.Resolution), request.Note)
+	})
+}
+
+// Only for admin. Schedules the pickup of an approved return.
+func SchedulePickup(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	returnID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid return ID format",
+			Result:  nil,
+		})
+	}
+
+	var request PickupRequest
+	if err := c.BodyParser(&request); err != nil || request.ScheduledFor.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "scheduledFor is required",
+			Result:  nil,
+		})
+	}
+
+	ret, err := returnService.SchedulePickup(ctx, returnID, models.ReturnPickup{
+		ScheduledFor: request.ScheduledFor,
+		Carrier:      request.Carrier,
+		AWB:          request.AWB,
+	}, request.Note)
+	if err != nil {
+		return returnError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Pickup scheduled",
+		Result: &fiber.Map{
+			"return": ret,
+		},
+	})
+}
@@ -0,0 +1,439 @@
+package reviewController
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	reviewService "fiber-mongo-api/services/reviews"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReviewRequest struct {
+	Rating int      `json:"rating" validate:"required,min=1,max=5"`
+	Title  string   `json:"title"`
+	Text   string   `json:"text"`
+	Photos []string `json:"photos"`
+}
+
+type ReportRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+type ModerationRequest struct {
+	Note string `json:"note"`
+}
+
+// reviewError maps review service errors to responses.
+func reviewError(c *fiber.Ctx, err error) error {
+	status, message := fiber.StatusInternalServerError, "Error updating review"
+	switch err {
+	case reviewService.ErrProductNotFound, reviewService.ErrReviewNotFound:
+		status, message = fiber.StatusNotFound, err.Error()
+	case reviewService.ErrInvalidRating, reviewService.ErrTitleTooLong, reviewService.ErrTextTooLong,
+		reviewService.ErrTooManyPhotos, reviewService.ErrInvalidPhoto, reviewService.ErrReasonRequired,
+		reviewService.ErrInvalidSort, reviewService.ErrInvalidStatus:
+		status, message = fiber.StatusBadRequest, err.Error()
+	case reviewService.ErrNotPurchased, reviewService.ErrOwnReview:
+		status, message = fiber.StatusForbidden, err.Error()
+	case reviewService.ErrAlreadyReviewed, reviewService.ErrAlreadyReported, reviewService.ErrNotVisible:
+		status, message = fiber.StatusConflict, err.Error()
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+func userObjectID(c *fiber.Ctx) (primitive.ObjectID, bool) {
+	userId, _ := c.Locals("userId").(string)
+	id, err := primitive.ObjectIDFromHex(userId)
+	return id, err == nil
+}
+
+func unauthorized(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+		Status:  fiber.StatusUnauthorized,
+		Message: "User ID not found in token",
+		Result:  nil,
+	})
+}
+
+func invalidID(c *fiber.Ctx, what string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+		Status:  fiber.StatusBadRequest,
+		Message: "Invalid " + what + " ID format",
+		Result:  nil,
+	})
+}
+
+func pagination(c *fiber.Ctx) (page, limit int64) {
+	page, err := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err = strconv.ParseInt(c.Query("limit", "10"), 10, 64)
+	if err != nil || limit < 1 || limit > 50 {
+		limit = 10
+	}
+	return page, limit
+}
+
+// reviewPage writes a page of reviews.
+func reviewPage(c *fiber.Ctx, reviews []models.Review, total, page, limit int64) error {
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched reviews",
+		Result: &fiber.Map{
+			"currentPage":  page,
+			"totalPages":   (total + limit - 1) / limit,
+			"totalReviews": total,
+			"reviews":      reviews,
+		},
+	})
+}
+
+func reviewInput(request ReviewRequest) reviewService.Input {
+	return reviewService.Input{
+		Rating: request.Rating,
+		Title:  request.Title,
+		Text:   request.Text,
+		Photos: request.Photos,
+	}
+}
+
+// GetProductReviews lists a product's published reviews, sorted by sort
+// (newest, oldest, helpful, rating_desc or rating_asc) and optionally only
+// those with stars stars.
+func GetProductReviews(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Query("productId"))
+	if err != nil {
+		return invalidID(c, "product")
+	}
+	stars := 0
+	if s := c.Query("stars"); s != "" {
+		if stars, err = strconv.Atoi(s); err != nil {
+			return reviewError(c, reviewService.ErrInvalidRating)
+		}
+	}
+	page, limit := pagination(c)
+
+	reviews, total, err := reviewService.ForProduct(ctx, productID, c.Query("sort", "newest"), stars, page, limit)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return reviewPage(c, reviews, total, page, limit)
+}
+
+// GetMyReviews lists the user's own reviews, including those awaiting
+// moderation.
+func GetMyReviews(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	page, limit := pagination(c)
+
+	reviews, total, err := reviewService.ForUser(ctx, userID, page, limit)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return reviewPage(c, reviews, total, page, limit)
+}
+
+// CreateReview reviews a product the user received. It is published once a
+// moderator approves it.
+func CreateReview(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	productID, err := primitive.ObjectIDFromHex(c.Query("productId"))
+	if err != nil {
+		return invalidID(c, "product")
+	}
+
+	var request ReviewRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing review",
+			Result:  nil,
+		})
+	}
+
+	review, err := reviewService.Create(ctx, userID, productID, reviewInput(request))
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(responses.UserResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Review submitted for moderation",
+		Result: &fiber.Map{
+			"review": review,
+		},
+	})
+}
+
+// UpdateReview edits the user's review, which goes back to moderation.
+func UpdateReview(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	reviewID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "review")
+	}
+
+	var request ReviewRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing review",
+			Result:  nil,
+		})
+	}
+
+	review, err := reviewService.Update(ctx, userID, reviewID, reviewInput(request))
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Review updated and submitted for moderation",
+		Result: &fiber.Map{
+			"review": review,
+		},
+	})
+}
+
+// DeleteReview removes the user's review.
+func DeleteReview(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	reviewID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "review")
+	}
+
+	if err := reviewService.Delete(ctx, userID, reviewID); err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Review deleted",
+		Result:  nil,
+	})
+}
+
+// vote marks a review helpful, or takes the vote back.
+func vote(c *fiber.Ctx, helpful bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	reviewID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "review")
+	}
+
+	review, err := reviewService.Vote(ctx, userID, reviewID, helpful)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Vote recorded",
+		Result: &fiber.Map{
+			"helpfulCount": review.HelpfulCount,
+		},
+	})
+}
+
+// MarkHelpful votes a review helpful.
+func MarkHelpful(c *fiber.Ctx) error {
+	return vote(c, true)
+}
+
+// UnmarkHelpful takes back the user's helpful vote.
+func UnmarkHelpful(c *fiber.Ctx) error {
+	return vote(c, false)
+}
+
+// ReportReview flags a review for moderators.
+func ReportReview(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	reviewID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "review")
+	}
+
+	var request ReportRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing report",
+			Result:  nil,
+		})
+	}
+
+	if _, err := reviewService.Report(ctx, userID, reviewID, request.Reason); err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Review reported",
+		Result:  nil,
+	})
+}
+
+// Only for admin. The moderation queue: reviews in status (pending by
+// default), most reported first.
+func GetReviewQueue(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	page, limit := pagination(c)
+	reviews, total, err := reviewService.Queue(ctx, c.Query("status", models.ReviewPending), page, limit)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return reviewPage(c, reviews, total, page, limit)
+}
+
+// Only for admin
+func GetReviewReports(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reviewID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "review")
+	}
+
+	reports, err := reviewService.Reports(ctx, reviewID)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched reports",
+		Result: &fiber.Map{
+			"reports": reports,
+		},
+	})
+}
+
+// moderate approves or rejects a review.
+func moderate(c *fiber.Ctx, approve bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	adminID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	reviewID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidID(c, "review")
+	}
+
+	var request ModerationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Error parsing moderation note",
+				Result:  nil,
+			})
+		}
+	}
+
+	review, err := reviewService.Moderate(ctx, adminID, reviewID, approve, request.Note)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	message := "Review rejected"
+	if approve {
+		message = "Review approved"
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Result: &fiber.Map{
+			"review": review,
+		},
+	})
+}
+
+// Only for admin. Publishes a review.
+func ApproveReview(c *fiber.Ctx) error {
+	return moderate(c, true)
+}
+
+// Only for admin. Hides a review, with an optional note.
+func RejectReview(c *fiber.Ctx) error {
+	return moderate(c, false)
+}
+
+// Only for admin. Rebuilds a product's rating from its published reviews.
+func RecountRating(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Query("productId"))
+	if err != nil {
+		return invalidID(c, "product")
+	}
+
+	rating, err := reviewService.Recount(ctx, productID)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Rating recounted",
+		Result: &fiber.Map{
+			"rating": reviewService.Summary(&rating),
+		},
+	})
+}
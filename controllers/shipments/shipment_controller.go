@@ -0,0 +1,225 @@
+package shipmentController
+
+import (
+	"context"
+	"fiber-mongo-api/responses"
+	shipmentService "fiber-mongo-api/services/shipments"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ShipmentRequest struct {
+	Carrier     string `json:"carrier" validate:"required"`
+	AWB         string `json:"awb" validate:"required"`
+	TrackingURL string `json:"trackingUrl"`
+}
+
+type TrackingRequest struct {
+	Carrier     string    `json:"carrier" validate:"required"`
+	AWB         string    `json:"awb" validate:"required"`
+	Status      string    `json:"status" validate:"required"`
+	Location    string    `json:"location"`
+	Description string    `json:"description"`
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+// Only for admin. Creates a shipment for the order with the carrier's AWB.
+func CreateShipment(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderID, err := primitive.ObjectIDFromHex(c.Query("orderId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID",
+			Result:  nil,
+		})
+	}
+
+	var request ShipmentRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing shipment data",
+			Result:  nil,
+		})
+	}
+	if strings.TrimSpace(request.Carrier) == "" || strings.TrimSpace(request.AWB) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Carrier and AWB are required",
+			Result:  nil,
+		})
+	}
+
+	shipment, err := shipmentService.Create(ctx, orderID, request.Carrier, request.AWB, request.TrackingURL)
+	if err != nil {
+		status, message := fiber.StatusInternalServerError, "Error creating shipment"
+		switch err {
+		case shipmentService.ErrOrderNotFound:
+			status, message = fiber.StatusNotFound, "Order not found"
+		case shipmentService.ErrOrderNotShippable, shipmentService.ErrDuplicateAWB:
+			status, message = fiber.StatusConflict, err.Error()
+		}
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(responses.UserResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Shipment created successfully",
+		Result: &fiber.Map{
+			"shipment": shipment,
+		},
+	})
+}
+
+// Only for admin. Lists the shipments of an order.
+func GetShipments(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	orderID, err := primitive.ObjectIDFromHex(c.Query("orderId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid order ID",
+			Result:  nil,
+		})
+	}
+
+	shipments, err := shipmentService.ForOrder(ctx, orderID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching shipments",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched shipments",
+		Result: &fiber.Map{
+			"shipments": shipments,
+		},
+	})
+}
+
+// Only for admin. Posts a tracking update by hand, for carriers without a
+// webhook.
+func AddTrackingEvent(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request TrackingRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing tracking data",
+			Result:  nil,
+		})
+	}
+	if request.Carrier == "" || request.AWB == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Carrier and AWB are required",
+			Result:  nil,
+		})
+	}
+
+	shipment, err := shipmentService.Track(ctx, request.Carrier, shipmentService.TrackingUpdate{
+		AWB:         request.AWB,
+		Status:      request.Status,
+		Location:    request.Location,
+		Description: request.Description,
+		OccurredAt:  request.OccurredAt,
+	})
+	if err != nil {
+		status, message := fiber.StatusInternalServerError, "Error saving tracking update"
+		switch err {
+		case shipmentService.ErrInvalidStatus:
+			status, message = fiber.StatusBadRequest, err.Error()
+		case shipmentService.ErrShipmentNotFound:
+			status, message = fiber.StatusNotFound, "Shipment not found"
+		}
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Tracking update saved",
+		Result: &fiber.Map{
+			"shipment": shipment,
+		},
+	})
+}
+
+// CarrierWebhook takes tracking updates pushed by a registered carrier.
+// Updates for unknown AWBs or with unknown statuses are skipped so the
+// carrier does not retry them forever; other failures return 500 so it does.
+func CarrierWebhook(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	carrier, err := shipmentService.Lookup(c.Params("carrier"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Unknown carrier",
+			Result:  nil,
+		})
+	}
+
+	updates, err := carrier.ParseWebhook(func(key string) string { return c.Get(key) }, c.Body())
+	if err != nil {
+		status, message := fiber.StatusBadRequest, "Invalid webhook payload"
+		if err == shipmentService.ErrInvalidSignature {
+			status, message = fiber.StatusUnauthorized, "Invalid webhook signature"
+		}
+		return c.Status(status).JSON(responses.UserResponse{
+			Status:  status,
+			Message: message,
+			Result:  nil,
+		})
+	}
+
+	applied, skipped := 0, 0
+	for _, update := range updates {
+		_, err := shipmentService.Track(ctx, carrier.Name(), update)
+		switch err {
+		case nil:
+			applied++
+		case shipmentService.ErrShipmentNotFound, shipmentService.ErrInvalidStatus:
+			log.Printf("carrier webhook: %s: skipping update for AWB %q: %v", carrier.Name(), update.AWB, err)
+			skipped++
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Error saving tracking updates",
+				Result:  nil,
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Tracking updates received",
+		Result: &fiber.Map{
+			"applied": applied,
+			"skipped": skipped,
+		},
+	})
+}
@@ -0,0 +1,357 @@
+package shippingController
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	shippingService "fiber-mongo-api/services/shipping"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var zoneCollection *mongo.Collection = configs.GetCollection(configs.DB, "shippingZones")
+var pinCodeCollection *mongo.Collection = configs.GetCollection(configs.DB, "pinCodeZones")
+
+type ShippingZoneRequest struct {
+	Code                  string  `json:"code" validate:"required"`
+	Name                  string  `json:"name"`
+	BaseRate              float64 `json:"baseRate" validate:"min=0"`
+	BaseWeightGrams       int     `json:"baseWeightGrams" validate:"min=0"`
+	AdditionalRatePerKg   float64 `json:"additionalRatePerKg" validate:"min=0"`
+	FreeShippingThreshold float64 `json:"freeShippingThreshold" validate:"min=0"`
+	MinDays               int     `json:"minDays" validate:"min=0"`
+	MaxDays               int     `json:"maxDays" validate:"min=0"`
+}
+
+type PinCodeRequest struct {
+	PinCode      string `json:"pinCode" validate:"required"`
+	ZoneCode     string `json:"zoneCode" validate:"required"`
+	Serviceable  bool   `json:"serviceable"`
+	CODAvailable bool   `json:"codAvailable"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+}
+
+// Only for admin. Creates the zone with the given code or replaces its rates.
+func UpsertShippingZone(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request ShippingZoneRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing shipping zone data",
+			Result:  nil,
+		})
+	}
+
+	request.Code = strings.ToUpper(strings.TrimSpace(request.Code))
+	if request.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Zone code is required",
+			Result:  nil,
+		})
+	}
+
+	if request.BaseRate < 0 || request.BaseWeightGrams < 0 || request.AdditionalRatePerKg < 0 || request.FreeShippingThreshold < 0 || request.MinDays < 0 || request.MaxDays < request.MinDays {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Rates cannot be negative and maxDays cannot be less than minDays",
+			Result:  nil,
+		})
+	}
+
+	var zone models.ShippingZone
+	err := zoneCollection.FindOneAndUpdate(ctx, bson.M{"code": request.Code},
+		bson.M{
+			"$set": bson.M{
+				"name":                  request.Name,
+				"baseRate":              request.BaseRate,
+				"baseWeightGrams":       request.BaseWeightGrams,
+				"additionalRatePerKg":   request.AdditionalRatePerKg,
+				"freeShippingThreshold": request.FreeShippingThreshold,
+				"minDays":               request.MinDays,
+				"maxDays":               request.MaxDays,
+				"updatedAt":             time.Now(),
+			},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&zone)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error saving shipping zone",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Shipping zone saved successfully",
+		Result: &fiber.Map{
+			"zone": zone,
+		},
+	})
+}
+
+// Only for admin
+func GetShippingZones(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var zones []models.ShippingZone
+	cursor, err := zoneCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "code", Value: 1}}))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching shipping zones",
+			Result:  nil,
+		})
+	}
+	if err = cursor.All(ctx, &zones); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error parsing shipping zones",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched shipping zones",
+		Result: &fiber.Map{
+			"defaultWeightGrams": configs.EnvShippingDefaultWeightGrams(),
+			"processingDays":     configs.EnvShippingProcessingDays(),
+			"zones":              zones,
+		},
+	})
+}
+
+// Only for admin. Accepts a list of entries so a zone table can be loaded in
+// one call. Each entry is a full PIN code or a three digit prefix covering
+// every PIN code that starts with it.
+func UpsertPinCodes(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var requests []PinCodeRequest
+	if err := c.BodyParser(&requests); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing PIN code data",
+			Result:  nil,
+		})
+	}
+
+	if len(requests) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "No PIN codes provided",
+			Result:  nil,
+		})
+	}
+
+	zoneCodes := make(map[string]bool)
+	for i := range requests {
+		requests[i].PinCode = shippingService.NormalizePinCode(requests[i].PinCode)
+		requests[i].ZoneCode = strings.ToUpper(strings.TrimSpace(requests[i].ZoneCode))
+		if !shippingService.ValidPinCodeEntry(requests[i].PinCode) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid PIN code " + requests[i].PinCode,
+				Result:  nil,
+			})
+		}
+		zoneCodes[requests[i].ZoneCode] = true
+	}
+
+	// Every entry must point at a zone that exists, otherwise it could never
+	// be priced
+	codes := make([]string, 0, len(zoneCodes))
+	for code := range zoneCodes {
+		codes = append(codes, code)
+	}
+	known, err := zoneCollection.CountDocuments(ctx, bson.M{"code": bson.M{"$in": codes}})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching shipping zones",
+			Result:  nil,
+		})
+	}
+	if known != int64(len(codes)) {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: shippingService.ErrZoneNotFound.Error(),
+			Result:  nil,
+		})
+	}
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(requests))
+	for _, request := range requests {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"pinCode": request.PinCode}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					"zoneCode":     request.ZoneCode,
+					"serviceable":  request.Serviceable,
+					"codAvailable": request.CODAvailable,
+					"city":         request.City,
+					"state":        request.State,
+					"updatedAt":    now,
+				},
+				"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+			}).
+			SetUpsert(true))
+	}
+
+	result, err := pinCodeCollection.BulkWrite(ctx, writes)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error saving PIN codes",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "PIN codes saved successfully",
+		Result: &fiber.Map{
+			"inserted": result.UpsertedCount,
+			"updated":  result.ModifiedCount,
+		},
+	})
+}
+
+// Only for admin
+func GetPinCodes(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pageStr := c.Query("page", "1")
+	limitStr := c.Query("limit", "50")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil || limit < 1 {
+		limit = 50
+	}
+
+	skip := (page - 1) * limit
+
+	filter := bson.M{}
+	if zoneCode := c.Query("zoneCode"); zoneCode != "" {
+		filter["zoneCode"] = strings.ToUpper(zoneCode)
+	}
+	// A full PIN code also matches the prefix entry that may be serving it
+	if pinCode := shippingService.NormalizePinCode(c.Query("pinCode")); pinCode != "" {
+		if !shippingService.ValidPinCodeEntry(pinCode) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: shippingService.ErrInvalidPinCode.Error(),
+				Result:  nil,
+			})
+		}
+		filter["pinCode"] = bson.M{"$in": []string{pinCode, pinCode[:3]}}
+	}
+
+	totalPinCodes, err := pinCodeCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error counting PIN codes",
+			Result:  nil,
+		})
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(skip)
+	findOptions.SetLimit(limit)
+	findOptions.SetSort(bson.D{{Key: "pinCode", Value: 1}})
+
+	var pinCodes []models.PinCodeZone
+	cursor, err := pinCodeCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching PIN codes",
+			Result:  nil,
+		})
+	}
+	if err = cursor.All(ctx, &pinCodes); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error parsing PIN codes",
+			Result:  nil,
+		})
+	}
+
+	totalPages := (totalPinCodes + limit - 1) / limit
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched PIN codes",
+		Result: &fiber.Map{
+			"currentPage":   page,
+			"totalPages":    totalPages,
+			"totalPinCodes": totalPinCodes,
+			"pinCodes":      pinCodes,
+		},
+	})
+}
+
+// Only for admin. Deleting an entry makes its PIN codes unserviceable unless
+// a prefix entry still covers them.
+func DeletePinCode(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pinCode := shippingService.NormalizePinCode(c.Query("pinCode"))
+	if !shippingService.ValidPinCodeEntry(pinCode) {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: shippingService.ErrInvalidPinCode.Error(),
+			Result:  nil,
+		})
+	}
+
+	result, err := pinCodeCollection.DeleteOne(ctx, bson.M{"pinCode": pinCode})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error deleting PIN code",
+			Result:  nil,
+		})
+	}
+
+	if result.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "PIN code not found",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "PIN code deleted successfully",
+		Result:  nil,
+	})
+}
@@ -0,0 +1,178 @@
+package stockController
+
+import (
+	"context"
+	"fiber-mongo-api/responses"
+	stockService "fiber-mongo-api/services/stock"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type StockAlertRequest struct {
+	ProductID string `json:"id" validate:"required"`
+}
+
+type StockRequest struct {
+	Quantity *int `json:"quantity" validate:"required,min=0"`
+}
+
+// stockError maps stock service errors to responses.
+func stockError(c *fiber.Ctx, err error) error {
+	status, message := fiber.StatusInternalServerError, "Error updating stock"
+	switch err {
+	case stockService.ErrProductNotFound, stockService.ErrSubscriptionNotFound:
+		status, message = fiber.StatusNotFound, err.Error()
+	case stockService.ErrInvalidQuantity:
+		status, message = fiber.StatusBadRequest, err.Error()
+	case stockService.ErrInStock:
+		status, message = fiber.StatusConflict, err.Error()
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+func userObjectID(c *fiber.Ctx) (primitive.ObjectID, bool) {
+	userId, _ := c.Locals("userId").(string)
+	id, err := primitive.ObjectIDFromHex(userId)
+	return id, err == nil
+}
+
+func unauthorized(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+		Status:  fiber.StatusUnauthorized,
+		Message: "User ID not found in token",
+		Result:  nil,
+	})
+}
+
+// parseAlert reads the product of a stock alert from the body.
+func parseAlert(c *fiber.Ctx) (primitive.ObjectID, bool) {
+	var request StockAlertRequest
+	if err := c.BodyParser(&request); err != nil {
+		return primitive.NilObjectID, false
+	}
+	productID, err := primitive.ObjectIDFromHex(request.ProductID)
+	return productID, err == nil
+}
+
+func invalidProduct(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+		Status:  fiber.StatusBadRequest,
+		Message: "Invalid product Id",
+		Result:  nil,
+	})
+}
+
+// GetStockAlerts lists the user's back-in-stock subscriptions, newest
+// first, with each product as it is now.
+func GetStockAlerts(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+
+	entries, err := stockService.List(ctx, userID)
+	if err != nil {
+		return stockError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched stock alerts",
+		Result: &fiber.Map{
+			"alerts": entries,
+			"count":  len(entries),
+		},
+	})
+}
+
+// SubscribeStockAlert asks to be notified when an out of stock product is
+// back.
+func SubscribeStockAlert(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	productID, ok := parseAlert(c)
+	if !ok {
+		return invalidProduct(c)
+	}
+
+	subscription, err := stockService.Subscribe(ctx, userID, productID)
+	if err != nil {
+		return stockError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "You will be notified when this is back in stock",
+		Result: &fiber.Map{
+			"alert": subscription,
+		},
+	})
+}
+
+// UnsubscribeStockAlert stops a back-in-stock subscription.
+func UnsubscribeStockAlert(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	productID, ok := parseAlert(c)
+	if !ok {
+		return invalidProduct(c)
+	}
+
+	if err := stockService.Unsubscribe(ctx, userID, productID); err != nil {
+		return stockError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Stock alert removed",
+		Result:  nil,
+	})
+}
+
+// Only for admin. Sets a product's quantity on hand; bringing an out of
+// stock product back notifies its subscribers.
+func UpdateStock(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	productID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return invalidProduct(c)
+	}
+	var request StockRequest
+	if err := c.BodyParser(&request); err != nil || request.Quantity == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body, quantity is required",
+			Result:  nil,
+		})
+	}
+
+	product, err := stockService.SetStock(ctx, productID, *request.Quantity)
+	if err != nil {
+		return stockError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Stock updated",
+		Result: &fiber.Map{
+			"product": product,
+		},
+	})
+}
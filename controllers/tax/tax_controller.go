@@ -0,0 +1,174 @@
+package taxController
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var taxRateCollection *mongo.Collection = configs.GetCollection(configs.DB, "taxRates")
+
+type TaxRateRequest struct {
+	HSNCode            string  `json:"hsnCode"`
+	Category           string  `json:"category"`
+	Description        string  `json:"description"`
+	Rate               float64 `json:"rate" validate:"min=0,max=100"`
+	ThresholdPrice     float64 `json:"thresholdPrice"`
+	RateAboveThreshold float64 `json:"rateAboveThreshold"`
+}
+
+// Only for admin. Creates the rate for an HSN code or category, or replaces
+// the existing one.
+func UpsertTaxRate(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request TaxRateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Error parsing tax rate data",
+			Result:  nil,
+		})
+	}
+
+	request.HSNCode = strings.TrimSpace(request.HSNCode)
+	request.Category = strings.TrimSpace(request.Category)
+	if (request.HSNCode == "") == (request.Category == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Specify either hsnCode or category",
+			Result:  nil,
+		})
+	}
+
+	if request.Rate < 0 || request.Rate > 100 || request.RateAboveThreshold < 0 || request.RateAboveThreshold > 100 || request.ThresholdPrice < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Rates must be between 0 and 100",
+			Result:  nil,
+		})
+	}
+
+	filter := bson.M{"hsnCode": request.HSNCode}
+	if request.HSNCode == "" {
+		filter = bson.M{"category": request.Category}
+	}
+
+	set := bson.M{
+		"description":        request.Description,
+		"rate":               request.Rate,
+		"thresholdPrice":     request.ThresholdPrice,
+		"rateAboveThreshold": request.RateAboveThreshold,
+		"updatedAt":          time.Now(),
+	}
+	if request.HSNCode != "" {
+		set["hsnCode"] = request.HSNCode
+	} else {
+		set["category"] = request.Category
+	}
+
+	var taxRate models.TaxRate
+	err := taxRateCollection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": set, "$setOnInsert": bson.M{"_id": primitive.NewObjectID()}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&taxRate)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error saving tax rate",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Tax rate saved successfully",
+		Result: &fiber.Map{
+			"taxRate": taxRate,
+		},
+	})
+}
+
+// Only for admin
+func GetTaxRates(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "hsnCode", Value: 1}, {Key: "category", Value: 1}})
+
+	var taxRates []models.TaxRate
+	cursor, err := taxRateCollection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching tax rates",
+			Result:  nil,
+		})
+	}
+	if err = cursor.All(ctx, &taxRates); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error parsing tax rates",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fetched tax rates",
+		Result: &fiber.Map{
+			"originState": configs.EnvGSTOriginState(),
+			"defaultRate": configs.EnvGSTDefaultRate(),
+			"taxRates":    taxRates,
+		},
+	})
+}
+
+// Only for admin. Products covered by the deleted rate fall back to their
+// category rate or the default rate.
+func DeleteTaxRate(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	taxRateObjId, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid tax rate ID format",
+			Result:  nil,
+		})
+	}
+
+	result, err := taxRateCollection.DeleteOne(ctx, bson.M{"_id": taxRateObjId})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error deleting tax rate",
+			Result:  nil,
+		})
+	}
+
+	if result.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(responses.UserResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Tax rate not found",
+			Result:  nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Tax rate deleted successfully",
+		Result:  nil,
+	})
+}
@@ -7,6 +7,8 @@ import (
 	"fiber-mongo-api/configs"
 	"fiber-mongo-api/models"
 	"fiber-mongo-api/responses"
+	cartService "fiber-mongo-api/services/cart"
+	"log"
 	"net/http"
 	"os"
 	"regexp"
@@ -197,6 +199,9 @@ func UserSignIn(c *fiber.Ctx) error {
 		})
 	}
 
+	// Carry over anything added to the cart before signing in
+	existingUser.Cart = mergeGuestCart(ctx, c, existingUser)
+
 	//Response
 	existingUser.Password = ""
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
@@ -227,6 +232,29 @@ func createJwt(userId string) (string, error) {
 	return token.SignedString([]byte(jwtSecret))
 }
 
+// mergeGuestCart merges the guest cart named by the X-Guest-Token header into
+// the user's cart and returns the resulting cart. A missing, invalid or
+// expired guest token, or a failed merge, never blocks sign-in; the user's
+// own cart is returned unchanged instead.
+func mergeGuestCart(ctx context.Context, c *fiber.Ctx, user models.User) []models.CartItem {
+	guestToken := c.Get(cartService.GuestTokenHeader)
+	if guestToken == "" {
+		return user.Cart
+	}
+
+	guestCartID, err := cartService.ParseGuestToken(guestToken)
+	if err != nil {
+		return user.Cart
+	}
+
+	merged, err := cartService.MergeGuestCart(ctx, user.Id, guestCartID)
+	if err != nil {
+		log.Printf("failed to merge guest cart %s into user %s: %v", guestCartID.Hex(), user.Id.Hex(), err)
+		return user.Cart
+	}
+	return merged
+}
+
 func OAuthLogin(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -327,6 +355,9 @@ func OAuthLogin(c *fiber.Ctx) error {
 
 	}
 
+	// Carry over anything added to the cart before signing in
+	existingUser.Cart = mergeGuestCart(ctx, c, existingUser)
+
 	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
 		Status:  fiber.StatusOK,
 		Message: "User signed in successfully",
@@ -0,0 +1,231 @@
+package wishlistController
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	"fiber-mongo-api/services/sizes"
+	wishlistService "fiber-mongo-api/services/wishlist"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WishlistItemRequest struct {
+	ProductID string `json:"id" validate:"required"`
+	Size      string `json:"size"` // Empty for any size
+}
+
+// wishlistError maps wishlist service errors to responses.
+func wishlistError(c *fiber.Ctx, err error) error {
+	status, message := fiber.StatusInternalServerError, "Error updating wishlist"
+	switch err {
+	case wishlistService.ErrProductNotFound, wishlistService.ErrItemNotFound, wishlistService.ErrShareNotFound:
+		status, message = fiber.StatusNotFound, err.Error()
+	case sizes.ErrInvalid:
+		status, message = fiber.StatusBadRequest, err.Error()
+	case wishlistService.ErrWishlistFull:
+		status, message = fiber.StatusConflict, err.Error()
+	}
+	return c.Status(status).JSON(responses.UserResponse{
+		Status:  status,
+		Message: message,
+		Result:  nil,
+	})
+}
+
+func userObjectID(c *fiber.Ctx) (primitive.ObjectID, bool) {
+	userId, _ := c.Locals("userId").(string)
+	id, err := primitive.ObjectIDFromHex(userId)
+	return id, err == nil
+}
+
+func unauthorized(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+		Status:  fiber.StatusUnauthorized,
+		Message: "User ID not found in token",
+		Result:  nil,
+	})
+}
+
+// parseItem reads the product and size of a wishlist item from the body.
+func parseItem(c *fiber.Ctx) (primitive.ObjectID, string, bool) {
+	var request WishlistItemRequest
+	if err := c.BodyParser(&request); err != nil {
+		return primitive.NilObjectID, "", false
+	}
+	productID, err := primitive.ObjectIDFromHex(request.ProductID)
+	return productID, request.Size, err == nil
+}
+
+// shareURL is the public link to a shared wishlist.
+func shareURL(c *fiber.Ctx, token string) string {
+	return c.BaseURL() + "/api/wishlist/shared?token=" + token
+}
+
+// sendWishlist writes the wishlist with its live products.
+func sendWishlist(c *fiber.Ctx, ctx context.Context, wishlist models.Wishlist, message string, owner bool) error {
+	entries, err := wishlistService.Entries(ctx, wishlist)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error fetching wishlist products",
+			Result:  nil,
+		})
+	}
+
+	result := fiber.Map{
+		"items": entries,
+		"count": len(entries),
+	}
+	if owner {
+		result["shared"] = wishlist.ShareToken != ""
+		if wishlist.ShareToken != "" {
+			result["shareUrl"] = shareURL(c, wishlist.ShareToken)
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Result:  &result,
+	})
+}
+
+// GetWishlist lists the user's wishlist, newest first, with each product as
+// it is now.
+func GetWishlist(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+
+	wishlist, err := wishlistService.Get(ctx, userID)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return sendWishlist(c, ctx, wishlist, "Fetched wishlist", true)
+}
+
+// AddToWishlist saves a product, in a size or any size, for later.
+func AddToWishlist(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	productID, size, ok := parseItem(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid product Id",
+			Result:  nil,
+		})
+	}
+
+	wishlist, err := wishlistService.Add(ctx, userID, productID, size)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Added to wishlist",
+		Result: &fiber.Map{
+			"count": len(wishlist.Items),
+		},
+	})
+}
+
+// RemoveFromWishlist takes a product in a size off the user's wishlist.
+func RemoveFromWishlist(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+	productID, size, ok := parseItem(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid product Id",
+			Result:  nil,
+		})
+	}
+
+	if err := wishlistService.Remove(ctx, userID, productID, size); err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Removed from wishlist",
+		Result:  nil,
+	})
+}
+
+// ShareWishlist returns a public link to the user's wishlist, creating it
+// if needed.
+func ShareWishlist(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+
+	token, err := wishlistService.Share(ctx, userID)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Wishlist shared",
+		Result: &fiber.Map{
+			"token":    token,
+			"shareUrl": shareURL(c, token),
+		},
+	})
+}
+
+// UnshareWishlist turns off the public link to the user's wishlist.
+func UnshareWishlist(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, ok := userObjectID(c)
+	if !ok {
+		return unauthorized(c)
+	}
+
+	if err := wishlistService.Unshare(ctx, userID); err != nil {
+		return wishlistError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.UserResponse{
+		Status:  fiber.StatusOK,
+		Message: "Wishlist is no longer shared",
+		Result:  nil,
+	})
+}
+
+// GetSharedWishlist shows a wishlist to anyone with its share link.
+func GetSharedWishlist(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	wishlist, err := wishlistService.Shared(ctx, c.Query("token"))
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return sendWishlist(c, ctx, wishlist, "Fetched shared wishlist", false)
+}
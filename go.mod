@@ -2,7 +2,10 @@ module fiber-mongo-api
 
 go 1.23.1
 
-require github.com/go-playground/validator v9.31.0+incompatible
+require (
+	github.com/go-playground/validator v9.31.0+incompatible
+	golang.org/x/image v0.18.0
+)
 
 require (
 	github.com/andybalholm/brotli v1.1.0 // indirect
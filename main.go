@@ -1,23 +1,197 @@
 package main
 
 import (
+	"context"
 	"fiber-mongo-api/configs"
 	"fiber-mongo-api/routes"
+	cartService "fiber-mongo-api/services/cart"
+	categoryService "fiber-mongo-api/services/categories"
+	currencyService "fiber-mongo-api/services/currency"
+	idempotencyService "fiber-mongo-api/services/idempotency"
+	invoiceService "fiber-mongo-api/services/invoices"
+	jobService "fiber-mongo-api/services/jobs"
+	mediaService "fiber-mongo-api/services/media"
+	merchandisingService "fiber-mongo-api/services/merchandising"
+	notificationService "fiber-mongo-api/services/notifications"
+	orderService "fiber-mongo-api/services/orders"
+	productService "fiber-mongo-api/services/products"
+	promotionService "fiber-mongo-api/services/promotions"
+	reportService "fiber-mongo-api/services/reports"
+	returnService "fiber-mongo-api/services/returns"
+	reviewService "fiber-mongo-api/services/reviews"
+	shipmentService "fiber-mongo-api/services/shipments"
+	shippingService "fiber-mongo-api/services/shipping"
+	stockService "fiber-mongo-api/services/stock"
+	taxService "fiber-mongo-api/services/tax"
+	wishlistService "fiber-mongo-api/services/wishlist"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func main() {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		BodyLimit: configs.EnvMaxBodyBytes(),
+	})
 
 	configs.ConnectDB()
 
+	// Checkout is only atomic on servers with transactions, so find out
+	// before taking orders
+	if err := configs.DetectTransactions(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Expire abandoned guest carts
+	if err := cartService.EnsureGuestCartIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := promotionService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := taxService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := shippingService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := currencyService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// Forget idempotency keys once they can no longer be replayed
+	if err := idempotencyService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := shipmentService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// Keep invoice numbers unique per financial year
+	if err := invoiceService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := returnService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := categoryService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := productService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := merchandisingService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := reviewService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := notificationService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := wishlistService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := stockService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := reportService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := jobService.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	// Accept tracking webhooks from the fake carrier in development
+	if secret := configs.EnvFakeCarrierSecret(); secret != "" {
+		shipmentService.Register(shipmentService.NewFakeCarrier(secret))
+	}
+
+	// Uploaded images. The app serves the local store itself unless its
+	// base URL points elsewhere, such as a CDN in front of the directory
+	blobStore, err := mediaService.NewStoreFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mediaService.SetStore(blobStore)
+	if local, ok := blobStore.(*mediaService.LocalStore); ok && strings.HasPrefix(local.BaseURL, "/") {
+		app.Static(local.BaseURL, local.Dir)
+	}
+
+	// Settle orders whose payment was never verified
+	jobService.Start(context.Background(), jobService.Job{
+		Name:     "reconcile-orders",
+		Interval: configs.EnvReconcileInterval(),
+		Timeout:  4 * time.Minute,
+		Run:      orderService.ReconcileJob(orderService.NewRazorpayProvider()),
+	}, jobService.Job{
+		// Keep the daily sales rollups behind the reports current
+		Name:     "sales-rollup",
+		Interval: configs.EnvReportRollupInterval(),
+		Timeout:  10 * time.Minute,
+		Run:      reportService.RollupJob(),
+	}, jobService.Job{
+		// Run product imports that were queued but never run, or abandoned
+		Name:     "product-imports",
+		Interval: time.Minute,
+		Timeout:  productService.ImportLease,
+		Run:      productService.ImportJob(),
+	}, jobService.Job{
+		// Tell shoppers when wishlisted items get cheaper or are restocked
+		Name:     "wishlist-alerts",
+		Interval: configs.EnvWishlistAlertInterval(),
+		Timeout:  10 * time.Minute,
+		Run:      wishlistService.AlertJob(),
+	}, jobService.Job{
+		// Catch back-in-stock subscribers missed when stock came back
+		Name:     "restock-alerts",
+		Interval: configs.EnvRestockAlertInterval(),
+		Timeout:  10 * time.Minute,
+		Run:      stockService.RestockJob(),
+	})
+
 	routes.CartRoutes(app)
 	routes.UserRoute(app)
 	routes.ProductsRoute(app)
 	routes.AccountRoute(app)
 	routes.AddressRoutes(app)
 	routes.OrderRoutes(app)
+	routes.PromotionRoutes(app)
+	routes.TaxRoutes(app)
+	routes.ShippingRoutes(app)
+	routes.CurrencyRoutes(app)
+	routes.ShipmentRoutes(app)
+	routes.ReturnRoutes(app)
+	routes.InvoiceRoutes(app)
+	routes.ReportRoutes(app)
+	routes.MediaRoutes(app)
+	routes.CategoryRoutes(app)
+	routes.MerchandisingRoutes(app)
+	routes.ReviewRoutes(app)
+	routes.NotificationRoutes(app)
+	routes.WishlistRoutes(app)
+	routes.StockRoutes(app)
+	routes.JobRoutes(app)
 
 	app.Listen(":3000")
 }
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/responses"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var userCollection *mongo.Collection = configs.GetCollection(configs.DB, "users")
+
+// AdminMiddleware only lets users whose type is "admin" through. It must run
+// after AuthMiddleware, which sets userId in Locals.
+func AdminMiddleware(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userId, ok := c.Locals("userId").(string)
+	if !ok || userId == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User ID not found in token",
+		})
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "Invalid User ID format",
+		})
+	}
+
+	var user models.User
+	findOptions := options.FindOne().SetProjection(bson.M{"type": 1})
+	if err := userCollection.FindOne(ctx, bson.M{"_id": userObjectID}, findOptions).Decode(&user); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(responses.UserResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "Admin access required",
+		})
+	}
+
+	if user.Type != "admin" {
+		return c.Status(fiber.StatusForbidden).JSON(responses.UserResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "Admin access required",
+		})
+	}
+
+	return c.Next()
+}
@@ -1,7 +1,9 @@
 package middlewares
 
 import (
+	"errors"
 	"fiber-mongo-api/responses"
+	cartService "fiber-mongo-api/services/cart"
 	"os"
 	"strings"
 
@@ -11,24 +13,24 @@ import (
 
 var jwtSecret = os.Getenv("JWT_SECRET")
 
-// AuthMiddleware
-func AuthMiddleware(c *fiber.Ctx) error {
-	// Extract the token from the Authorization header
-	authHeader := c.Get("Authorization")
+var (
+	errNoAuthHeader      = errors.New("No auth token, access denied")
+	errInvalidAuthHeader = errors.New("Invalid authorization header format")
+	errInvalidToken      = errors.New("Token verification failed, access denied")
+	errUserIdNotInToken  = errors.New("User ID not found in token")
+)
+
+// userIdFromAuthHeader validates a "Bearer <jwt>" header and returns the user
+// ID carried in the token claims.
+func userIdFromAuthHeader(authHeader string) (string, error) {
 	if authHeader == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "No auth token, access denied",
-		})
+		return "", errNoAuthHeader
 	}
 
 	// Check if the Authorization header starts with "Bearer "
 	bearerToken := strings.Split(authHeader, " ")
 	if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid authorization header format",
-		})
+		return "", errInvalidAuthHeader
 	}
 
 	tokenString := bearerToken[1]
@@ -40,18 +42,26 @@ func AuthMiddleware(c *fiber.Ctx) error {
 	})
 
 	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Token verification failed, access denied",
-		})
+		return "", errInvalidToken
 	}
 
 	// Extract the user ID from the token claims
 	userId, ok := (*claims)["id"].(string)
 	if !ok || userId == "" {
+		return "", errUserIdNotInToken
+	}
+
+	return userId, nil
+}
+
+// AuthMiddleware
+func AuthMiddleware(c *fiber.Ctx) error {
+	// Extract the token from the Authorization header
+	userId, err := userIdFromAuthHeader(c.Get("Authorization"))
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
 			Status:  fiber.StatusUnauthorized,
-			Message: "User ID not found in token",
+			Message: err.Error(),
 		})
 	}
 
@@ -60,3 +70,43 @@ func AuthMiddleware(c *fiber.Ctx) error {
 
 	return c.Next()
 }
+
+// OptionalAuthMiddleware sets userId in Locals when a valid token is sent and
+// lets the request through anonymously otherwise. Use it on public routes
+// that personalise their response for signed-in users.
+func OptionalAuthMiddleware(c *fiber.Ctx) error {
+	if userId, err := userIdFromAuthHeader(c.Get("Authorization")); err == nil {
+		c.Locals("userId", userId)
+	}
+
+	return c.Next()
+}
+
+// CartAuthMiddleware accepts either a user token in the Authorization header
+// or a guest token in the X-Guest-Token header. A user token wins when both
+// are sent; an invalid user token is rejected rather than falling back.
+func CartAuthMiddleware(c *fiber.Ctx) error {
+	if authHeader := c.Get("Authorization"); authHeader != "" {
+		return AuthMiddleware(c)
+	}
+
+	guestToken := c.Get(cartService.GuestTokenHeader)
+	if guestToken == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "No auth token or guest token, access denied",
+		})
+	}
+
+	guestCartID, err := cartService.ParseGuestToken(guestToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.UserResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "Guest token verification failed, access denied",
+		})
+	}
+
+	c.Locals("guestCartId", guestCartID.Hex())
+
+	return c.Next()
+}
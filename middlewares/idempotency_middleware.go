@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"fiber-mongo-api/responses"
+	idempotencyService "fiber-mongo-api/services/idempotency"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// IdempotencyMiddleware makes a mutating endpoint safe to retry. When the
+// request carries an Idempotency-Key header the first response is stored and
+// sent again for every retry with the same key and body; reusing the key with
+// a different body is rejected with 409. Requests without the header run as
+// usual. It must run after the auth middleware so keys are scoped per user.
+func IdempotencyMiddleware(c *fiber.Ctx) error {
+	key := c.Get(idempotencyService.KeyHeader)
+	if key == "" {
+		return c.Next()
+	}
+
+	if len(key) > idempotencyService.MaxKeyLength {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.UserResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Idempotency key is too long",
+		})
+	}
+
+	owner, _ := c.Locals("userId").(string)
+	if owner == "" {
+		if guestCartId, ok := c.Locals("guestCartId").(string); ok {
+			owner = "guest:" + guestCartId
+		}
+	}
+
+	method := c.Method()
+	path := c.Path()
+	requestHash := idempotencyService.RequestHash(method, path, c.Body())
+
+	beginCtx, cancelBegin := context.WithTimeout(context.Background(), 10*time.Second)
+	record, err := idempotencyService.Begin(beginCtx, owner, key, method, path, requestHash)
+	cancelBegin()
+	if err == idempotencyService.ErrKeyReused || err == idempotencyService.ErrInProgress {
+		return c.Status(fiber.StatusConflict).JSON(responses.UserResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.UserResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Error checking idempotency key",
+		})
+	}
+
+	if record != nil {
+		c.Set("Idempotent-Replayed", "true")
+		c.Set(fiber.HeaderContentType, record.ContentType)
+		return c.Status(record.ResponseStatus).Send(record.ResponseBody)
+	}
+
+	handlerErr := c.Next()
+
+	// The handler may outlast any timeout set before it ran, so the key is
+	// settled with a context of its own
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := handlerErr; err != nil {
+		if abandonErr := idempotencyService.Abandon(ctx, owner, key); abandonErr != nil {
+			log.Printf("idempotency: releasing key after handler error: %v", abandonErr)
+		}
+		return err
+	}
+
+	// Server errors are not stored so the client can retry them
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusInternalServerError {
+		if err := idempotencyService.Abandon(ctx, owner, key); err != nil {
+			log.Printf("idempotency: releasing key after server error: %v", err)
+		}
+		return nil
+	}
+
+	// The response buffer is reused by fasthttp, so store a copy
+	body := append([]byte(nil), c.Response().Body()...)
+	contentType := string(c.Response().Header.ContentType())
+	if err := idempotencyService.Complete(ctx, owner, key, status, contentType, body); err != nil {
+		log.Printf("idempotency: storing response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Category is a node of the product taxonomy. Ancestors lists the
+// categories above it from the root down, so a subtree is found with one
+// query. Products sit in any number of categories through CategoryIDs.
+type Category struct {
+	ID          primitive.ObjectID   `json:"id" bson:"_id"`
+	Name        string               `json:"name" bson:"name"`
+	Slug        string               `json:"slug" bson:"slug"`
+	Description string               `json:"description,omitempty" bson:"description,omitempty"`
+	Image       string               `json:"image,omitempty" bson:"image,omitempty"`
+	ParentID    *primitive.ObjectID  `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	Ancestors   []primitive.ObjectID `json:"ancestors" bson:"ancestors"`
+	// Position orders a category among its siblings
+	Position  int       `json:"position" bson:"position"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Money is an amount in the smallest unit of its currency, for example paise
+// for INR or cents for USD. Amounts are only turned into decimals for
+// display: in JSON a Money is the decimal number, with as many places as the
+// currency has, and the currency is given next to it by the enclosing
+// document.
+type Money struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+// minorDigits lists the currencies whose minor unit is not a hundredth, per
+// ISO 4217.
+var minorDigits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorDigits is the number of decimal places of a currency: 0 for JPY, 3
+// for KWD and 2 for most others.
+func MinorDigits(currency string) int {
+	if digits, ok := minorDigits[currency]; ok {
+		return digits
+	}
+	return 2
+}
+
+// Decimal writes the amount in major units with the currency's decimal
+// places, such as "1234.50".
+func (m Money) Decimal() string {
+	digits := MinorDigits(m.Currency)
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	s := strconv.FormatInt(amount, 10)
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON reads the decimal number MarshalJSON writes. The currency is
+// not part of it, so the number is read in m's currency, or with two places
+// when that is not set yet and left for the enclosing document to fill in.
+// Numbers with more places than the currency has are rejected rather than
+// rounded.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	digits := MinorDigits(m.Currency)
+	sign := int64(1)
+	if strings.HasPrefix(text, "-") {
+		sign, text = -1, text[1:]
+	}
+	whole, fraction, _ := strings.Cut(text, ".")
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > digits {
+		return fmt.Errorf("cannot decode %s into Money with %d decimal places", data, digits)
+	}
+	amount, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", digits-len(fraction)), 10, 64)
+	if err != nil || whole == "" {
+		return fmt.Errorf("cannot decode %s into Money", data)
+	}
+	m.Amount = sign * amount
+	return nil
+}
+
+// UnmarshalBSONValue also reads the plain numbers documents were stored with
+// before amounts carried their currency. Those were in two decimal units of
+// the document's currency, which is left for the document to fill in.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.EmbeddedDocument:
+		type money Money
+		return raw.Unmarshal((*money)(m))
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+		return nil
+	case bsontype.Double:
+		*m = Money{Amount: int64(math.Round(raw.Double() * 100))}
+		return nil
+	}
+	return fmt.Errorf("cannot decode %s into Money", t)
+}
+
+// ExchangeRate is how many units of Currency one unit of the base currency
+// buys. Prices are stored in the base currency and converted with it unless
+// the product has a price list entry for the currency.
+type ExchangeRate struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Currency  string             `json:"currency" bson:"currency"`
+	Rate      float64            `json:"rate" bson:"rate"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// BaseAmounts records an order's figures in the base currency, in minor
+// units, at the exchange rate it was placed at. Reports and accounting read
+// these instead of the amounts the shopper was charged.
+type BaseAmounts struct {
+	Currency      string  `json:"currency" bson:"currency"`
+	ExchangeRate  float64 `json:"exchangeRate" bson:"exchangeRate"`
+	Subtotal      int64   `json:"subtotal" bson:"subtotal"`
+	DiscountTotal int64   `json:"discountTotal" bson:"discountTotal"`
+	Tax           int64   `json:"tax" bson:"tax"`
+	Shipping      int64   `json:"shipping" bson:"shipping"`
+	PlatformFee   int64   `json:"platformFee" bson:"platformFee"`
+	Total         int64   `json:"total" bson:"total"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		money Money
+		json  string
+	}{
+		{Money{Amount: 123450, Currency: "INR"}, "1234.50"},
+		{Money{Amount: -5, Currency: "USD"}, "-0.05"},
+		{Money{Amount: 1500, Currency: "JPY"}, "1500"},
+		{Money{Amount: 1234, Currency: "KWD"}, "1.234"},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.money)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", tt.money, err)
+		}
+		if string(data) != tt.json {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.money, data, tt.json)
+		}
+		got := Money{Currency: tt.money.Currency}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", data, err)
+		}
+		if got != tt.money {
+			t.Errorf("Unmarshal(%s) = %v, want %v", data, got, tt.money)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSONRejects(t *testing.T) {
+	tests := []struct {
+		currency string
+		json     string
+	}{
+		{"INR", "1.234"},
+		{"JPY", "1.5"},
+		{"INR", `"12.00"`},
+		{"INR", "1e3"},
+	}
+	for _, tt := range tests {
+		m := Money{Currency: tt.currency}
+		if err := json.Unmarshal([]byte(tt.json), &m); err == nil {
+			t.Errorf("Unmarshal(%s) in %s = %v, want error", tt.json, tt.currency, m)
+		}
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{Amount: 123450, Currency: "INR"}, "1234.50"},
+		{Money{Amount: 5, Currency: "INR"}, "0.05"},
+		{Money{Amount: 0, Currency: "USD"}, "0.00"},
+		{Money{Amount: -150, Currency: "USD"}, "-1.50"},
+		{Money{Amount: 1500, Currency: "JPY"}, "1500"},
+		{Money{Amount: -7, Currency: "JPY"}, "-7"},
+		{Money{Amount: 0, Currency: "JPY"}, "0"},
+		{Money{Amount: 1234, Currency: "KWD"}, "1.234"},
+		{Money{Amount: 5, Currency: "KWD"}, "0.005"},
+		{Money{Amount: -1000, Currency: "BHD"}, "-1.000"},
+		{Money{Amount: 99}, "0.99"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("%v.Decimal() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
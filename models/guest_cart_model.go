@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GuestCart holds the cart of a shopper who has not signed in. The document
+// is removed by a TTL index once ExpiresAt passes.
+type GuestCart struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	Cart       []CartItem         `json:"cart" bson:"cart"`
+	CouponCode string             `json:"couponCode,omitempty" bson:"couponCode,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ExpiresAt  time.Time          `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Idempotency record states
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyRecord remembers a mutating request made with an Idempotency-Key
+// header and, once it finished, the response that was sent for it.
+type IdempotencyRecord struct {
+	ID             string    `json:"id" bson:"_id"`
+	Owner          string    `json:"owner" bson:"owner"`
+	Key            string    `json:"key" bson:"key"`
+	Method         string    `json:"method" bson:"method"`
+	Path           string    `json:"path" bson:"path"`
+	RequestHash    string    `json:"requestHash" bson:"requestHash"`
+	State          string    `json:"state" bson:"state"`
+	ResponseStatus int       `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	ContentType    string    `json:"contentType,omitempty" bson:"contentType,omitempty"`
+	ResponseBody   []byte    `json:"-" bson:"responseBody,omitempty"`
+	LockedUntil    time.Time `json:"lockedUntil" bson:"lockedUntil"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt      time.Time `json:"expiresAt" bson:"expiresAt"`
+}
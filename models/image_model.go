@@ -0,0 +1,13 @@
+package models
+
+// Image is an uploaded image: its large size and thumbnail, each as JPEG or
+// PNG and as WebP. A size has no WebP when it would not be smaller. Width
+// and Height are those of the large size.
+type Image struct {
+	URL           string `json:"url" bson:"url"`
+	WebP          string `json:"webp,omitempty" bson:"webp,omitempty"`
+	Thumbnail     string `json:"thumbnail" bson:"thumbnail"`
+	ThumbnailWebP string `json:"thumbnailWebp,omitempty" bson:"thumbnailWebp,omitempty"`
+	Width         int    `json:"width" bson:"width"`
+	Height        int    `json:"height" bson:"height"`
+}
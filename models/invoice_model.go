@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InvoiceParty is the seller or the buyer named on an invoice.
+type InvoiceParty struct {
+	Name    string `json:"name" bson:"name"`
+	GSTIN   string `json:"gstin,omitempty" bson:"gstin,omitempty"`
+	Email   string `json:"email,omitempty" bson:"email,omitempty"`
+	Address string `json:"address" bson:"address"`
+	State   string `json:"state" bson:"state"`
+}
+
+// InvoiceLine is an order item as invoiced. Amounts are in the invoice's
+// currency; UnitPrice is the taxable value of one unit, after discounts.
+type InvoiceLine struct {
+	Description  string  `json:"description" bson:"description"`
+	HSNCode      string  `json:"hsnCode,omitempty" bson:"hsnCode,omitempty"`
+	Quantity     int     `json:"quantity" bson:"quantity"`
+	UnitPrice    Money   `json:"unitPrice" bson:"unitPrice"`
+	TaxableValue Money   `json:"taxableValue" bson:"taxableValue"`
+	TaxRate      float64 `json:"taxRate" bson:"taxRate"`
+	TaxAmount    Money   `json:"taxAmount" bson:"taxAmount"`
+	Total        Money   `json:"total" bson:"total"`
+}
+
+// Invoice is the GST tax invoice issued for a paid order. Numbers run
+// without gaps within a financial year (April to March), e.g. INV/2025-26/000042.
+// The invoice keeps its own copy of everything printed on it.
+type Invoice struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id"`
+	OrderID       primitive.ObjectID `json:"orderId" bson:"orderId"`
+	UserID        primitive.ObjectID `json:"userId" bson:"userId"`
+	Number        string             `json:"number" bson:"number"`
+	FinancialYear string             `json:"financialYear" bson:"financialYear"`
+	Sequence      int64              `json:"sequence" bson:"sequence"`
+	IssuedAt      time.Time          `json:"issuedAt" bson:"issuedAt"`
+	Seller        InvoiceParty       `json:"seller" bson:"seller"`
+	Buyer         InvoiceParty       `json:"buyer" bson:"buyer"`
+	PlaceOfSupply string             `json:"placeOfSupply" bson:"placeOfSupply"`
+	Currency      string             `json:"currency" bson:"currency"`
+	Lines         []InvoiceLine      `json:"lines" bson:"lines"`
+	Tax           TaxBreakdown       `json:"tax" bson:"tax"`
+	Shipping      Money              `json:"shipping" bson:"shipping"`
+	PlatformFee   Money              `json:"platformFee" bson:"platformFee"`
+	Total         Money              `json:"total" bson:"total"`
+	PaymentMethod string             `json:"paymentMethod,omitempty" bson:"paymentMethod,omitempty"`
+	PaymentID     string             `json:"paymentId,omitempty" bson:"paymentId,omitempty"`
+}
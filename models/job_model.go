@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobRun records one run of a background job.
+type JobRun struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	Job        string             `json:"job" bson:"job"`
+	StartedAt  time.Time          `json:"startedAt" bson:"startedAt"`
+	FinishedAt time.Time          `json:"finishedAt" bson:"finishedAt"`
+	Succeeded  bool               `json:"succeeded" bson:"succeeded"`
+	Error      string             `json:"error,omitempty" bson:"error,omitempty"`
+	// Results counts what the run did, for example orders marked paid
+	Results map[string]int `json:"results,omitempty" bson:"results,omitempty"`
+}
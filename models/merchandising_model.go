@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection types
+const (
+	CollectionManual    = "manual"
+	CollectionRuleBased = "rule"
+)
+
+// CollectionRule picks the products of a rule-based collection. Categories
+// include their subcategories; empty fields do not narrow the selection.
+type CollectionRule struct {
+	CategoryIDs []primitive.ObjectID `json:"categoryIds,omitempty" bson:"categoryIds,omitempty"`
+	Brands      []string             `json:"brands,omitempty" bson:"brands,omitempty"`
+	MinPrice    float64              `json:"minPrice,omitempty" bson:"minPrice,omitempty"`
+	MaxPrice    float64              `json:"maxPrice,omitempty" bson:"maxPrice,omitempty"`
+	InStockOnly bool                 `json:"inStockOnly,omitempty" bson:"inStockOnly,omitempty"`
+	// Sort is newest, price_asc or price_desc
+	Sort string `json:"sort" bson:"sort"`
+}
+
+// Collection is a merchandising list such as "New Arrivals", kept apart
+// from the category taxonomy: either products picked by hand, in order, or
+// a rule evaluated when it is shown.
+type Collection struct {
+	ID         primitive.ObjectID   `json:"id" bson:"_id"`
+	Name       string               `json:"name" bson:"name"`
+	Slug       string               `json:"slug" bson:"slug"`
+	Type       string               `json:"type" bson:"type"`
+	ProductIDs []primitive.ObjectID `json:"productIds,omitempty" bson:"productIds,omitempty"`
+	Rule       *CollectionRule      `json:"rule,omitempty" bson:"rule,omitempty"`
+	CreatedAt  time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Home section types
+const (
+	SectionCollection = "collection"
+	SectionBanners    = "banners"
+)
+
+// Banner is one slide of a banners section. Link is where tapping it goes,
+// a URL or an app path.
+type Banner struct {
+	ID       primitive.ObjectID `json:"id" bson:"id"`
+	Title    string             `json:"title,omitempty" bson:"title,omitempty"`
+	Subtitle string             `json:"subtitle,omitempty" bson:"subtitle,omitempty"`
+	Image    string             `json:"image" bson:"image"`
+	Link     string             `json:"link,omitempty" bson:"link,omitempty"`
+	StartsAt *time.Time         `json:"startsAt,omitempty" bson:"startsAt,omitempty"`
+	EndsAt   *time.Time         `json:"endsAt,omitempty" bson:"endsAt,omitempty"`
+}
+
+// HomeSection is one section of the home feed: a collection's products or
+// a row of banners. Sections and banners are only shown within their
+// scheduling window; a missing end never ends.
+type HomeSection struct {
+	ID           primitive.ObjectID  `json:"id" bson:"_id"`
+	Title        string              `json:"title" bson:"title"`
+	Type         string              `json:"type" bson:"type"`
+	CollectionID *primitive.ObjectID `json:"collectionId,omitempty" bson:"collectionId,omitempty"`
+	// Limit caps the products shown from the collection
+	Limit     int        `json:"limit,omitempty" bson:"limit,omitempty"`
+	Banners   []Banner   `json:"banners,omitempty" bson:"banners,omitempty"`
+	Position  int        `json:"position" bson:"position"`
+	StartsAt  *time.Time `json:"startsAt,omitempty" bson:"startsAt,omitempty"`
+	EndsAt    *time.Time `json:"endsAt,omitempty" bson:"endsAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification types
+const (
+	NotificationPriceDrop   = "price_drop"
+	NotificationBackInStock = "back_in_stock"
+)
+
+// Notification is a message to a user, shown in their in-app inbox. Key
+// identifies the event it is about, so the same event notifies a user once.
+type Notification struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id"`
+	UserID    primitive.ObjectID  `json:"userId" bson:"userId"`
+	Type      string              `json:"type" bson:"type"`
+	Title     string              `json:"title" bson:"title"`
+	Body      string              `json:"body" bson:"body"`
+	ProductID *primitive.ObjectID `json:"productId,omitempty" bson:"productId,omitempty"`
+	Size      string              `json:"size,omitempty" bson:"size,omitempty"`
+	Key       string              `json:"-" bson:"key,omitempty"`
+	ReadAt    *time.Time          `json:"readAt,omitempty" bson:"readAt,omitempty"`
+	CreatedAt time.Time           `json:"createdAt" bson:"createdAt"`
+}
@@ -3,28 +3,178 @@ package models
 import (
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Payment methods
+const (
+	PaymentRazorpay = "razorpay"
+	PaymentCOD      = "cod"
+	// PaymentExchange marks a replacement order sent out for an exchange,
+	// paid for by the original order.
+	PaymentExchange = "exchange"
+)
+
+// Refund methods
+const (
+	RefundRazorpay = "razorpay" // Back to the original payment
+	RefundManual   = "manual"   // Paid out outside the gateway, e.g. for cash on delivery
+)
+
+// Refund statuses
+const (
+	RefundPending   = "pending"
+	RefundProcessed = "processed"
+)
+
+// PaymentCODPending is the payment status of a cash on delivery order until
+// the cash is collected.
+const PaymentCODPending = "cod_pending"
+
+// Payment statuses of a pre-order paid on dispatch: the payment is
+// authorized at checkout and captured when the order ships, or voided when
+// it is cancelled first.
+const (
+	PaymentAuthorized = "authorized"
+	PaymentVoided     = "voided"
+)
+
+// Post-payment steps recorded on an order until they succeed
+const (
+	OrderStepClearCart    = "clear_cart"
+	OrderStepIssueInvoice = "issue_invoice"
+	// OrderStepCapturePayment captures an authorized payment once the
+	// order is dispatched
+	OrderStepCapturePayment = "capture_payment"
+)
+
 // OrderItem represents a single item in an order
 type OrderItem struct {
 	ProductID primitive.ObjectID `json:"productId" bson:"productId"`
-	Product   Product     `json:"product" bson:"product"`
+	LineID    primitive.ObjectID `json:"lineId,omitempty" bson:"lineId,omitempty"` // Cart line the item was ordered from
+	Product   Product            `json:"product" bson:"product"`
 	Quantity  int                `json:"quantity" bson:"quantity"`
 	Size      string             `json:"size" bson:"size,omitempty"`
+	Tax       *ItemTax           `json:"tax,omitempty" bson:"tax,omitempty"`
+	// UnitPrice is what one unit cost in the order's currency, before
+	// discounts
+	UnitPrice *Money `json:"unitPrice,omitempty" bson:"unitPrice,omitempty"`
+	// BaseTotal is the line after discounts and with GST, in base currency
+	// minor units, for sales reports
+	BaseTotal int64 `json:"-" bson:"baseTotal,omitempty"`
+	// ReleaseDate is set on pre-ordered items; they ship once it is reached
+	ReleaseDate *time.Time `json:"releaseDate,omitempty" bson:"releaseDate,omitempty"`
+}
+
+// OrderPreOrder flags an order holding pre-ordered items. ReleaseDate is
+// the latest release among them. A CaptureOnDispatch order is only
+// authorized at checkout and charged when it ships.
+type OrderPreOrder struct {
+	ReleaseDate       time.Time `json:"releaseDate" bson:"releaseDate"`
+	CaptureOnDispatch bool      `json:"captureOnDispatch" bson:"captureOnDispatch"`
+}
+
+// OrderAddress is the shipping address as it was when the order was placed.
+// It is kept on the order so later edits to the address book do not change
+// where the order ships.
+type OrderAddress struct {
+	StreetAddress string `json:"streetAddress" bson:"streetAddress"`
+	City          string `json:"city" bson:"city"`
+	State         string `json:"state" bson:"state"`
+	ZipCode       string `json:"zipCode" bson:"zipCode"`
+}
+
+// Refund is money paid back against an order. Amount is in the order's
+// currency.
+type Refund struct {
+	ID               primitive.ObjectID  `json:"id" bson:"_id"`
+	Amount           Money               `json:"amount" bson:"amount"`
+	Method           string              `json:"method" bson:"method"`
+	Status           string              `json:"status" bson:"status"`
+	ProviderRefundID string              `json:"providerRefundId,omitempty" bson:"providerRefundId,omitempty"`
+	ReturnID         *primitive.ObjectID `json:"returnId,omitempty" bson:"returnId,omitempty"`
+	Reason           string              `json:"reason,omitempty" bson:"reason,omitempty"`
+	CreatedAt        time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// OrderStatusChange records a move of an order to Status. By is the admin
+// who made it, if it was not automatic.
+type OrderStatusChange struct {
+	Status string              `json:"status" bson:"status"`
+	Note   string              `json:"note,omitempty" bson:"note,omitempty"`
+	By     *primitive.ObjectID `json:"by,omitempty" bson:"by,omitempty"`
+	At     time.Time           `json:"at" bson:"at"`
+}
+
+// OrderNote is an internal note left on an order by an admin. Notes are
+// never shown to the customer.
+type OrderNote struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Text      string             `json:"text" bson:"text"`
+	AuthorID  primitive.ObjectID `json:"authorId" bson:"authorId"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
 }
 
 // Order represents a customer order
 type Order struct {
-	ID            primitive.ObjectID `json:"id" bson:"_id"`
-	UserID        primitive.ObjectID `json:"userId" bson:"userId"`
-	AddressID     primitive.ObjectID `json:"addressId" bson:"addressId"`
-	Items         []OrderItem        `json:"items" bson:"items"`
-	TotalAmount   float64            `json:"totalAmount" bson:"totalAmount"`
-	Status        string             `json:"status" bson:"status"`               // pending, processing, shipped, delivered, cancelled
-	PaymentStatus string             `json:"paymentStatus" bson:"paymentStatus"` // pending, completed, failed
-	RazorpayID    string             `json:"razorpayId" bson:"razorpayId"`
-	PaymentID     string             `json:"paymentId,omitempty" bson:"paymentId,omitempty"`
-	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID              primitive.ObjectID `json:"id" bson:"_id"`
+	UserID          primitive.ObjectID `json:"userId" bson:"userId"`
+	AddressID       primitive.ObjectID `json:"addressId" bson:"addressId"`
+	ShippingAddress *OrderAddress      `json:"shippingAddress,omitempty" bson:"shippingAddress,omitempty"` // Snapshot of the address at checkout
+	Items           []OrderItem        `json:"items" bson:"items"`
+	Subtotal        Money              `json:"subtotal" bson:"subtotal"`
+	Discounts       []AppliedDiscount  `json:"discounts,omitempty" bson:"discounts,omitempty"`
+	DiscountTotal   Money              `json:"discountTotal" bson:"discountTotal"`
+	CouponCode      string             `json:"couponCode,omitempty" bson:"couponCode,omitempty"`
+	PlatformFee     Money              `json:"platformFee" bson:"platformFee"`
+	Tax             *TaxBreakdown      `json:"tax,omitempty" bson:"tax,omitempty"`
+	Shipping        *ShippingQuote     `json:"shipping,omitempty" bson:"shipping,omitempty"`
+	TotalAmount     Money              `json:"totalAmount" bson:"totalAmount"` // The amount charged
+	// The amounts above are in Currency; Base is the order in the base
+	// currency for accounting.
+	Currency       string              `json:"currency,omitempty" bson:"currency,omitempty"`
+	Base           *BaseAmounts        `json:"base,omitempty" bson:"base,omitempty"`
+	Status         string              `json:"status" bson:"status"`                                   // pending, processing, shipped, delivered, cancelled, expired
+	PaymentMethod  string              `json:"paymentMethod,omitempty" bson:"paymentMethod,omitempty"` // razorpay, cod
+	PaymentStatus  string              `json:"paymentStatus" bson:"paymentStatus"`                     // pending, cod_pending, authorized, completed, voided, failed
+	PreOrder       *OrderPreOrder      `json:"preOrder,omitempty" bson:"preOrder,omitempty"`
+	AuthorizedAt   *time.Time          `json:"authorizedAt,omitempty" bson:"authorizedAt,omitempty"` // When a capture on dispatch payment was authorized
+	CapturedAt     *time.Time          `json:"capturedAt,omitempty" bson:"capturedAt,omitempty"`
+	CollectedAt    *time.Time          `json:"collectedAt,omitempty" bson:"collectedAt,omitempty"` // When cash on delivery was collected
+	DeliveredAt    *time.Time          `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+	Refunds        []Refund            `json:"refunds,omitempty" bson:"refunds,omitempty"`
+	RefundedTotal  *Money              `json:"refundedTotal,omitempty" bson:"refundedTotal,omitempty"`
+	ReplacementFor *primitive.ObjectID `json:"replacementFor,omitempty" bson:"replacementFor,omitempty"` // Return this order replaces items for
+	RazorpayID     string              `json:"razorpayId" bson:"razorpayId"`
+	PaymentID      string              `json:"paymentId,omitempty" bson:"paymentId,omitempty"`
+	StatusHistory  []OrderStatusChange `json:"statusHistory,omitempty" bson:"statusHistory,omitempty"`
+	Notes          []OrderNote         `json:"-" bson:"notes,omitempty"`         // Internal, see OrderNote
+	StockReserved  bool                `json:"-" bson:"stockReserved,omitempty"` // Stock is held for the order until it is paid or expires
+	PendingSteps   []string            `json:"-" bson:"pendingSteps,omitempty"`  // Post-payment steps still to run, see OrderStepClearCart
+	CreatedAt      time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// UnmarshalBSON gives the plain-number amounts of older orders the order's
+// currency. Orders from before currencies were recorded are in rupees.
+func (o *Order) UnmarshalBSON(data []byte) error {
+	type order Order
+	if err := bson.Unmarshal(data, (*order)(o)); err != nil {
+		return err
+	}
+	currency := o.Currency
+	if currency == "" {
+		currency = "INR"
+	}
+	amounts := []*Money{&o.Subtotal, &o.DiscountTotal, &o.PlatformFee, &o.TotalAmount, o.RefundedTotal}
+	for i := range o.Refunds {
+		amounts = append(amounts, &o.Refunds[i].Amount)
+	}
+	for _, amount := range amounts {
+		if amount != nil && amount.Currency == "" {
+			amount.Currency = currency
+		}
+	}
+	return nil
 }
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Product import formats
+const (
+	ImportCSV    = "csv"
+	ImportNDJSON = "ndjson"
+)
+
+// Product import statuses
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportRowError explains why one row of an import was not applied.
+type ImportRowError struct {
+	Row     int    `json:"row" bson:"row"`
+	SKU     string `json:"sku,omitempty" bson:"sku,omitempty"`
+	Field   string `json:"field,omitempty" bson:"field,omitempty"`
+	Message string `json:"message" bson:"message"`
+}
+
+// ProductImport tracks a bulk product import. Rows are upserted by SKU in
+// the background; the counts and errors grow as it runs.
+type ProductImport struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Format    string             `json:"format" bson:"format"`
+	Filename  string             `json:"filename,omitempty" bson:"filename,omitempty"`
+	Status    string             `json:"status" bson:"status"`
+	CreatedBy primitive.ObjectID `json:"createdBy" bson:"createdBy"`
+	// Data is the uploaded file, dropped once the import finishes
+	Data []byte `json:"-" bson:"data,omitempty"`
+	// LeaseUntil is when a running import is considered abandoned
+	LeaseUntil time.Time        `json:"-" bson:"leaseUntil,omitempty"`
+	Rows       int              `json:"rows" bson:"rows"`
+	Created    int              `json:"created" bson:"created"`
+	Updated    int              `json:"updated" bson:"updated"`
+	Failed     int              `json:"failed" bson:"failed"`
+	Errors     []ImportRowError `json:"errors" bson:"errors"`
+	// ErrorsTruncated is set when more rows failed than errors are kept
+	ErrorsTruncated bool      `json:"errorsTruncated,omitempty" bson:"errorsTruncated,omitempty"`
+	Error           string    `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt       time.Time `json:"createdAt" bson:"createdAt"`
+	StartedAt       time.Time `json:"startedAt,omitempty" bson:"startedAt,omitempty"`
+	FinishedAt      time.Time `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+}
@@ -1,18 +1,55 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Pre-order payment modes
+const (
+	PreOrderUpfront   = "upfront"   // Charged in full at checkout
+	PreOrderAuthorize = "authorize" // Authorized at checkout and captured at dispatch
+)
+
+// PreOrder makes a product orderable ahead of its release. Until
+// ReleaseDate the product's quantity is how many can be pre-ordered.
+type PreOrder struct {
+	ReleaseDate time.Time `bson:"releaseDate" json:"releaseDate"`
+	Payment     string    `bson:"payment" json:"payment"`
+}
 
 type Product struct {
 	// ProductID   string   `bson:"productId" json:"productId" validate:"required,uuid4"`
-	ID            primitive.ObjectID `json:"productId,omitempty" bson:"_id,omitempty"`
-	Name          string             `bson:"name" json:"name" validate:"required"`
-	Brand         string             `bson:"brand" json:"brand" validate:"required"`
-	Description   string             `bson:"description" json:"description" validate:"required"`
-	Quantity      int                `bson:"quantity" json:"quantity" validate:"required,min=1"`
-	Price         float64            `bson:"price" json:"price" validate:"required,gt=0"`
-	Category      string             `bson:"category" json:"category" validate:"required"`
-	Images        []string           `bson:"images" json:"images" validate:"required,min=1,dive"`
-	InCart        bool               `bson:"inCart" json:"inCart"`
-	CartItemCount int                `bson:"cartItemCount,omitempty" json:"cartItemCount,omitempty"`
-	Size          string             `bson:"size,omitempty" json:"size,omitempty"`
+	ID primitive.ObjectID `json:"productId,omitempty" bson:"_id,omitempty"`
+	// SKU is the catalogue team's key for the product, used by bulk imports
+	SKU         string  `bson:"sku,omitempty" json:"sku,omitempty"`
+	Name        string  `bson:"name" json:"name" validate:"required"`
+	Brand       string  `bson:"brand" json:"brand" validate:"required"`
+	Description string  `bson:"description" json:"description" validate:"required"`
+	Quantity    int     `bson:"quantity" json:"quantity" validate:"required,min=1"`
+	Price       float64 `bson:"price" json:"price" validate:"required,gt=0"`
+	Category    string  `bson:"category" json:"category" validate:"required"`
+	// CategoryIDs places the product in the category taxonomy; Category
+	// remains the label tax rates and promotions match on
+	CategoryIDs []primitive.ObjectID `bson:"categoryIds,omitempty" json:"categoryIds,omitempty"`
+	HSNCode     string               `bson:"hsnCode,omitempty" json:"hsnCode,omitempty"`
+	WeightGrams int                  `bson:"weightGrams,omitempty" json:"weightGrams,omitempty"`
+	Images      []string             `bson:"images" json:"images" validate:"required,min=1,dive"`
+	// ImageVariants are the sizes and formats of images uploaded for the
+	// product; their URLs are also in Images
+	ImageVariants []Image `bson:"imageVariants,omitempty" json:"imageVariants,omitempty"`
+	// Prices is the price list: the price in minor units per currency code,
+	// overriding conversion from Price for that currency.
+	Prices map[string]int64 `bson:"prices,omitempty" json:"prices,omitempty"`
+	// Rating is kept up to date by the reviews service as reviews are
+	// approved and removed
+	Rating *ProductRating `bson:"rating,omitempty" json:"rating,omitempty"`
+	// PreOrder is set while the product is sold ahead of its release date
+	PreOrder *PreOrder `bson:"preOrder,omitempty" json:"preOrder,omitempty"`
+	// InCart and CartItemCount describe the requesting user's cart and are
+	// filled in per request; they are never stored.
+	InCart        bool   `bson:"-" json:"inCart"`
+	CartItemCount int    `bson:"-" json:"cartItemCount,omitempty"`
+	Size          string `bson:"size,omitempty" json:"size,omitempty"`
 }
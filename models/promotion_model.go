@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Promotion types
+const (
+	PromotionPercentage = "percentage"
+	PromotionFlat       = "flat"
+)
+
+// Promotion is a discount rule. Promotions with a Code are coupons the
+// shopper applies to the cart; promotions marked Automatic apply to every
+// eligible cart without a code.
+type Promotion struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	Code         string             `json:"code,omitempty" bson:"code,omitempty"`
+	Name         string             `json:"name" bson:"name" validate:"required"`
+	Description  string             `json:"description,omitempty" bson:"description,omitempty"`
+	Type         string             `json:"type" bson:"type" validate:"required,oneof=percentage flat"`
+	Value        float64            `json:"value" bson:"value" validate:"required,gt=0"`
+	MaxDiscount  float64            `json:"maxDiscount,omitempty" bson:"maxDiscount,omitempty"`
+	MinCartValue float64            `json:"minCartValue,omitempty" bson:"minCartValue,omitempty"`
+	Brands       []string           `json:"brands,omitempty" bson:"brands,omitempty"`
+	Categories   []string           `json:"categories,omitempty" bson:"categories,omitempty"`
+	UsageLimit   int                `json:"usageLimit,omitempty" bson:"usageLimit,omitempty"`
+	PerUserLimit int                `json:"perUserLimit,omitempty" bson:"perUserLimit,omitempty"`
+	UsedCount    int                `json:"usedCount" bson:"usedCount"`
+	Automatic    bool               `json:"automatic" bson:"automatic"`
+	Active       bool               `json:"active" bson:"active"`
+	StartsAt     *time.Time         `json:"startsAt,omitempty" bson:"startsAt,omitempty"`
+	EndsAt       *time.Time         `json:"endsAt,omitempty" bson:"endsAt,omitempty"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// AppliedDiscount is a promotion applied to a cart or order and the amount
+// it took off.
+type AppliedDiscount struct {
+	PromotionID primitive.ObjectID `json:"promotionId" bson:"promotionId"`
+	Code        string             `json:"code,omitempty" bson:"code,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Amount      Money              `json:"amount" bson:"amount"`
+}
+
+// PromotionRedemption records one use of a promotion by a user on an order.
+// Redemptions back the per-user usage limit.
+type PromotionRedemption struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	PromotionID primitive.ObjectID `json:"promotionId" bson:"promotionId"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	OrderID     primitive.ObjectID `json:"orderId" bson:"orderId"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// SalesLine is what one product, brand or size sold on a day. Revenue is in
+// base currency minor units.
+type SalesLine struct {
+	Key     string `json:"key" bson:"key"`
+	Name    string `json:"name,omitempty" bson:"name,omitempty"`
+	Units   int64  `json:"units" bson:"units"`
+	Revenue int64  `json:"revenue" bson:"revenue"`
+}
+
+// SalesDay is the precomputed rollup of the orders placed on one day, in
+// Indian Standard Time. Amounts are in base currency minor units and count
+// paid orders only; replacement orders for exchanges are left out. Revenue
+// is what the orders were charged and Refunds what has been paid back on
+// them since. A rollup is Stale when an order placed that day was paid or
+// refunded after the rollup was computed.
+type SalesDay struct {
+	Date           string      `json:"date" bson:"_id"` // YYYY-MM-DD
+	Day            time.Time   `json:"day" bson:"day"`  // Start of the day
+	Currency       string      `json:"currency" bson:"currency"`
+	Orders         int64       `json:"orders" bson:"orders"`
+	PaidOrders     int64       `json:"paidOrders" bson:"paidOrders"`
+	FailedPayments int64       `json:"failedPayments" bson:"failedPayments"`
+	Cancelled      int64       `json:"cancelled" bson:"cancelled"`
+	Expired        int64       `json:"expired" bson:"expired"`
+	Revenue        int64       `json:"revenue" bson:"revenue"`
+	Refunds        int64       `json:"refunds" bson:"refunds"`
+	Discounts      int64       `json:"discounts" bson:"discounts"`
+	Tax            int64       `json:"tax" bson:"tax"`
+	Shipping       int64       `json:"shipping" bson:"shipping"`
+	Units          int64       `json:"units" bson:"units"`
+	Returns        int64       `json:"returns" bson:"returns"`
+	ReturnedUnits  int64       `json:"returnedUnits" bson:"returnedUnits"`
+	Products       []SalesLine `json:"products" bson:"products"`
+	Brands         []SalesLine `json:"brands" bson:"brands"`
+	Sizes          []SalesLine `json:"sizes" bson:"sizes"`
+	ComputedAt     time.Time   `json:"computedAt" bson:"computedAt"`
+	Stale          bool        `json:"stale,omitempty" bson:"stale,omitempty"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Return types
+const (
+	ReturnTypeReturn   = "return"   // Items go back for a refund
+	ReturnTypeExchange = "exchange" // Items go back for another size
+)
+
+// Return statuses. A request is approved or rejected, picked up, received,
+// and then refunded or exchanged. The customer can cancel it until it is
+// picked up.
+const (
+	ReturnRequested       = "requested"
+	ReturnApproved        = "approved"
+	ReturnRejected        = "rejected"
+	ReturnPickupScheduled = "pickup_scheduled"
+	ReturnReceived        = "received"
+	ReturnRefunded        = "refunded"
+	ReturnExchanged       = "exchanged"
+	ReturnCancelled       = "cancelled"
+)
+
+// ReturnItem is an order line, or part of one, being sent back. RefundAmount
+// is what the customer paid for it, in the order's currency.
+type ReturnItem struct {
+	ProductID    primitive.ObjectID `json:"productId" bson:"productId"`
+	Name         string             `json:"name" bson:"name"`
+	Size         string             `json:"size,omitempty" bson:"size,omitempty"`
+	Quantity     int                `json:"quantity" bson:"quantity"`
+	ExchangeSize string             `json:"exchangeSize,omitempty" bson:"exchangeSize,omitempty"`
+	RefundAmount Money              `json:"refundAmount" bson:"refundAmount"`
+}
+
+// ReturnPickup is when and how returned items are collected.
+type ReturnPickup struct {
+	ScheduledFor time.Time `json:"scheduledFor" bson:"scheduledFor"`
+	Carrier      string    `json:"carrier,omitempty" bson:"carrier,omitempty"`
+	AWB          string    `json:"awb,omitempty" bson:"awb,omitempty"`
+}
+
+// ReturnEvent records a status change of a return.
+type ReturnEvent struct {
+	Status string    `json:"status" bson:"status"`
+	Note   string    `json:"note,omitempty" bson:"note,omitempty"`
+	At     time.Time `json:"at" bson:"at"`
+}
+
+// Return is a request to send back items of a delivered order (an RMA).
+type Return struct {
+	ID                 primitive.ObjectID  `json:"id" bson:"_id"`
+	OrderID            primitive.ObjectID  `json:"orderId" bson:"orderId"`
+	UserID             primitive.ObjectID  `json:"userId" bson:"userId"`
+	Type               string              `json:"type" bson:"type"`
+	Items              []ReturnItem        `json:"items" bson:"items"`
+	Reason             string              `json:"reason" bson:"reason"`
+	Photos             []string            `json:"photos,omitempty" bson:"photos,omitempty"`
+	Status             string              `json:"status" bson:"status"`
+	RefundAmount       Money               `json:"refundAmount" bson:"refundAmount"`
+	Currency           string              `json:"currency,omitempty" bson:"currency,omitempty"`
+	Pickup             *ReturnPickup       `json:"pickup,omitempty" bson:"pickup,omitempty"`
+	RejectionReason    string              `json:"rejectionReason,omitempty" bson:"rejectionReason,omitempty"`
+	RefundID           *primitive.ObjectID `json:"refundId,omitempty" bson:"refundId,omitempty"`
+	ReplacementOrderID *primitive.ObjectID `json:"replacementOrderId,omitempty" bson:"replacementOrderId,omitempty"`
+	History            []ReturnEvent       `json:"history" bson:"history"`
+	CreatedAt          time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt          time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Review statuses. A review waits for moderation until it is approved and
+// shown, or rejected. A shown review that is reported often enough goes
+// back to pending until an admin looks at it again.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review is a customer's rating of a product they received. OrderID is the
+// delivered order that makes it a verified purchase.
+type Review struct {
+	ID             primitive.ObjectID  `json:"id" bson:"_id"`
+	ProductID      primitive.ObjectID  `json:"productId" bson:"productId"`
+	UserID         primitive.ObjectID  `json:"userId" bson:"userId"`
+	OrderID        primitive.ObjectID  `json:"orderId" bson:"orderId"`
+	AuthorName     string              `json:"authorName" bson:"authorName"`
+	Size           string              `json:"size,omitempty" bson:"size,omitempty"`
+	Rating         int                 `json:"rating" bson:"rating"` // 1 to 5 stars
+	Title          string              `json:"title,omitempty" bson:"title,omitempty"`
+	Text           string              `json:"text,omitempty" bson:"text,omitempty"`
+	Photos         []string            `json:"photos,omitempty" bson:"photos,omitempty"`
+	Status         string              `json:"status" bson:"status"`
+	HelpfulCount   int                 `json:"helpfulCount" bson:"helpfulCount"`
+	ReportCount    int                 `json:"reportCount,omitempty" bson:"reportCount"` // Reports since it was last moderated
+	ModerationNote string              `json:"moderationNote,omitempty" bson:"moderationNote,omitempty"`
+	ModeratedBy    *primitive.ObjectID `json:"-" bson:"moderatedBy,omitempty"`
+	ModeratedAt    *time.Time          `json:"moderatedAt,omitempty" bson:"moderatedAt,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// ReviewVote is a shopper finding a review helpful. A shopper votes once per
+// review.
+type ReviewVote struct {
+	ReviewID  primitive.ObjectID `json:"reviewId" bson:"reviewId"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// ReviewReport is a shopper flagging a review as abusive, spam or off
+// topic. A shopper reports a review once.
+type ReviewReport struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	ReviewID  primitive.ObjectID `json:"reviewId" bson:"reviewId"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	Reason    string             `json:"reason" bson:"reason"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// ProductRating is the denormalized rating of a product over its approved
+// reviews. Histogram counts reviews by stars, keyed "1" to "5"; Total is the
+// sum of their stars.
+type ProductRating struct {
+	Average   float64        `json:"average" bson:"average"`
+	Count     int            `json:"count" bson:"count"`
+	Total     int            `json:"-" bson:"total"`
+	Histogram map[string]int `json:"-" bson:"histogram"`
+}
+
+// RatingBucket is the number of reviews giving a product Stars stars.
+type RatingBucket struct {
+	Stars int `json:"stars"`
+	Count int `json:"count"`
+}
+
+// RatingSummary is the rating shown on a product page.
+type RatingSummary struct {
+	Average   float64        `json:"average"`
+	Count     int            `json:"count"`
+	Histogram []RatingBucket `json:"histogram"` // 5 stars first
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Shipment statuses, in the order a parcel normally moves through them
+const (
+	ShipmentCreated        = "created"
+	ShipmentPickedUp       = "picked_up"
+	ShipmentInTransit      = "in_transit"
+	ShipmentOutForDelivery = "out_for_delivery"
+	ShipmentDelivered      = "delivered"
+	ShipmentFailedDelivery = "failed_delivery"
+	ShipmentReturned       = "returned"
+)
+
+// TrackingEvent is one scan or status update reported for a shipment.
+type TrackingEvent struct {
+	Status      string    `json:"status" bson:"status"`
+	Location    string    `json:"location,omitempty" bson:"location,omitempty"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt" bson:"occurredAt"`
+}
+
+// Shipment is a parcel sent out for an order. An order may ship in several.
+type Shipment struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	OrderID     primitive.ObjectID `json:"orderId" bson:"orderId"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	Carrier     string             `json:"carrier" bson:"carrier"`
+	AWB         string             `json:"awb" bson:"awb"` // Air waybill / tracking number
+	TrackingURL string             `json:"trackingUrl,omitempty" bson:"trackingUrl,omitempty"`
+	Status      string             `json:"status" bson:"status"`
+	ShippedAt   *time.Time         `json:"shippedAt,omitempty" bson:"shippedAt,omitempty"`
+	DeliveredAt *time.Time         `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+	Events      []TrackingEvent    `json:"events" bson:"events"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShippingZone prices delivery to a group of PIN codes. BaseRate covers
+// parcels up to BaseWeightGrams; every further started kilogram costs
+// AdditionalRatePerKg. Orders whose item total reaches FreeShippingThreshold
+// ship free when the threshold is set.
+type ShippingZone struct {
+	ID                    primitive.ObjectID `json:"id" bson:"_id"`
+	Code                  string             `json:"code" bson:"code"`
+	Name                  string             `json:"name" bson:"name"`
+	BaseRate              float64            `json:"baseRate" bson:"baseRate"`
+	BaseWeightGrams       int                `json:"baseWeightGrams" bson:"baseWeightGrams"`
+	AdditionalRatePerKg   float64            `json:"additionalRatePerKg" bson:"additionalRatePerKg"`
+	FreeShippingThreshold float64            `json:"freeShippingThreshold,omitempty" bson:"freeShippingThreshold,omitempty"`
+	MinDays               int                `json:"minDays" bson:"minDays"`
+	MaxDays               int                `json:"maxDays" bson:"maxDays"`
+	UpdatedAt             time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// PinCodeZone assigns a PIN code to a shipping zone. PinCode is either a
+// full six digit PIN code or a three digit prefix covering a sorting
+// district; a full PIN code entry wins over its prefix.
+type PinCodeZone struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	PinCode     string             `json:"pinCode" bson:"pinCode"`
+	ZoneCode    string             `json:"zoneCode" bson:"zoneCode"`
+	Serviceable bool               `json:"serviceable" bson:"serviceable"`
+	// CODAvailable allows cash on delivery to the PIN code
+	CODAvailable bool      `json:"codAvailable" bson:"codAvailable"`
+	City         string    `json:"city,omitempty" bson:"city,omitempty"`
+	State        string    `json:"state,omitempty" bson:"state,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// ShippingQuote is the delivery charge and estimate for a PIN code.
+type ShippingQuote struct {
+	PinCode               string    `json:"pinCode" bson:"pinCode"`
+	ZoneCode              string    `json:"zoneCode,omitempty" bson:"zoneCode,omitempty"`
+	Serviceable           bool      `json:"serviceable" bson:"serviceable"`
+	CODAvailable          bool      `json:"codAvailable" bson:"codAvailable"`
+	WeightGrams           int       `json:"weightGrams" bson:"weightGrams"`
+	Charge                Money     `json:"charge" bson:"charge"`
+	FreeShipping          bool      `json:"freeShipping" bson:"freeShipping"`
+	FreeShippingThreshold *Money    `json:"freeShippingThreshold,omitempty" bson:"freeShippingThreshold,omitempty"`
+	EstimatedDeliveryFrom time.Time `json:"estimatedDeliveryFrom,omitempty" bson:"estimatedDeliveryFrom,omitempty"`
+	EstimatedDeliveryTo   time.Time `json:"estimatedDeliveryTo,omitempty" bson:"estimatedDeliveryTo,omitempty"`
+}
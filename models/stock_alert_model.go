@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StockSubscription asks for a notification when an out of stock product
+// is available again. Stock is kept per product, so subscriptions are too.
+// NotifiedAt is set once the user has been told; subscribing again starts a
+// new wait.
+type StockSubscription struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	ProductID  primitive.ObjectID `json:"productId" bson:"productId"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	NotifiedAt *time.Time         `json:"notifiedAt,omitempty" bson:"notifiedAt,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GST components
+const (
+	TaxCGST = "CGST"
+	TaxSGST = "SGST"
+	TaxIGST = "IGST"
+)
+
+// TaxRate is the GST rate for an HSN code or, when HSNCode is empty, for a
+// product category. Some goods, footwear among them, are taxed at a higher
+// rate above a per-unit price; set ThresholdPrice and RateAboveThreshold for
+// those.
+type TaxRate struct {
+	ID                 primitive.ObjectID `json:"id" bson:"_id"`
+	HSNCode            string             `json:"hsnCode,omitempty" bson:"hsnCode,omitempty"`
+	Category           string             `json:"category,omitempty" bson:"category,omitempty"`
+	Description        string             `json:"description,omitempty" bson:"description,omitempty"`
+	Rate               float64            `json:"rate" bson:"rate"`
+	ThresholdPrice     float64            `json:"thresholdPrice,omitempty" bson:"thresholdPrice,omitempty"`
+	RateAboveThreshold float64            `json:"rateAboveThreshold,omitempty" bson:"rateAboveThreshold,omitempty"`
+	UpdatedAt          time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// TaxLine is one GST component charged on a cart or order, such as CGST at 6%.
+type TaxLine struct {
+	Type   string  `json:"type" bson:"type"`
+	Rate   float64 `json:"rate" bson:"rate"`
+	Amount Money   `json:"amount" bson:"amount"`
+}
+
+// TaxBreakdown is the GST charged on a cart or order. InterState is true
+// when IGST applies because the shipment leaves the origin state.
+type TaxBreakdown struct {
+	OriginState      string    `json:"originState" bson:"originState"`
+	DestinationState string    `json:"destinationState,omitempty" bson:"destinationState,omitempty"`
+	InterState       bool      `json:"interState" bson:"interState"`
+	Estimated        bool      `json:"estimated,omitempty" bson:"estimated,omitempty"`
+	TaxableValue     Money     `json:"taxableValue" bson:"taxableValue"`
+	Lines            []TaxLine `json:"lines" bson:"lines"`
+	Total            Money     `json:"total" bson:"total"`
+}
+
+// ItemTax is the GST worked out for a single cart or order line.
+type ItemTax struct {
+	HSNCode      string  `json:"hsnCode,omitempty" bson:"hsnCode,omitempty"`
+	Rate         float64 `json:"rate" bson:"rate"`
+	TaxableValue Money   `json:"taxableValue" bson:"taxableValue"`
+	Amount       Money   `json:"amount" bson:"amount"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type User struct {
 	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
@@ -12,9 +16,40 @@ type User struct {
 	Address  string             `bson:"address,omitempty" json:"address,omitempty"`
 	Type     string             `bson:"type,omitempty" json:"type,omitempty" validate:"required,oneof=user admin"`
 	Cart     []CartItem         `bson:"cart" json:"cart"`
+	// CouponCode is the coupon currently applied to the cart
+	CouponCode string `bson:"couponCode,omitempty" json:"couponCode,omitempty"`
 }
 
+// CartItem is a single line in a cart. A product appears once per size, and
+// each line carries its own LineID so clients can address it unambiguously.
 type CartItem struct {
-	Product  Product `bson:"product" json:"product" validate:"required"`
-	Quantity int     `bson:"quantity" json:"quantity" validate:"required,min=1"`
+	LineID   primitive.ObjectID `bson:"lineId,omitempty" json:"lineId,omitempty"`
+	Product  Product            `bson:"product" json:"product" validate:"required"`
+	Quantity int                `bson:"quantity" json:"quantity" validate:"required,min=1"`
+	Notices  []CartNotice       `bson:"notices,omitempty" json:"notices,omitempty"`
+	// UnitPrice is the product's price in the shopper's currency. It is set
+	// when the cart is priced and never stored.
+	UnitPrice *Money `bson:"-" json:"unitPrice,omitempty"`
+}
+
+// Cart notice types raised when a cart line no longer matches the catalogue
+const (
+	NoticePriceChanged      = "price_changed"
+	NoticeDiscontinued      = "discontinued"
+	NoticeInsufficientStock = "insufficient_stock"
+	NoticePreOrderChanged   = "preorder_changed"
+)
+
+// CartNotice tells the shopper that a cart line changed since it was added.
+// Checkout is refused while any line still carries a notice.
+type CartNotice struct {
+	Type      string    `bson:"type" json:"type"`
+	Message   string    `bson:"message" json:"message"`
+	// OldPrice and NewPrice are in Currency, the currency the shopper was
+	// shown the change in
+	OldPrice  *Money    `bson:"oldPrice,omitempty" json:"oldPrice,omitempty"`
+	NewPrice  *Money    `bson:"newPrice,omitempty" json:"newPrice,omitempty"`
+	Currency  string    `bson:"currency,omitempty" json:"currency,omitempty"`
+	Available int       `bson:"available,omitempty" json:"available,omitempty"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WishlistItem is a product, in a size or any size, saved for later. Price
+// and InStock are what the shopper was last told: the price when it was
+// saved or last dropped, and its stock when last checked.
+type WishlistItem struct {
+	ProductID primitive.ObjectID `json:"productId" bson:"productId"`
+	Size      string             `json:"size,omitempty" bson:"size"`
+	Price     float64            `json:"price" bson:"price"`
+	InStock   bool               `json:"inStock" bson:"inStock"`
+	AddedAt   time.Time          `json:"addedAt" bson:"addedAt"`
+}
+
+// Wishlist is a user's saved products. ShareToken, when set, lets anyone
+// with the link view it.
+type Wishlist struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	Items      []WishlistItem     `json:"items" bson:"items"`
+	ShareToken string             `json:"-" bson:"shareToken,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
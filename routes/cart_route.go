@@ -8,16 +8,28 @@ import (
 )
 
 func CartRoutes(app *fiber.App) {
-	app.Post("/api/add-to-cart", middlewares.AuthMiddleware, cartController.AddtoCart)
+	app.Post("/api/guest-cart", cartController.CreateGuestCart)
 
-	app.Post("api/add-to-cart-from-cart", middlewares.AuthMiddleware, cartController.AddToCartFromCart)
+	app.Post("/api/add-to-cart", middlewares.CartAuthMiddleware, cartController.AddtoCart)
 
-	app.Post("/api/remove-from-cart", middlewares.AuthMiddleware, cartController.RemoveFromCart)
+	app.Post("api/add-to-cart-from-cart", middlewares.CartAuthMiddleware, cartController.AddToCartFromCart)
 
-	app.Post("/api/decrement-from-cart", middlewares.AuthMiddleware, cartController.DecrementFromCart)
+	app.Post("/api/remove-from-cart", middlewares.CartAuthMiddleware, cartController.RemoveFromCart)
 
-	app.Get("/api/fetchCartItems", middlewares.AuthMiddleware, cartController.GetAllCarts)
+	app.Post("/api/decrement-from-cart", middlewares.CartAuthMiddleware, cartController.DecrementFromCart)
 
-	app.Get("/api/getCartTotal", middlewares.AuthMiddleware, cartController.GetCartTotals)
+	app.Post("/api/set-cart-quantity", middlewares.CartAuthMiddleware, cartController.SetCartItemQuantity)
+
+	app.Post("/api/remove-cart-items", middlewares.CartAuthMiddleware, cartController.RemoveCartItems)
+
+	app.Get("/api/fetchCartItems", middlewares.CartAuthMiddleware, cartController.GetAllCarts)
+
+	app.Get("/api/getCartTotal", middlewares.CartAuthMiddleware, cartController.GetCartTotals)
+
+	app.Post("/api/acknowledge-cart-notices", middlewares.CartAuthMiddleware, cartController.AcknowledgeCartNotices)
+
+	app.Post("/api/apply-coupon", middlewares.CartAuthMiddleware, cartController.ApplyCoupon)
+
+	app.Post("/api/remove-coupon", middlewares.CartAuthMiddleware, cartController.RemoveCoupon)
 
 }
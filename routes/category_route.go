@@ -0,0 +1,19 @@
+package routes
+
+import (
+	categoryController "fiber-mongo-api/controllers/categories"
+	productController "fiber-mongo-api/controllers/products"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CategoryRoutes(app *fiber.App) {
+	app.Get("/api/categories", categoryController.GetCategoryTree)
+	app.Get("/api/categories/products", middlewares.OptionalAuthMiddleware, productController.BrowseCategory)
+
+	app.Post("/api/admin/categories", middlewares.AuthMiddleware, middlewares.AdminMiddleware, categoryController.CreateCategory)
+	app.Put("/api/admin/categories", middlewares.AuthMiddleware, middlewares.AdminMiddleware, categoryController.UpdateCategory)
+	app.Delete("/api/admin/categories", middlewares.AuthMiddleware, middlewares.AdminMiddleware, categoryController.DeleteCategory)
+	app.Put("/api/admin/products/categories", middlewares.AuthMiddleware, middlewares.AdminMiddleware, categoryController.SetProductCategories)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	currencyController "fiber-mongo-api/controllers/currency"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CurrencyRoutes(app *fiber.App) {
+	app.Get("/api/currencies", currencyController.GetCurrencies)
+
+	app.Put("/api/admin/exchange-rates", middlewares.AuthMiddleware, middlewares.AdminMiddleware, currencyController.UpsertExchangeRate)
+	app.Get("/api/admin/exchange-rates", middlewares.AuthMiddleware, middlewares.AdminMiddleware, currencyController.GetExchangeRates)
+	app.Delete("/api/admin/exchange-rates", middlewares.AuthMiddleware, middlewares.AdminMiddleware, currencyController.DeleteExchangeRate)
+	app.Put("/api/admin/price-lists", middlewares.AuthMiddleware, middlewares.AdminMiddleware, currencyController.UpdatePriceList)
+	app.Get("/api/admin/price-lists", middlewares.AuthMiddleware, middlewares.AdminMiddleware, currencyController.GetPriceList)
+}
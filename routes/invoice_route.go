@@ -0,0 +1,12 @@
+package routes
+
+import (
+	invoiceController "fiber-mongo-api/controllers/invoices"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InvoiceRoutes(app *fiber.App) {
+	app.Get("/api/admin/invoices", middlewares.AuthMiddleware, middlewares.AdminMiddleware, invoiceController.GetInvoices)
+}
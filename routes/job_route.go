@@ -0,0 +1,12 @@
+package routes
+
+import (
+	jobController "fiber-mongo-api/controllers/jobs"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func JobRoutes(app *fiber.App) {
+	app.Get("/api/admin/job-runs", middlewares.AuthMiddleware, middlewares.AdminMiddleware, jobController.GetJobRuns)
+}
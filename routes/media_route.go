@@ -0,0 +1,14 @@
+package routes
+
+import (
+	mediaController "fiber-mongo-api/controllers/media"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func MediaRoutes(app *fiber.App) {
+	app.Post("/api/admin/products/images", middlewares.AuthMiddleware, middlewares.AdminMiddleware, mediaController.UploadProductImages)
+	app.Post("/api/upload-profile-image", middlewares.AuthMiddleware, mediaController.UploadProfileImage)
+	app.Post("/api/reviews/photos", middlewares.AuthMiddleware, mediaController.UploadReviewPhotos)
+}
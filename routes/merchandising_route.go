@@ -0,0 +1,24 @@
+package routes
+
+import (
+	merchandisingController "fiber-mongo-api/controllers/merchandising"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func MerchandisingRoutes(app *fiber.App) {
+	app.Get("/api/home", merchandisingController.GetHomeFeed)
+	app.Get("/api/collections/products", merchandisingController.GetCollectionProducts)
+
+	app.Get("/api/admin/collections", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.GetCollections)
+	app.Post("/api/admin/collections", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.CreateCollection)
+	app.Put("/api/admin/collections", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.UpdateCollection)
+	app.Delete("/api/admin/collections", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.DeleteCollection)
+
+	app.Get("/api/admin/home/sections", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.GetSections)
+	app.Post("/api/admin/home/sections", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.CreateSection)
+	app.Put("/api/admin/home/sections", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.UpdateSection)
+	app.Delete("/api/admin/home/sections", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.DeleteSection)
+	app.Get("/api/admin/home/preview", middlewares.AuthMiddleware, middlewares.AdminMiddleware, merchandisingController.PreviewHomeFeed)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	notificationController "fiber-mongo-api/controllers/notifications"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func NotificationRoutes(app *fiber.App) {
+	app.Get("/api/notifications", middlewares.AuthMiddleware, notificationController.GetNotifications)
+	app.Put("/api/notifications/read", middlewares.AuthMiddleware, notificationController.MarkNotificationsRead)
+}
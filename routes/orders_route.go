@@ -8,11 +8,20 @@ import (
 )
 
 func OrderRoutes(app *fiber.App) {
-	app.Post("/api/create-order", middlewares.AuthMiddleware, orderController.CreateOrder)
-	app.Post("/api/verify-payment", middlewares.AuthMiddleware, orderController.VerifyPayment)
+	app.Post("/api/create-order", middlewares.AuthMiddleware, middlewares.IdempotencyMiddleware, orderController.CreateOrder)
+	app.Post("/api/verify-payment", middlewares.AuthMiddleware, middlewares.IdempotencyMiddleware, orderController.VerifyPayment)
 	// app.Get("/api/get-orders-processing", middlewares.AuthMiddleware, orderController.GetProcessingOrders)
 	// app.Get("/api/get-orders-delivered", middlewares.AuthMiddleware, orderController.GetDeliveredOrders)
 	// app.Get("/api/get-orders-cancelled", middlewares.AuthMiddleware, orderController.GetCancelledOrders)
 	app.Get("/api/get-orders", middlewares.AuthMiddleware, orderController.GetOrders)
 	app.Get("/api/get-order", middlewares.AuthMiddleware, orderController.GetOrderById)
+	app.Get("/api/get-order/invoice", middlewares.AuthMiddleware, orderController.DownloadInvoice)
+
+	app.Get("/api/admin/orders", middlewares.AuthMiddleware, middlewares.AdminMiddleware, orderController.AdminGetOrders)
+	app.Get("/api/admin/orders/details", middlewares.AuthMiddleware, middlewares.AdminMiddleware, orderController.AdminGetOrder)
+	app.Get("/api/admin/orders/invoice", middlewares.AuthMiddleware, middlewares.AdminMiddleware, orderController.AdminDownloadInvoice)
+	app.Put("/api/admin/orders/status", middlewares.AuthMiddleware, middlewares.AdminMiddleware, orderController.BulkUpdateOrderStatus)
+	app.Post("/api/admin/orders/notes", middlewares.AuthMiddleware, middlewares.AdminMiddleware, orderController.AddOrderNote)
+	app.Post("/api/admin/orders/refund", middlewares.AuthMiddleware, middlewares.AdminMiddleware, middlewares.IdempotencyMiddleware, orderController.RefundOrder)
+	app.Put("/api/admin/orders/cod-collected", middlewares.AuthMiddleware, middlewares.AdminMiddleware, orderController.MarkCODCollected)
 }
@@ -13,12 +13,25 @@ func ProductsRoute(app *fiber.App) {
 	//For admin add-product
 	app.Post("/api/admin/add-product", middlewares.AuthMiddleware, controllers.AddProduct)
 
+	//For admin bulk import and export of the catalogue
+	app.Post("/api/admin/products/import", middlewares.AuthMiddleware, middlewares.AdminMiddleware, controllers.ImportProducts)
+	app.Get("/api/admin/products/imports", middlewares.AuthMiddleware, middlewares.AdminMiddleware, controllers.GetProductImports)
+	app.Get("/api/admin/products/imports/details", middlewares.AuthMiddleware, middlewares.AdminMiddleware, controllers.GetProductImport)
+	app.Get("/api/admin/products/export", middlewares.AuthMiddleware, middlewares.AdminMiddleware, controllers.ExportProducts)
+
+	//For admin pre-orders
+	app.Put("/api/admin/products/pre-order", middlewares.AuthMiddleware, middlewares.AdminMiddleware, controllers.SetPreOrder)
+	app.Delete("/api/admin/products/pre-order", middlewares.AuthMiddleware, middlewares.AdminMiddleware, controllers.ClearPreOrder)
+
 	//Search products with name
-	app.Get("/api/search", controllers.SearchProducts)
+	app.Get("/api/search", middlewares.OptionalAuthMiddleware, controllers.SearchProducts)
 
 	//Get popular products based on brandName
 	app.Get("api/popularBrand", controllers.GetPopularProducts)
 
 	//Fetch productDetails
-	app.Get("api/details", controllers.FetchProductDetails)
+	app.Get("api/details", middlewares.OptionalAuthMiddleware, controllers.FetchProductDetails)
+
+	//Check delivery to a PIN code from the product page
+	app.Get("api/details/check-pincode", controllers.CheckPinCode)
 }
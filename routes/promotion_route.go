@@ -0,0 +1,14 @@
+package routes
+
+import (
+	promotionController "fiber-mongo-api/controllers/promotions"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func PromotionRoutes(app *fiber.App) {
+	app.Post("/api/admin/promotions", middlewares.AuthMiddleware, middlewares.AdminMiddleware, promotionController.CreatePromotion)
+	app.Get("/api/admin/promotions", middlewares.AuthMiddleware, middlewares.AdminMiddleware, promotionController.GetPromotions)
+	app.Put("/api/admin/promotions", middlewares.AuthMiddleware, middlewares.AdminMiddleware, promotionController.UpdatePromotion)
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	reportController "fiber-mongo-api/controllers/reports"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ReportRoutes(app *fiber.App) {
+	app.Get("/api/admin/reports/revenue", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reportController.GetRevenueReport)
+	app.Get("/api/admin/reports/units", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reportController.GetUnitsReport)
+	app.Get("/api/admin/reports/summary", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reportController.GetSummaryReport)
+	app.Post("/api/admin/reports/rollups", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reportController.RebuildRollups)
+}
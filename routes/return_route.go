@@ -0,0 +1,20 @@
+package routes
+
+import (
+	returnController "fiber-mongo-api/controllers/returns"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ReturnRoutes(app *fiber.App) {
+	app.Post("/api/returns", middlewares.AuthMiddleware, returnController.CreateReturn)
+	app.Get("/api/returns", middlewares.AuthMiddleware, returnController.GetReturns)
+	app.Put("/api/returns/cancel", middlewares.AuthMiddleware, returnController.CancelReturn)
+
+	app.Get("/api/admin/returns", middlewares.AuthMiddleware, middlewares.AdminMiddleware, returnController.GetAllReturns)
+	app.Put("/api/admin/returns/approve", middlewares.AuthMiddleware, middlewares.AdminMiddleware, returnController.ApproveReturn)
+	app.Put("/api/admin/returns/reject", middlewares.AuthMiddleware, middlewares.AdminMiddleware, returnController.RejectReturn)
+	app.Put("/api/admin/returns/pickup", middlewares.AuthMiddleware, middlewares.AdminMiddleware, returnController.SchedulePickup)
+	app.Put("/api/admin/returns/receive", middlewares.AuthMiddleware, middlewares.AdminMiddleware, returnController.ReceiveReturn)
+}
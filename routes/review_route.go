@@ -0,0 +1,25 @@
+package routes
+
+import (
+	reviewController "fiber-mongo-api/controllers/reviews"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ReviewRoutes(app *fiber.App) {
+	app.Get("/api/reviews", reviewController.GetProductReviews)
+	app.Post("/api/reviews", middlewares.AuthMiddleware, reviewController.CreateReview)
+	app.Put("/api/reviews", middlewares.AuthMiddleware, reviewController.UpdateReview)
+	app.Delete("/api/reviews", middlewares.AuthMiddleware, reviewController.DeleteReview)
+	app.Get("/api/my-reviews", middlewares.AuthMiddleware, reviewController.GetMyReviews)
+	app.Post("/api/reviews/helpful", middlewares.AuthMiddleware, reviewController.MarkHelpful)
+	app.Delete("/api/reviews/helpful", middlewares.AuthMiddleware, reviewController.UnmarkHelpful)
+	app.Post("/api/reviews/report", middlewares.AuthMiddleware, reviewController.ReportReview)
+
+	app.Get("/api/admin/reviews", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reviewController.GetReviewQueue)
+	app.Get("/api/admin/reviews/reports", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reviewController.GetReviewReports)
+	app.Put("/api/admin/reviews/approve", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reviewController.ApproveReview)
+	app.Put("/api/admin/reviews/reject", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reviewController.RejectReview)
+	app.Post("/api/admin/reviews/recount", middlewares.AuthMiddleware, middlewares.AdminMiddleware, reviewController.RecountRating)
+}
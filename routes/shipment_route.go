@@ -0,0 +1,15 @@
+package routes
+
+import (
+	shipmentController "fiber-mongo-api/controllers/shipments"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ShipmentRoutes(app *fiber.App) {
+	app.Post("/api/admin/shipments", middlewares.AuthMiddleware, middlewares.AdminMiddleware, shipmentController.CreateShipment)
+	app.Get("/api/admin/shipments", middlewares.AuthMiddleware, middlewares.AdminMiddleware, shipmentController.GetShipments)
+	app.Post("/api/admin/shipments/tracking", middlewares.AuthMiddleware, middlewares.AdminMiddleware, shipmentController.AddTrackingEvent)
+	app.Post("/api/webhooks/carriers/:carrier", shipmentController.CarrierWebhook)
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	shippingController "fiber-mongo-api/controllers/shipping"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ShippingRoutes(app *fiber.App) {
+	app.Put("/api/admin/shipping-zones", middlewares.AuthMiddleware, middlewares.AdminMiddleware, shippingController.UpsertShippingZone)
+	app.Get("/api/admin/shipping-zones", middlewares.AuthMiddleware, middlewares.AdminMiddleware, shippingController.GetShippingZones)
+	app.Put("/api/admin/pin-codes", middlewares.AuthMiddleware, middlewares.AdminMiddleware, shippingController.UpsertPinCodes)
+	app.Get("/api/admin/pin-codes", middlewares.AuthMiddleware, middlewares.AdminMiddleware, shippingController.GetPinCodes)
+	app.Delete("/api/admin/pin-codes", middlewares.AuthMiddleware, middlewares.AdminMiddleware, shippingController.DeletePinCode)
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	stockController "fiber-mongo-api/controllers/stock"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func StockRoutes(app *fiber.App) {
+	app.Get("/api/stock-alerts", middlewares.AuthMiddleware, stockController.GetStockAlerts)
+	app.Post("/api/stock-alerts", middlewares.AuthMiddleware, stockController.SubscribeStockAlert)
+	app.Delete("/api/stock-alerts", middlewares.AuthMiddleware, stockController.UnsubscribeStockAlert)
+
+	//For admin stock updates, which notify back-in-stock subscribers
+	app.Put("/api/admin/products/stock", middlewares.AuthMiddleware, middlewares.AdminMiddleware, stockController.UpdateStock)
+}
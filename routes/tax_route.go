@@ -0,0 +1,14 @@
+package routes
+
+import (
+	taxController "fiber-mongo-api/controllers/tax"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TaxRoutes(app *fiber.App) {
+	app.Put("/api/admin/tax-rates", middlewares.AuthMiddleware, middlewares.AdminMiddleware, taxController.UpsertTaxRate)
+	app.Get("/api/admin/tax-rates", middlewares.AuthMiddleware, middlewares.AdminMiddleware, taxController.GetTaxRates)
+	app.Delete("/api/admin/tax-rates", middlewares.AuthMiddleware, middlewares.AdminMiddleware, taxController.DeleteTaxRate)
+}
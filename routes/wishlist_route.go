@@ -0,0 +1,22 @@
+package routes
+
+import (
+	cartController "fiber-mongo-api/controllers/cart"
+	wishlistController "fiber-mongo-api/controllers/wishlist"
+	"fiber-mongo-api/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func WishlistRoutes(app *fiber.App) {
+	app.Get("/api/wishlist", middlewares.AuthMiddleware, wishlistController.GetWishlist)
+	app.Post("/api/wishlist", middlewares.AuthMiddleware, wishlistController.AddToWishlist)
+	app.Delete("/api/wishlist", middlewares.AuthMiddleware, wishlistController.RemoveFromWishlist)
+
+	app.Post("/api/wishlist/share", middlewares.AuthMiddleware, wishlistController.ShareWishlist)
+	app.Delete("/api/wishlist/share", middlewares.AuthMiddleware, wishlistController.UnshareWishlist)
+	app.Get("/api/wishlist/shared", wishlistController.GetSharedWishlist)
+
+	app.Post("/api/wishlist/move-to-cart", middlewares.AuthMiddleware, cartController.MoveToCart)
+	app.Post("/api/move-to-wishlist", middlewares.AuthMiddleware, cartController.MoveToWishlist)
+}
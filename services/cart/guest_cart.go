@@ -0,0 +1,176 @@
+package cartService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var guestCartCollection *mongo.Collection = configs.GetCollection(configs.DB, "guestCarts")
+var userCollection *mongo.Collection = configs.GetCollection(configs.DB, "users")
+
+// GuestTokenHeader carries the signed guest token on cart requests and on
+// sign-in, where it triggers the merge into the user's cart.
+const GuestTokenHeader = "X-Guest-Token"
+
+var ErrInvalidGuestToken = errors.New("invalid guest token")
+
+// EnsureGuestCartIndexes creates the TTL index that expires abandoned guest
+// carts. It is safe to call on every start.
+func EnsureGuestCartIndexes(ctx context.Context) error {
+	_, err := guestCartCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// CreateGuestCart stores an empty guest cart and returns it.
+func CreateGuestCart(ctx context.Context) (models.GuestCart, error) {
+	now := time.Now()
+	guestCart := models.GuestCart{
+		ID:        primitive.NewObjectID(),
+		Cart:      []models.CartItem{},
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(configs.EnvGuestCartTTL()),
+	}
+
+	if _, err := guestCartCollection.InsertOne(ctx, guestCart); err != nil {
+		return models.GuestCart{}, err
+	}
+	return guestCart, nil
+}
+
+// SaveGuestCart replaces the guest cart's lines and pushes its expiry out by
+// another TTL period.
+func SaveGuestCart(ctx context.Context, guestCartID primitive.ObjectID, cart []models.CartItem) error {
+	now := time.Now()
+	_, err := guestCartCollection.UpdateOne(ctx, bson.M{"_id": guestCartID}, bson.M{"$set": bson.M{
+		"cart":      cart,
+		"updatedAt": now,
+		"expiresAt": now.Add(configs.EnvGuestCartTTL()),
+	}})
+	return err
+}
+
+// FindGuestCart loads a guest cart. It returns mongo.ErrNoDocuments once the
+// cart has expired or been merged.
+func FindGuestCart(ctx context.Context, guestCartID primitive.ObjectID) (models.GuestCart, error) {
+	var guestCart models.GuestCart
+	err := guestCartCollection.FindOne(ctx, bson.M{"_id": guestCartID}).Decode(&guestCart)
+	return guestCart, err
+}
+
+// IssueGuestToken signs a token identifying the guest cart. The token lives
+// as long as the cart would if left untouched.
+func IssueGuestToken(guestCartID primitive.ObjectID) (string, error) {
+	claims := jwt.MapClaims{
+		"guestCartId": guestCartID.Hex(),
+		"exp":         time.Now().Add(configs.EnvGuestCartTTL()).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(configs.EnvJwtSecret()))
+}
+
+// ParseGuestToken validates a guest token and returns the guest cart id.
+func ParseGuestToken(tokenString string) (primitive.ObjectID, error) {
+	claims := &jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidGuestToken
+		}
+		return []byte(configs.EnvJwtSecret()), nil
+	})
+	if err != nil || !token.Valid {
+		return primitive.NilObjectID, ErrInvalidGuestToken
+	}
+
+	guestCartId, ok := (*claims)["guestCartId"].(string)
+	if !ok {
+		return primitive.NilObjectID, ErrInvalidGuestToken
+	}
+
+	guestCartID, err := primitive.ObjectIDFromHex(guestCartId)
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidGuestToken
+	}
+	return guestCartID, nil
+}
+
+// MergeCartItems folds guest lines into the user's cart. Lines for the same
+// product and size are merged keeping the larger quantity, so a shopper who
+// added the same pair on two devices does not end up ordering it twice.
+// Other guest lines are appended after the user's own lines.
+func MergeCartItems(userCart, guestCart []models.CartItem) []models.CartItem {
+	merged := make([]models.CartItem, len(userCart), len(userCart)+len(guestCart))
+	copy(merged, userCart)
+
+	for _, guestItem := range guestCart {
+		found := false
+		for i, cartItem := range merged {
+			if cartItem.Product.ID == guestItem.Product.ID && cartItem.Product.Size == guestItem.Product.Size {
+				if guestItem.Quantity > cartItem.Quantity {
+					merged[i].Quantity = guestItem.Quantity
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			if guestItem.LineID.IsZero() {
+				guestItem.LineID = primitive.NewObjectID()
+			}
+			merged = append(merged, guestItem)
+		}
+	}
+
+	return merged
+}
+
+// MergeGuestCart moves the guest cart into the user's cart and deletes the
+// guest cart. It returns the user's resulting cart. A guest cart that has
+// already expired or been merged leaves the user's cart unchanged.
+func MergeGuestCart(ctx context.Context, userID, guestCartID primitive.ObjectID) ([]models.CartItem, error) {
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	// Claim the guest cart by deleting it first, so two concurrent sign-ins
+	// with the same token cannot both merge it.
+	var guestCart models.GuestCart
+	err := guestCartCollection.FindOneAndDelete(ctx, bson.M{"_id": guestCartID}).Decode(&guestCart)
+	if err == mongo.ErrNoDocuments {
+		return user.Cart, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(guestCart.Cart) == 0 {
+		return user.Cart, nil
+	}
+
+	merged := MergeCartItems(user.Cart, guestCart.Cart)
+	update := bson.M{"cart": merged}
+	// The user's own coupon wins over one applied while browsing as a guest
+	if user.CouponCode == "" && guestCart.CouponCode != "" {
+		update["couponCode"] = guestCart.CouponCode
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": update}); err != nil {
+		// Put the guest cart back so the merge can be retried on the next sign-in
+		guestCartCollection.InsertOne(ctx, guestCart)
+		return nil, err
+	}
+
+	return merged, nil
+}
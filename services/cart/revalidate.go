@@ -0,0 +1,226 @@
+package cartService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	productService "fiber-mongo-api/services/products"
+	"fmt"
+	"maps"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+
+// RevalidateCart compares each cart line's product snapshot with the live
+// product and records a notice on the line for every difference that matters
+// to the shopper: a changed price in the rate's currency, a product that no
+// longer exists, not enough stock for the quantity in the cart, or new
+// pre-order terms. Snapshots are refreshed to the live product so totals are
+// computed from current prices. The cart is modified in place and
+// RevalidateCart reports whether anything changed.
+func RevalidateCart(ctx context.Context, cart []models.CartItem, rate currencyService.Rate) (bool, error) {
+	if len(cart) == 0 {
+		return false, nil
+	}
+
+	productIDs := make([]primitive.ObjectID, 0, len(cart))
+	for _, cartItem := range cart {
+		productIDs = append(productIDs, cartItem.Product.ID)
+	}
+
+	cursor, err := productCollection.Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return false, err
+	}
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return false, err
+	}
+
+	live := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		live[product.ID] = product
+	}
+
+	now := time.Now()
+	changed := false
+	for i := range cart {
+		cartItem := &cart[i]
+
+		product, found := live[cartItem.Product.ID]
+		if !found {
+			changed = setNotice(cartItem, models.CartNotice{
+				Type:      models.NoticeDiscontinued,
+				Message:   fmt.Sprintf("%s is no longer available", cartItem.Product.Name),
+				CreatedAt: now,
+			}) || changed
+			continue
+		}
+
+		// Compare the price the shopper pays, which is the price list entry
+		// for their currency when the product has one
+		oldPrice, newPrice := rate.UnitPrice(cartItem.Product), rate.UnitPrice(product)
+		if oldPrice != newPrice {
+			notice := models.CartNotice{
+				Type:      models.NoticePriceChanged,
+				OldPrice:  &oldPrice,
+				NewPrice:  &newPrice,
+				Currency:  rate.Currency,
+				CreatedAt: now,
+			}
+			// Keep the price the shopper originally saw when the price moves
+			// again before they acknowledge the first change
+			if existing := findNotice(cartItem, models.NoticePriceChanged); existing != nil && existing.Currency == rate.Currency {
+				notice.OldPrice = existing.OldPrice
+			}
+			notice.Message = fmt.Sprintf("Price of %s changed from %s %s to %s %s", product.Name,
+				notice.OldPrice.Decimal(), rate.Currency, notice.NewPrice.Decimal(), rate.Currency)
+			setNotice(cartItem, notice)
+			changed = true
+		}
+
+		if product.Quantity < cartItem.Quantity {
+			changed = setNotice(cartItem, models.CartNotice{
+				Type:      models.NoticeInsufficientStock,
+				Message:   fmt.Sprintf("Only %d of %s left in stock", product.Quantity, product.Name),
+				Available: product.Quantity,
+				CreatedAt: now,
+			}) || changed
+		} else {
+			// The shopper already lowered the quantity or stock came back
+			changed = clearNotice(cartItem, models.NoticeInsufficientStock) || changed
+		}
+
+		// A product that became a pre-order, or whose release or payment
+		// terms changed, ships and is paid differently from what the shopper
+		// chose. A pre-order reaching its release is not a notice.
+		preOrderChanged := !samePreOrder(cartItem.Product.PreOrder, product.PreOrder)
+		if preOrderChanged && productService.PreOrderActive(product, now) {
+			changed = setNotice(cartItem, models.CartNotice{
+				Type: models.NoticePreOrderChanged,
+				Message: fmt.Sprintf("%s is a pre-order releasing on %s, paid %s",
+					product.Name, product.PreOrder.ReleaseDate.Format("2 Jan 2006"), preOrderPayment(product.PreOrder)),
+				CreatedAt: now,
+			}) || changed
+		} else if !productService.PreOrderActive(product, now) {
+			changed = clearNotice(cartItem, models.NoticePreOrderChanged) || changed
+		}
+
+		// Refresh the snapshot but keep the size chosen for this line
+		if cartItem.Product.Price != product.Price || !maps.Equal(cartItem.Product.Prices, product.Prices) ||
+			cartItem.Product.Name != product.Name || preOrderChanged {
+			product.Size = cartItem.Product.Size
+			cartItem.Product = product
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// samePreOrder reports whether two pre-order terms are the same.
+func samePreOrder(a, b *models.PreOrder) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ReleaseDate.Equal(b.ReleaseDate) && a.Payment == b.Payment
+}
+
+// preOrderPayment describes when a pre-order is paid.
+func preOrderPayment(preOrder *models.PreOrder) string {
+	if preOrder.Payment == models.PreOrderAuthorize {
+		return "on dispatch"
+	}
+	return "at checkout"
+}
+
+// PreOrderRelease returns the latest release date among the cart's
+// pre-ordered lines, or nil when none is a pre-order.
+func PreOrderRelease(cart []models.CartItem) *time.Time {
+	var release *time.Time
+	now := time.Now()
+	for _, cartItem := range cart {
+		if !productService.PreOrderActive(cartItem.Product, now) {
+			continue
+		}
+		releaseDate := cartItem.Product.PreOrder.ReleaseDate
+		if release == nil || releaseDate.After(*release) {
+			release = &releaseDate
+		}
+	}
+	return release
+}
+
+// findNotice returns the notice of the given type on the line, if any.
+func findNotice(cartItem *models.CartItem, noticeType string) *models.CartNotice {
+	for i := range cartItem.Notices {
+		if cartItem.Notices[i].Type == noticeType {
+			return &cartItem.Notices[i]
+		}
+	}
+	return nil
+}
+
+// setNotice adds the notice to the line, replacing an earlier notice of the
+// same type. It reports whether the line changed, so a problem that persists
+// across fetches does not rewrite the cart every time.
+func setNotice(cartItem *models.CartItem, notice models.CartNotice) bool {
+	if existing := findNotice(cartItem, notice.Type); existing != nil {
+		if existing.Message == notice.Message {
+			return false
+		}
+		*existing = notice
+		return true
+	}
+	cartItem.Notices = append(cartItem.Notices, notice)
+	return true
+}
+
+// clearNotice removes the notice of the given type from the line and reports
+// whether one was removed.
+func clearNotice(cartItem *models.CartItem, noticeType string) bool {
+	for i := range cartItem.Notices {
+		if cartItem.Notices[i].Type == noticeType {
+			cartItem.Notices = append(cartItem.Notices[:i], cartItem.Notices[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// HasNotices reports whether any cart line has an unacknowledged notice.
+func HasNotices(cart []models.CartItem) bool {
+	for _, cartItem := range cart {
+		if len(cartItem.Notices) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AcknowledgeNotices clears the notices on the given lines, or on every line
+// when lineIDs is empty, and returns how many notices were cleared. Problems
+// that still exist, such as missing stock, are raised again on the next
+// revalidation.
+func AcknowledgeNotices(cart []models.CartItem, lineIDs []primitive.ObjectID) int {
+	selected := make(map[primitive.ObjectID]bool, len(lineIDs))
+	for _, lineID := range lineIDs {
+		selected[lineID] = true
+	}
+
+	cleared := 0
+	for i := range cart {
+		if len(lineIDs) > 0 && !selected[cart[i].LineID] {
+			continue
+		}
+		cleared += len(cart[i].Notices)
+		cart[i].Notices = nil
+	}
+	return cleared
+}
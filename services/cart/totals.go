@@ -0,0 +1,181 @@
+package cartService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	"fiber-mongo-api/services/pricing"
+	promotionService "fiber-mongo-api/services/promotions"
+	shippingService "fiber-mongo-api/services/shipping"
+	taxService "fiber-mongo-api/services/tax"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PlatformFeeRate is charged on the item total after discounts (0.2%).
+const PlatformFeeRate = 0.002
+
+// TotalsInput is everything the cart totals depend on besides the cart lines.
+type TotalsInput struct {
+	Cart       []models.CartItem
+	CouponCode string
+	// UserID is nil for guest carts; per-user coupon limits are then
+	// checked at checkout instead.
+	UserID *primitive.ObjectID
+	// Address is the shipping address. Without one, tax is estimated and
+	// shipping is left out.
+	Address *models.Address
+	// Currency is the currency the shopper pays in; empty means the base
+	// currency.
+	Currency string
+}
+
+// Totals is the priced breakdown of a cart. GetCartTotals shows it and
+// CreateOrder charges and stores it, so both always agree.
+type Totals struct {
+	Subtotal      models.Money             `json:"totalPrice"`
+	Discounts     []models.AppliedDiscount `json:"discounts"`
+	DiscountTotal models.Money             `json:"discountTotal"`
+	CouponCode    string                   `json:"couponCode,omitempty"`
+	CouponError   string                   `json:"couponError,omitempty"`
+	Tax           models.TaxBreakdown      `json:"tax"`
+	Shipping      *models.ShippingQuote    `json:"shipping,omitempty"`
+	ShippingError string                   `json:"shippingError,omitempty"`
+	PlatformFee   models.Money             `json:"platformFee"`
+	GrandTotal    models.Money             `json:"grandTotal"` // The amount charged
+	// The amounts above are in Currency; Base is the breakdown in the base
+	// currency.
+	Currency string             `json:"currency"`
+	Base     models.BaseAmounts `json:"base"`
+	// ItemPrices, ItemTaxes and ItemBaseTotals hold each cart line's unit
+	// price, its tax, and its value with tax in base currency minor units, in
+	// cart order
+	ItemPrices     []models.Money   `json:"-"`
+	ItemTaxes      []models.ItemTax `json:"-"`
+	ItemBaseTotals []int64          `json:"-"`
+}
+
+// platformFee is PlatformFeeRate of an amount in minor units.
+func platformFee(amount int64) int64 {
+	return int64(math.Round(float64(amount) * PlatformFeeRate))
+}
+
+// ComputeTotals prices the cart: item subtotal, promotions, GST, shipping,
+// platform fee and grand total, all in the shopper's currency. It returns
+// currencyService.ErrUnsupportedCurrency or ErrNoExchangeRate when the
+// currency cannot be priced.
+func ComputeTotals(ctx context.Context, input TotalsInput) (Totals, error) {
+	var totals Totals
+
+	rate, err := currencyService.LoadRate(ctx, input.Currency)
+	if err != nil {
+		return totals, err
+	}
+	totals.Currency = rate.Currency
+
+	cart := rate.PriceCart(input.Cart)
+	var subtotal int64
+	totals.ItemPrices = make([]models.Money, len(cart))
+	for i, cartItem := range cart {
+		subtotal += currencyService.LineTotal(cartItem)
+		totals.ItemPrices[i] = *cartItem.UnitPrice
+	}
+	totals.Subtotal = rate.Money(subtotal)
+
+	evaluation, err := promotionService.Evaluate(ctx, cart, input.CouponCode, input.UserID, rate)
+	if err != nil {
+		return totals, err
+	}
+	totals.Discounts = evaluation.Discounts
+	totals.DiscountTotal = evaluation.DiscountTotal
+	totals.CouponCode = promotionService.NormalizeCode(input.CouponCode)
+	totals.CouponError = evaluation.CouponError
+	if totals.Discounts == nil {
+		totals.Discounts = []models.AppliedDiscount{}
+	}
+
+	discount := totals.DiscountTotal.Amount
+	itemTotal := subtotal - discount
+
+	// GST is charged on the discounted value. Discounts are spread over the
+	// lines in proportion to their value, the last line taking what rounding
+	// leaves so the shares add up to the discount.
+	// GST slabs are set in the base currency, so the slab is picked from the
+	// base price.
+	lineValues := make([]int64, len(cart))
+	for i, cartItem := range cart {
+		lineValues[i] = currencyService.LineTotal(cartItem)
+	}
+	shares := pricing.Apportion(discount, lineValues)
+	taxableLines := make([]taxService.TaxableLine, len(cart))
+	for i, cartItem := range cart {
+		taxableLines[i] = taxService.TaxableLine{
+			HSNCode:      cartItem.Product.HSNCode,
+			Category:     cartItem.Product.Category,
+			UnitPrice:    currencyService.BasePrice(cartItem.Product),
+			TaxableValue: lineValues[i] - shares[i],
+		}
+	}
+
+	destinationState := ""
+	if input.Address != nil {
+		destinationState = input.Address.State
+	}
+	totals.Tax, totals.ItemTaxes, err = taxService.Compute(ctx, taxableLines, destinationState, rate.Currency)
+	if err != nil {
+		return totals, err
+	}
+
+	// Shipping rates and free shipping thresholds are in the base currency
+	var shippingCharge int64
+	if input.Address != nil && len(cart) > 0 {
+		quote, err := shippingService.Quote(ctx, input.Address.ZipCode, shippingService.ParcelWeight(cart), rate.ToBase(rate.Money(itemTotal)))
+		quote.Charge = rate.FromBase(quote.Charge)
+		if quote.FreeShippingThreshold != nil {
+			threshold := rate.FromBase(*quote.FreeShippingThreshold)
+			quote.FreeShippingThreshold = &threshold
+		}
+		switch err {
+		case nil:
+			shippingCharge = quote.Charge.Amount
+		case shippingService.ErrNotServiceable, shippingService.ErrInvalidPinCode:
+			totals.ShippingError = err.Error()
+		default:
+			return totals, err
+		}
+		totals.Shipping = &quote
+	}
+
+	totals.PlatformFee = rate.Money(platformFee(itemTotal))
+	totals.GrandTotal = rate.Money(itemTotal + totals.Tax.Total.Amount + shippingCharge + totals.PlatformFee.Amount)
+
+	totals.Base = baseAmounts(rate, totals, shippingCharge)
+	totals.ItemBaseTotals = make([]int64, len(cart))
+	for i, itemTax := range totals.ItemTaxes {
+		totals.ItemBaseTotals[i] = rate.ToBase(rate.Money(itemTax.TaxableValue.Amount + itemTax.Amount.Amount)).Amount
+	}
+
+	return totals, nil
+}
+
+// baseAmounts converts the totals into base currency minor units. The total
+// is the sum of the converted parts so the books always add up.
+func baseAmounts(rate currencyService.Rate, totals Totals, shippingCharge int64) models.BaseAmounts {
+	toBase := func(amount int64) int64 {
+		return rate.ToBase(rate.Money(amount)).Amount
+	}
+
+	amounts := models.BaseAmounts{
+		Currency:      configs.EnvBaseCurrency(),
+		ExchangeRate:  rate.Rate,
+		Subtotal:      toBase(totals.Subtotal.Amount),
+		DiscountTotal: toBase(totals.DiscountTotal.Amount),
+		Tax:           toBase(totals.Tax.Total.Amount),
+		Shipping:      toBase(shippingCharge),
+		PlatformFee:   toBase(totals.PlatformFee.Amount),
+	}
+	amounts.Total = amounts.Subtotal - amounts.DiscountTotal + amounts.Tax + amounts.Shipping + amounts.PlatformFee
+	return amounts
+}
@@ -0,0 +1,477 @@
+package categoryService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	mediaService "fiber-mongo-api/services/media"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var categoryCollection *mongo.Collection = configs.GetCollection(configs.DB, "categories")
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrParentNotFound   = errors.New("parent category not found")
+	ErrNameRequired     = errors.New("a name is required")
+	ErrInvalidSlug      = errors.New("slugs may only hold lowercase letters, digits and hyphens")
+	ErrSlugTaken        = errors.New("another category has this slug")
+	ErrInvalidImage     = errors.New("image must be an http or https URL or a path on this server")
+	ErrCycle            = errors.New("a category cannot be moved under itself or its descendants")
+	ErrHasChildren      = errors.New("move or delete the subcategories first")
+	ErrProductNotFound  = errors.New("product not found")
+)
+
+var validSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+var slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Input is the editable part of a category. An empty slug is made from the
+// name.
+type Input struct {
+	Name        string
+	Slug        string
+	Description string
+	Image       string
+	ParentID    *primitive.ObjectID
+	Position    int
+}
+
+// Node is a category with its subcategories, in order.
+type Node struct {
+	models.Category
+	Children []*Node `json:"children"`
+}
+
+// EnsureIndexes makes slugs unique and indexes categories by parent and by
+// ancestor, and products by category. It is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := categoryCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "parentId", Value: 1}, {Key: "position", Value: 1}}},
+		{Keys: bson.D{{Key: "ancestors", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = productCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "categoryIds", Value: 1}},
+	})
+	return err
+}
+
+// Slugify turns a name into a slug: "Men's Running Shoes" becomes
+// "men-s-running-shoes".
+func Slugify(name string) string {
+	return strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// ValidSlug reports whether slug is lowercase words joined by hyphens.
+func ValidSlug(slug string) bool {
+	return validSlug.MatchString(slug)
+}
+
+// clean trims and checks the input, making the slug when none was given.
+func (in *Input) clean() error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Slug = strings.TrimSpace(in.Slug)
+	in.Description = strings.TrimSpace(in.Description)
+	in.Image = strings.TrimSpace(in.Image)
+	if in.Name == "" {
+		return ErrNameRequired
+	}
+	if in.Slug == "" {
+		in.Slug = Slugify(in.Name)
+	}
+	if !validSlug.MatchString(in.Slug) {
+		return ErrInvalidSlug
+	}
+	if in.Image != "" && !mediaService.ValidURL(in.Image) {
+		return ErrInvalidImage
+	}
+	return nil
+}
+
+// Get returns the category with the id.
+func Get(ctx context.Context, id primitive.ObjectID) (models.Category, error) {
+	var category models.Category
+	err := categoryCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return category, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// BySlug returns the category with the slug.
+func BySlug(ctx context.Context, slug string) (models.Category, error) {
+	var category models.Category
+	err := categoryCollection.FindOne(ctx, bson.M{"slug": slug}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return category, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// ancestorsBelow returns the ancestors of a category placed under parent.
+func ancestorsBelow(ctx context.Context, parent *primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if parent == nil {
+		return []primitive.ObjectID{}, nil
+	}
+	category, err := Get(ctx, *parent)
+	if err == ErrCategoryNotFound {
+		return nil, ErrParentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return append(category.Ancestors, category.ID), nil
+}
+
+// Create adds a category under its parent, or at the root.
+func Create(ctx context.Context, in Input) (models.Category, error) {
+	if err := in.clean(); err != nil {
+		return models.Category{}, err
+	}
+	ancestors, err := ancestorsBelow(ctx, in.ParentID)
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	now := time.Now()
+	category := models.Category{
+		ID:          primitive.NewObjectID(),
+		Name:        in.Name,
+		Slug:        in.Slug,
+		Description: in.Description,
+		Image:       in.Image,
+		ParentID:    in.ParentID,
+		Ancestors:   ancestors,
+		Position:    in.Position,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	_, err = categoryCollection.InsertOne(ctx, category)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.Category{}, ErrSlugTaken
+	}
+	return category, err
+}
+
+// Update replaces the editable fields of a category. Moving it to another
+// parent moves its whole subtree along.
+func Update(ctx context.Context, id primitive.ObjectID, in Input) (models.Category, error) {
+	if err := in.clean(); err != nil {
+		return models.Category{}, err
+	}
+
+	var updated models.Category
+	err := configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		category, err := Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		ancestors, err := ancestorsBelow(ctx, in.ParentID)
+		if err != nil {
+			return err
+		}
+		for _, ancestor := range ancestors {
+			if ancestor == id {
+				return ErrCycle
+			}
+		}
+		if in.ParentID != nil && *in.ParentID == id {
+			return ErrCycle
+		}
+
+		set := bson.M{
+			"name":      in.Name,
+			"slug":      in.Slug,
+			"ancestors": ancestors,
+			"position":  in.Position,
+			"updatedAt": time.Now(),
+		}
+		unset := bson.M{}
+		optional := func(field string, value interface{}, empty bool) {
+			if empty {
+				unset[field] = ""
+			} else {
+				set[field] = value
+			}
+		}
+		optional("description", in.Description, in.Description == "")
+		optional("image", in.Image, in.Image == "")
+		optional("parentId", in.ParentID, in.ParentID == nil)
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		err = categoryCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrSlugTaken
+		} else if err != nil {
+			return err
+		}
+
+		if !sameIDs(category.Ancestors, ancestors) {
+			return moveSubtree(ctx, id, append(ancestors, id))
+		}
+		return nil
+	})
+	return updated, err
+}
+
+func sameIDs(a, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// moveSubtree rewrites the ancestors of every category below id, whose own
+// path from the root is now path.
+func moveSubtree(ctx context.Context, id primitive.ObjectID, path []primitive.ObjectID) error {
+	cursor, err := categoryCollection.Find(ctx, bson.M{"ancestors": id})
+	if err != nil {
+		return err
+	}
+	var descendants []models.Category
+	if err := cursor.All(ctx, &descendants); err != nil {
+		return err
+	}
+
+	var writes []mongo.WriteModel
+	for _, descendant := range descendants {
+		for i, ancestor := range descendant.Ancestors {
+			if ancestor != id {
+				continue
+			}
+			ancestors := append(append([]primitive.ObjectID{}, path...), descendant.Ancestors[i+1:]...)
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": descendant.ID}).
+				SetUpdate(bson.M{"$set": bson.M{"ancestors": ancestors}}))
+			break
+		}
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	_, err = categoryCollection.BulkWrite(ctx, writes)
+	return err
+}
+
+// Delete removes a category without subcategories and takes its products
+// out of it.
+func Delete(ctx context.Context, id primitive.ObjectID) error {
+	return configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		children, err := categoryCollection.CountDocuments(ctx, bson.M{"parentId": id})
+		if err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrHasChildren
+		}
+
+		result, err := categoryCollection.DeleteOne(ctx, bson.M{"_id": id})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return ErrCategoryNotFound
+		}
+
+		_, err = productCollection.UpdateMany(ctx, bson.M{"categoryIds": id}, bson.M{
+			"$pull": bson.M{"categoryIds": id},
+		})
+		return err
+	})
+}
+
+func all(ctx context.Context) ([]models.Category, error) {
+	cursor, err := categoryCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	categories := []models.Category{}
+	err = cursor.All(ctx, &categories)
+	return categories, err
+}
+
+// Tree returns the root categories with their subcategories, each level
+// ordered by position and then name.
+func Tree(ctx context.Context) ([]*Node, error) {
+	categories, err := all(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[primitive.ObjectID]*Node, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &Node{Category: category, Children: []*Node{}}
+	}
+	roots := []*Node{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID == nil {
+			roots = append(roots, node)
+		} else if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	var order func(nodes []*Node)
+	order = func(nodes []*Node) {
+		sort.Slice(nodes, func(i, j int) bool {
+			if nodes[i].Position != nodes[j].Position {
+				return nodes[i].Position < nodes[j].Position
+			}
+			return nodes[i].Name < nodes[j].Name
+		})
+		for _, node := range nodes {
+			order(node.Children)
+		}
+	}
+	order(roots)
+	return roots, nil
+}
+
+// Breadcrumbs returns the ancestors of a category from the root down.
+func Breadcrumbs(ctx context.Context, category models.Category) ([]models.Category, error) {
+	if len(category.Ancestors) == 0 {
+		return []models.Category{}, nil
+	}
+	cursor, err := categoryCollection.Find(ctx, bson.M{"_id": bson.M{"$in": category.Ancestors}})
+	if err != nil {
+		return nil, err
+	}
+	var found []models.Category
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]models.Category, len(found))
+	for _, ancestor := range found {
+		byID[ancestor.ID] = ancestor
+	}
+	breadcrumbs := []models.Category{}
+	for _, id := range category.Ancestors {
+		if ancestor, ok := byID[id]; ok {
+			breadcrumbs = append(breadcrumbs, ancestor)
+		}
+	}
+	return breadcrumbs, nil
+}
+
+// Subtree returns the ids of a category and every category below it.
+func Subtree(ctx context.Context, id primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := categoryCollection.Find(ctx, bson.M{"ancestors": id},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var descendants []models.Category
+	if err := cursor.All(ctx, &descendants); err != nil {
+		return nil, err
+	}
+
+	ids := []primitive.ObjectID{id}
+	for _, descendant := range descendants {
+		ids = append(ids, descendant.ID)
+	}
+	return ids, nil
+}
+
+// Slugs maps every category id to its slug.
+func Slugs(ctx context.Context) (map[primitive.ObjectID]string, error) {
+	categories, err := all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slugs := make(map[primitive.ObjectID]string, len(categories))
+	for _, category := range categories {
+		slugs[category.ID] = category.Slug
+	}
+	return slugs, nil
+}
+
+// IDsBySlug maps the slugs that exist to their category ids.
+func IDsBySlug(ctx context.Context, slugs []string) (map[string]primitive.ObjectID, error) {
+	ids := map[string]primitive.ObjectID{}
+	if len(slugs) == 0 {
+		return ids, nil
+	}
+	cursor, err := categoryCollection.Find(ctx, bson.M{"slug": bson.M{"$in": slugs}},
+		options.Find().SetProjection(bson.M{"slug": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var categories []models.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	for _, category := range categories {
+		ids[category.Slug] = category.ID
+	}
+	return ids, nil
+}
+
+// CheckIDs returns ErrCategoryNotFound unless every id is a category.
+func CheckIDs(ctx context.Context, ids []primitive.ObjectID) error {
+	unique := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	count, err := categoryCollection.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+	if int(count) != len(unique) {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// SetProductCategories places a product in exactly the given categories.
+func SetProductCategories(ctx context.Context, productID primitive.ObjectID, ids []primitive.ObjectID) error {
+	unique := []primitive.ObjectID{}
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	if err := CheckIDs(ctx, unique); err != nil {
+		return err
+	}
+
+	result, err := productCollection.UpdateOne(ctx, bson.M{"_id": productID}, bson.M{
+		"$set": bson.M{"categoryIds": unique},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package currencyService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var exchangeRateCollection *mongo.Collection = configs.GetCollection(configs.DB, "exchangeRates")
+
+var (
+	ErrUnsupportedCurrency = errors.New("Currency is not supported")
+	ErrNoExchangeRate      = errors.New("No exchange rate is configured for this currency")
+)
+
+// Currency describes a currency shoppers can pay in.
+type Currency struct {
+	Code   string `json:"code"`
+	Symbol string `json:"symbol"`
+	// Exponent is the number of minor units digits, 2 for paise and cents
+	Exponent int `json:"exponent"`
+}
+
+// supportedCurrencies are the currencies Razorpay settles that we price in.
+var supportedCurrencies = map[string]Currency{
+	"INR": {Code: "INR", Symbol: "₹", Exponent: 2},
+	"USD": {Code: "USD", Symbol: "$", Exponent: 2},
+	"EUR": {Code: "EUR", Symbol: "€", Exponent: 2},
+	"GBP": {Code: "GBP", Symbol: "£", Exponent: 2},
+	"AED": {Code: "AED", Symbol: "AED", Exponent: 2},
+	"SGD": {Code: "SGD", Symbol: "S$", Exponent: 2},
+	"AUD": {Code: "AUD", Symbol: "A$", Exponent: 2},
+	"CAD": {Code: "CAD", Symbol: "C$", Exponent: 2},
+	"JPY": {Code: "JPY", Symbol: "¥", Exponent: 0},
+}
+
+// NormalizeCode upper-cases and trims a currency code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Lookup returns the currency for a code.
+func Lookup(code string) (Currency, bool) {
+	currency, ok := supportedCurrencies[NormalizeCode(code)]
+	return currency, ok
+}
+
+// Supported lists the supported currencies.
+func Supported() []Currency {
+	currencies := make([]Currency, 0, len(supportedCurrencies))
+	for _, currency := range supportedCurrencies {
+		currencies = append(currencies, currency)
+	}
+	return currencies
+}
+
+// EnsureIndexes makes exchange rates unique per currency. It is safe to call
+// on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := exchangeRateCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "currency", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func pow10(exponent int) float64 {
+	return math.Pow(10, float64(exponent))
+}
+
+// ToMinor converts an amount in major units, as entered by an admin or sent
+// by a client, to minor units of the currency.
+func ToMinor(amount float64, code string) int64 {
+	return int64(math.Round(amount * pow10(models.MinorDigits(code))))
+}
+
+// FromMinor converts an amount in minor units to major units of the
+// currency, for display only.
+func FromMinor(amount int64, code string) float64 {
+	return float64(amount) / pow10(models.MinorDigits(code))
+}
+
+// BaseMoney is an amount in major units of the base currency, such as a
+// shipping rate or coupon value set by an admin, as Money.
+func BaseMoney(amount float64) models.Money {
+	base := configs.EnvBaseCurrency()
+	return models.Money{Amount: ToMinor(amount, base), Currency: base}
+}
+
+// BasePrice is the product's catalogue price as Money.
+func BasePrice(product models.Product) models.Money {
+	return BaseMoney(product.Price)
+}
+
+// Rate converts between the base currency and Currency.
+type Rate struct {
+	Currency string
+	// Rate is how many units of Currency one base unit buys; 1 for the base
+	// currency itself
+	Rate float64
+}
+
+// BaseRate is the identity rate for pricing in the base currency.
+func BaseRate() Rate {
+	return Rate{Currency: configs.EnvBaseCurrency(), Rate: 1}
+}
+
+// IsBase reports whether the rate is for the base currency.
+func (r Rate) IsBase() bool {
+	return r.Currency == configs.EnvBaseCurrency()
+}
+
+// Money is amount minor units of Currency as Money.
+func (r Rate) Money(amount int64) models.Money {
+	return models.Money{Amount: amount, Currency: r.Currency}
+}
+
+// convert multiplies an amount in minor units of one currency by rate and
+// rounds it to minor units of another.
+func convert(amount int64, rate float64, from, to string) int64 {
+	return int64(math.Round(float64(amount) * rate * pow10(models.MinorDigits(to)-models.MinorDigits(from))))
+}
+
+// FromBase converts an amount in the base currency to Currency.
+func (r Rate) FromBase(amount models.Money) models.Money {
+	if r.Rate == 0 || r.IsBase() {
+		return amount
+	}
+	return r.Money(convert(amount.Amount, r.Rate, amount.Currency, r.Currency))
+}
+
+// ToBase converts an amount in Currency to the base currency.
+func (r Rate) ToBase(amount models.Money) models.Money {
+	if r.Rate == 0 || r.IsBase() {
+		return amount
+	}
+	base := configs.EnvBaseCurrency()
+	return models.Money{Amount: convert(amount.Amount, 1/r.Rate, amount.Currency, base), Currency: base}
+}
+
+// UnitPrice is what one unit of the product costs in Currency: its price
+// list entry if it has one, otherwise its base price converted.
+func (r Rate) UnitPrice(product models.Product) models.Money {
+	if r.IsBase() {
+		return BasePrice(product)
+	}
+	if price, ok := product.Prices[r.Currency]; ok && price > 0 {
+		return r.Money(price)
+	}
+	return r.FromBase(BasePrice(product))
+}
+
+// PriceCart returns a copy of the cart with every line's UnitPrice set in
+// Currency. The stored cart is never priced.
+func (r Rate) PriceCart(cart []models.CartItem) []models.CartItem {
+	priced := make([]models.CartItem, len(cart))
+	for i, cartItem := range cart {
+		price := r.UnitPrice(cartItem.Product)
+		priced[i] = cartItem
+		priced[i].UnitPrice = &price
+	}
+	return priced
+}
+
+// LineTotal is the price of a priced cart line: its unit price times the
+// quantity, in minor units.
+func LineTotal(cartItem models.CartItem) int64 {
+	return cartItem.UnitPrice.Amount * int64(cartItem.Quantity)
+}
+
+// LoadRate returns the rate for a currency code. An empty code means the base
+// currency.
+func LoadRate(ctx context.Context, code string) (Rate, error) {
+	code = NormalizeCode(code)
+	if code == "" || code == configs.EnvBaseCurrency() {
+		return BaseRate(), nil
+	}
+	if _, ok := Lookup(code); !ok {
+		return Rate{}, ErrUnsupportedCurrency
+	}
+
+	var exchangeRate models.ExchangeRate
+	err := exchangeRateCollection.FindOne(ctx, bson.M{"currency": code}).Decode(&exchangeRate)
+	if err == mongo.ErrNoDocuments {
+		return Rate{}, ErrNoExchangeRate
+	} else if err != nil {
+		return Rate{}, err
+	}
+	return Rate{Currency: code, Rate: exchangeRate.Rate}, nil
+}
@@ -0,0 +1,128 @@
+package idempotencyService
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var idempotencyCollection *mongo.Collection = configs.GetCollection(configs.DB, "idempotencyKeys")
+
+// KeyHeader is the request header clients put the idempotency key in.
+const KeyHeader = "Idempotency-Key"
+
+// MaxKeyLength bounds the keys clients may send.
+const MaxKeyLength = 255
+
+// lockDuration is how long a request may run before another request with the
+// same key is allowed to take over, for example after a crash.
+const lockDuration = time.Minute
+
+var (
+	ErrKeyReused  = errors.New("Idempotency key was already used for a different request")
+	ErrInProgress = errors.New("A request with this idempotency key is still being processed")
+)
+
+// EnsureIndexes creates the TTL index that forgets old keys. It is safe to
+// call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := idempotencyCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// RequestHash fingerprints a request so a reused key with a different body
+// can be told apart from a retry.
+func RequestHash(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordID scopes a key to its owner, so two users cannot collide on or
+// replay each other's keys.
+func recordID(owner, key string) string {
+	sum := sha256.Sum256([]byte(owner + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Begin claims the key for a request. It returns nil when the request should
+// run, the completed record when its response should be replayed, or
+// ErrKeyReused or ErrInProgress.
+func Begin(ctx context.Context, owner, key, method, path, requestHash string) (*models.IdempotencyRecord, error) {
+	now := time.Now()
+	record := models.IdempotencyRecord{
+		ID:          recordID(owner, key),
+		Owner:       owner,
+		Key:         key,
+		Method:      method,
+		Path:        path,
+		RequestHash: requestHash,
+		State:       models.IdempotencyProcessing,
+		LockedUntil: now.Add(lockDuration),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(configs.EnvIdempotencyKeyTTL()),
+	}
+
+	_, err := idempotencyCollection.InsertOne(ctx, record)
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	var existing models.IdempotencyRecord
+	if err := idempotencyCollection.FindOne(ctx, bson.M{"_id": record.ID}).Decode(&existing); err != nil {
+		return nil, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, ErrKeyReused
+	}
+	if existing.State == models.IdempotencyCompleted {
+		return &existing, nil
+	}
+
+	// Take over a request whose lock ran out; its handler never finished
+	result, err := idempotencyCollection.UpdateOne(ctx, bson.M{
+		"_id":         record.ID,
+		"state":       models.IdempotencyProcessing,
+		"lockedUntil": bson.M{"$lt": now},
+	}, bson.M{"$set": bson.M{"lockedUntil": record.LockedUntil}})
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount == 0 {
+		return nil, ErrInProgress
+	}
+	return nil, nil
+}
+
+// Complete stores the response sent for the key so retries get it back.
+func Complete(ctx context.Context, owner, key string, status int, contentType string, body []byte) error {
+	_, err := idempotencyCollection.UpdateOne(ctx, bson.M{"_id": recordID(owner, key)}, bson.M{"$set": bson.M{
+		"state":          models.IdempotencyCompleted,
+		"responseStatus": status,
+		"contentType":    contentType,
+		"responseBody":   body,
+	}})
+	return err
+}
+
+// Abandon forgets the key so the request can be retried, for example after a
+// server error.
+func Abandon(ctx context.Context, owner, key string) error {
+	_, err := idempotencyCollection.DeleteOne(ctx, bson.M{"_id": recordID(owner, key)})
+	return err
+}
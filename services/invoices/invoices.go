@@ -0,0 +1,213 @@
+package invoiceService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var invoiceCollection *mongo.Collection = configs.GetCollection(configs.DB, "invoices")
+var orderCollection *mongo.Collection = configs.GetCollection(configs.DB, "orders")
+var userCollection *mongo.Collection = configs.GetCollection(configs.DB, "users")
+
+// numberAttempts bounds how often Issue retries when another invoice took
+// the number it picked.
+const numberAttempts = 20
+
+var (
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrNotInvoiceable  = errors.New("invoices are issued once an order is paid")
+	ErrInvoiceNotFound = errors.New("invoice not found")
+)
+
+// ist is Indian Standard Time, which financial years and invoice dates
+// follow.
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+// FinancialYear returns the Indian financial year t falls in, e.g. "2025-26"
+// for any day from 1 April 2025 to 31 March 2026.
+func FinancialYear(t time.Time) string {
+	t = t.In(ist)
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// EnsureIndexes makes sequence numbers unique per financial year, which is
+// what keeps invoice numbers free of duplicates, and allows one invoice per
+// order. It is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := invoiceCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "financialYear", Value: 1}, {Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "orderId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "issuedAt", Value: -1}}},
+	})
+	return err
+}
+
+// ForOrder returns the order's invoice, or ErrInvoiceNotFound.
+func ForOrder(ctx context.Context, orderID primitive.ObjectID) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := invoiceCollection.FindOne(ctx, bson.M{"orderId": orderID}).Decode(&invoice)
+	if err == mongo.ErrNoDocuments {
+		return invoice, ErrInvoiceNotFound
+	}
+	return invoice, err
+}
+
+// nextSequence is one past the highest sequence issued in the financial year.
+func nextSequence(ctx context.Context, financialYear string) (int64, error) {
+	var last models.Invoice
+	err := invoiceCollection.FindOne(ctx, bson.M{"financialYear": financialYear},
+		options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}}).SetProjection(bson.M{"sequence": 1}),
+	).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 1, nil
+	}
+	return last.Sequence + 1, err
+}
+
+// Issue returns the invoice of a paid order, issuing it first if the order
+// has none. A number only counts as used once its invoice is stored, and the
+// unique index turns a race for the same number into a retry with the next
+// one, so numbers have neither gaps nor duplicates without needing a
+// counter. Replacement orders for exchanges are not invoiced.
+func Issue(ctx context.Context, orderID primitive.ObjectID) (models.Invoice, error) {
+	if invoice, err := ForOrder(ctx, orderID); err != ErrInvoiceNotFound {
+		return invoice, err
+	}
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Invoice{}, ErrOrderNotFound
+		}
+		return models.Invoice{}, err
+	}
+	if order.PaymentStatus != "completed" || order.PaymentMethod == models.PaymentExchange {
+		return models.Invoice{}, ErrNotInvoiceable
+	}
+
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"_id": order.UserID}).Decode(&user); err != nil && err != mongo.ErrNoDocuments {
+		return models.Invoice{}, err
+	}
+
+	invoice := build(order, user, time.Now())
+	for attempt := 1; ; attempt++ {
+		sequence, err := nextSequence(ctx, invoice.FinancialYear)
+		if err != nil {
+			return invoice, err
+		}
+		invoice.ID = primitive.NewObjectID()
+		invoice.Sequence = sequence
+		invoice.Number = fmt.Sprintf("%s/%s/%06d", configs.EnvInvoicePrefix(), invoice.FinancialYear, sequence)
+
+		_, err = invoiceCollection.InsertOne(ctx, invoice)
+		if err == nil {
+			return invoice, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) || attempt == numberAttempts {
+			return invoice, err
+		}
+		// Someone else invoiced this order in the meantime
+		if existing, err := ForOrder(ctx, orderID); err == nil {
+			return existing, nil
+		}
+	}
+}
+
+// build fills an invoice, without its number, from the order.
+func build(order models.Order, user models.User, issuedAt time.Time) models.Invoice {
+	invoice := models.Invoice{
+		OrderID:       order.ID,
+		UserID:        order.UserID,
+		FinancialYear: FinancialYear(issuedAt),
+		IssuedAt:      issuedAt,
+		Seller: models.InvoiceParty{
+			Name:    configs.EnvSellerName(),
+			GSTIN:   configs.EnvSellerGSTIN(),
+			Address: configs.EnvSellerAddress(),
+			State:   configs.EnvGSTOriginState(),
+		},
+		Buyer: models.InvoiceParty{
+			Name:  user.Name,
+			Email: user.Email,
+		},
+		Currency:      order.Currency,
+		PlatformFee:   order.PlatformFee,
+		Total:         order.TotalAmount,
+		PaymentMethod: order.PaymentMethod,
+		PaymentID:     order.PaymentID,
+	}
+	if invoice.Currency == "" {
+		invoice.Currency = configs.EnvBaseCurrency()
+	}
+	money := func(amount int64) models.Money {
+		return models.Money{Amount: amount, Currency: invoice.Currency}
+	}
+	invoice.Shipping = money(0)
+
+	if address := order.ShippingAddress; address != nil {
+		invoice.Buyer.Address = strings.Join([]string{address.StreetAddress, address.City, address.State + " " + address.ZipCode}, ", ")
+		invoice.Buyer.State = address.State
+	}
+	invoice.PlaceOfSupply = invoice.Buyer.State
+	if order.Tax != nil {
+		invoice.Tax = *order.Tax
+		if order.Tax.DestinationState != "" {
+			invoice.PlaceOfSupply = order.Tax.DestinationState
+		}
+	}
+	if order.Shipping != nil {
+		invoice.Shipping = order.Shipping.Charge
+	}
+
+	for _, item := range order.Items {
+		line := models.InvoiceLine{
+			Description: item.Product.Name,
+			HSNCode:     item.Product.HSNCode,
+			Quantity:    item.Quantity,
+		}
+		if item.Size != "" {
+			line.Description += " (" + item.Size + ")"
+		}
+		if item.Tax != nil {
+			line.HSNCode = item.Tax.HSNCode
+			line.TaxableValue = item.Tax.TaxableValue
+			line.TaxRate = item.Tax.Rate
+			line.TaxAmount = item.Tax.Amount
+		} else {
+			// Orders from before GST was kept per line, which were all in
+			// the base currency
+			line.TaxableValue = money(currencyService.BasePrice(item.Product).Amount * int64(item.Quantity))
+			line.TaxAmount = money(0)
+		}
+		line.UnitPrice = money(0)
+		if item.Quantity > 0 {
+			line.UnitPrice = money(int64(math.Round(float64(line.TaxableValue.Amount) / float64(item.Quantity))))
+		}
+		line.Total = money(line.TaxableValue.Amount + line.TaxAmount.Amount)
+		invoice.Lines = append(invoice.Lines, line)
+	}
+	return invoice
+}
@@ -0,0 +1,124 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parse reads the trailer and cross-reference table of a PDF, returning the
+// offset of each object and the trailer's /Size.
+func parse(t *testing.T, data []byte) (offsets []int, size int) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or end of file marker")
+	}
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if match == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if xref >= len(data) || !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+
+	lines := strings.Split(string(data[xref:]), "\n")
+	var first, count int
+	if _, err := fmt.Sscanf(lines[1], "%d %d", &first, &count); err != nil || first != 0 {
+		t.Fatalf("bad xref subsection %q", lines[1])
+	}
+	// Every entry is exactly 20 bytes with its end of line
+	if lines[2] != "0000000000 65535 f " {
+		t.Fatalf("bad free entry %q", lines[2])
+	}
+	for _, entry := range lines[3 : 2+count] {
+		if len(entry)+1 != 20 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("bad xref entry %q", entry)
+		}
+		offset, err := strconv.Atoi(entry[:10])
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, offset)
+	}
+
+	trailer := regexp.MustCompile(`trailer\n<< /Size (\d+) /Root 1 0 R >>`).FindSubmatch(data[xref:])
+	if trailer == nil {
+		t.Fatal("missing trailer")
+	}
+	size, _ = strconv.Atoi(string(trailer[1]))
+	if size != count {
+		t.Errorf("trailer /Size = %d, xref has %d entries", size, count)
+	}
+	return offsets, size
+}
+
+func TestWriteXrefOffsets(t *testing.T) {
+	for _, pages := range []int{1, 3} {
+		t.Run(fmt.Sprintf("%d pages", pages), func(t *testing.T) {
+			var doc []*Page
+			for i := 0; i < pages; i++ {
+				page := &Page{}
+				page.Text(Margin, 60, 18, true, "TAX INVOICE")
+				page.TextRight(PageWidth-Margin, 90, 9, false, "1,234.50")
+				page.Text(Margin, 120, 9, false, "Café (Mumbai) \\ naïve ₹")
+				page.Line(130)
+				doc = append(doc, page)
+			}
+			var buf bytes.Buffer
+			if err := Write(&buf, doc); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+
+			offsets, size := parse(t, data)
+			if want := 4 + 2*pages + 1; size != want {
+				t.Errorf("/Size = %d, want %d", size, want)
+			}
+			for i, offset := range offsets {
+				header := fmt.Sprintf("%d 0 obj\n", i+1)
+				if offset >= len(data) || !bytes.HasPrefix(data[offset:], []byte(header)) {
+					t.Errorf("object %d: offset %d does not point at %q", i+1, offset, header)
+				}
+			}
+			if !bytes.Contains(data, []byte(fmt.Sprintf("/Count %d", pages))) {
+				t.Errorf("page tree does not count %d pages", pages)
+			}
+		})
+	}
+}
+
+func TestStreamLengths(t *testing.T) {
+	page := &Page{}
+	page.Text(Margin, 60, 12, false, "Total (INR)")
+	var buf bytes.Buffer
+	if err := Write(&buf, []*Page{page}); err != nil {
+		t.Fatal(err)
+	}
+	streams := regexp.MustCompile(`(?s)<< /Length (\d+) >>\nstream\n(.*?)endstream`).FindAllSubmatch(buf.Bytes(), -1)
+	if len(streams) != 1 {
+		t.Fatalf("found %d content streams, want 1", len(streams))
+	}
+	for _, match := range streams {
+		if length, _ := strconv.Atoi(string(match[1])); length != len(match[2]) {
+			t.Errorf("/Length %d, stream is %d bytes", length, len(match[2]))
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := map[string]string{
+		"Invoice (copy)": `Invoice \(copy\)`,
+		`a\b`:            `a\\b`,
+		"Café":           `Caf\351`,
+		"₹ 100":          "? 100",
+	}
+	for s, want := range tests {
+		if got := pdfText(s); got != want {
+			t.Errorf("pdfText(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
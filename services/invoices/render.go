@@ -0,0 +1,152 @@
+package invoiceService
+
+import (
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/services/invoices/pdf"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Table column right edges, and where a page's rows must stop
+const (
+	colQty     = 330.0
+	colUnit    = 385.0
+	colTaxable = 440.0
+	colRate    = 475.0
+	colTax     = 515.0
+	colTotal   = pdf.PageWidth - pdf.Margin
+	rowHeight  = 16.0
+	lastRowY   = pdf.PageHeight - 120
+)
+
+// maxDescription is how many characters of a description fit its column.
+const maxDescription = 38
+
+func money(amount models.Money) string {
+	return amount.Decimal()
+}
+
+func percent(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64) + "%"
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// tableHeader draws the line item column titles at y.
+func tableHeader(p *pdf.Page, y float64) {
+	p.Line(y - 12)
+	p.Text(pdf.Margin, y, 9, true, "#")
+	p.Text(60, y, 9, true, "Description")
+	p.Text(258, y, 9, true, "HSN")
+	p.TextRight(colQty, y, 9, true, "Qty")
+	p.TextRight(colUnit, y, 9, true, "Unit price")
+	p.TextRight(colTaxable, y, 9, true, "Taxable")
+	p.TextRight(colRate, y, 9, true, "GST")
+	p.TextRight(colTax, y, 9, true, "GST amt")
+	p.TextRight(colTotal, y, 9, true, "Total")
+	p.Line(y + 5)
+}
+
+// Render writes the invoice as a PDF: seller and buyer, one row per line
+// item with its HSN code and GST, and the GST breakdown and totals.
+func Render(w io.Writer, invoice models.Invoice) error {
+	page := &pdf.Page{}
+	pages := []*pdf.Page{page}
+
+	page.Text(pdf.Margin, 60, 18, true, "TAX INVOICE")
+
+	// Seller on the left, invoice details on the right
+	y := 90.0
+	page.Text(pdf.Margin, y, 11, true, invoice.Seller.Name)
+	page.Text(pdf.Margin, y+14, 9, false, invoice.Seller.Address)
+	page.Text(pdf.Margin, y+28, 9, false, "State: "+invoice.Seller.State)
+	if invoice.Seller.GSTIN != "" {
+		page.Text(pdf.Margin, y+42, 9, false, "GSTIN: "+invoice.Seller.GSTIN)
+	}
+
+	page.Text(360, y, 9, true, "Invoice no.")
+	page.Text(440, y, 9, false, invoice.Number)
+	page.Text(360, y+14, 9, true, "Invoice date")
+	page.Text(440, y+14, 9, false, invoice.IssuedAt.In(ist).Format("02 Jan 2006"))
+	page.Text(360, y+28, 9, true, "Order")
+	page.Text(440, y+28, 9, false, invoice.OrderID.Hex())
+	page.Text(360, y+42, 9, true, "Place of supply")
+	page.Text(440, y+42, 9, false, invoice.PlaceOfSupply)
+
+	y = 165
+	page.Text(pdf.Margin, y, 9, true, "Bill to / Ship to")
+	page.Text(pdf.Margin, y+14, 10, true, invoice.Buyer.Name)
+	page.Text(pdf.Margin, y+28, 9, false, invoice.Buyer.Address)
+	page.Text(pdf.Margin, y+42, 9, false, invoice.Buyer.Email)
+
+	y = 240
+	tableHeader(page, y)
+	y += rowHeight + 6
+	taxable := models.Money{Currency: invoice.Currency}
+	for i, line := range invoice.Lines {
+		if y > lastRowY {
+			page = &pdf.Page{}
+			pages = append(pages, page)
+			page.Text(pdf.Margin, 50, 9, false, "Invoice "+invoice.Number+" (continued)")
+			y = 80
+			tableHeader(page, y)
+			y += rowHeight + 6
+		}
+		page.Text(pdf.Margin, y, 9, false, strconv.Itoa(i+1))
+		page.Text(60, y, 9, false, truncate(line.Description, maxDescription))
+		page.Text(258, y, 9, false, line.HSNCode)
+		page.TextRight(colQty, y, 9, false, strconv.Itoa(line.Quantity))
+		page.TextRight(colUnit, y, 9, false, money(line.UnitPrice))
+		page.TextRight(colTaxable, y, 9, false, money(line.TaxableValue))
+		page.TextRight(colRate, y, 9, false, percent(line.TaxRate))
+		page.TextRight(colTax, y, 9, false, money(line.TaxAmount))
+		page.TextRight(colTotal, y, 9, false, money(line.Total))
+		taxable.Amount += line.TaxableValue.Amount
+		y += rowHeight
+	}
+	page.Line(y - 10)
+
+	// The totals need room for one row per GST component plus five more
+	if y+float64(len(invoice.Tax.Lines)+6)*rowHeight > pdf.PageHeight-pdf.Margin {
+		page = &pdf.Page{}
+		pages = append(pages, page)
+		y = 60
+	}
+	y += 6
+	total := func(label, amount string, bold bool) {
+		page.Text(360, y, 9, bold, label)
+		page.TextRight(colTotal, y, 9, bold, amount)
+		y += rowHeight
+	}
+	total("Taxable value", money(taxable), false)
+	for _, tax := range invoice.Tax.Lines {
+		total(fmt.Sprintf("%s @ %s", tax.Type, percent(tax.Rate)), money(tax.Amount), false)
+	}
+	if invoice.Shipping.Amount > 0 {
+		total("Shipping", money(invoice.Shipping), false)
+	}
+	if invoice.PlatformFee.Amount > 0 {
+		total("Platform fee", money(invoice.PlatformFee), false)
+	}
+	total("Total ("+invoice.Currency+")", money(invoice.Total), true)
+
+	y += rowHeight
+	if invoice.PaymentMethod != "" {
+		payment := "Payment: " + invoice.PaymentMethod
+		if invoice.PaymentID != "" {
+			payment += " (" + invoice.PaymentID + ")"
+		}
+		page.Text(pdf.Margin, y, 8, false, payment)
+		y += 12
+	}
+	page.Text(pdf.Margin, y, 8, false, "This is a computer generated invoice and needs no signature.")
+
+	return pdf.Write(w, pages)
+}
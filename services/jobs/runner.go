@@ -0,0 +1,127 @@
+package jobService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var jobRunCollection *mongo.Collection = configs.GetCollection(configs.DB, "jobRuns")
+var jobLockCollection *mongo.Collection = configs.GetCollection(configs.DB, "jobLocks")
+
+// runRetention is how long job runs are kept.
+const runRetention = 30 * 24 * time.Hour
+
+// instanceID tells this process's job locks apart from other instances'.
+var instanceID = primitive.NewObjectID().Hex()
+
+// Job is a task run periodically in the background.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Timeout bounds a single run and the lock held for it
+	Timeout time.Duration
+	// Run does the work and returns counts describing what it did
+	Run func(ctx context.Context) (map[string]int, error)
+}
+
+// EnsureIndexes indexes job runs by job and expires them after the retention
+// period. It is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := jobRunCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "job", Value: 1}, {Key: "startedAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "finishedAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(runRetention.Seconds())),
+		},
+	})
+	return err
+}
+
+// Start runs every job on its interval until ctx is cancelled. Each job runs
+// in its own goroutine, first right away and then on every tick.
+func Start(ctx context.Context, jobs ...Job) {
+	for _, job := range jobs {
+		go loop(ctx, job)
+	}
+}
+
+func loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := RunOnce(ctx, job); err != nil {
+			log.Printf("jobs: %s: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// acquire takes the job's lock until the run times out. Only one instance of
+// the service runs a job at a time; false means another one holds the lock.
+func acquire(ctx context.Context, job Job) (bool, error) {
+	now := time.Now()
+	_, err := jobLockCollection.UpdateOne(ctx,
+		bson.M{"_id": job.Name, "lockedUntil": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"lockedUntil": now.Add(job.Timeout), "holder": instanceID}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func release(ctx context.Context, job Job) {
+	jobLockCollection.UpdateOne(ctx,
+		bson.M{"_id": job.Name, "holder": instanceID},
+		bson.M{"$set": bson.M{"lockedUntil": time.Now()}},
+	)
+}
+
+// RunOnce runs the job now if no other instance is running it and records
+// the run. It returns nil when the job was skipped.
+func RunOnce(ctx context.Context, job Job) (*models.JobRun, error) {
+	acquired, err := acquire(ctx, job)
+	if err != nil || !acquired {
+		return nil, err
+	}
+	defer release(context.Background(), job)
+
+	run := models.JobRun{
+		ID:        primitive.NewObjectID(),
+		Job:       job.Name,
+		StartedAt: time.Now(),
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	results, err := job.Run(runCtx)
+	cancel()
+
+	run.FinishedAt = time.Now()
+	run.Results = results
+	run.Succeeded = err == nil
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	recordCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := jobRunCollection.InsertOne(recordCtx, run); err != nil {
+		return &run, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,210 @@
+package mediaService
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/services/media/webp"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxPixels bounds the decoded size of an upload, so a small file cannot
+// claim a huge canvas.
+const maxPixels = 40_000_000
+
+var (
+	ErrNoFile            = errors.New("an image file is required")
+	ErrTooLarge          = errors.New("the image file is too large")
+	ErrUnsupportedType   = errors.New("images must be JPEG, PNG or GIF")
+	ErrInvalidImage      = errors.New("the file is not a valid image")
+	ErrInvalidDimensions = errors.New("the image has too many pixels")
+)
+
+// Kind says where an upload is stored and the sizes made of it, as the
+// longest side in pixels. Images are never enlarged.
+type Kind struct {
+	Prefix    string
+	Large     int
+	Thumbnail int
+}
+
+var (
+	ProductImage = Kind{Prefix: "products", Large: 1600, Thumbnail: 320}
+	ProfileImage = Kind{Prefix: "users", Large: 512, Thumbnail: 128}
+	ReviewImage  = Kind{Prefix: "reviews", Large: 1200, Thumbnail: 240}
+)
+
+// supportedTypes are the sniffed content types that can be decoded.
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// scale shrinks img so its longest side is at most size, averaging the
+// source pixels each output pixel covers.
+func scale(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	dw, dh := sw, sh
+	if sw > size || sh > size {
+		if sw >= sh {
+			dw, dh = size, max(1, sh*size/sw)
+		} else {
+			dw, dh = max(1, sw*size/sh), size
+		}
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	if dw == sw && dh == sh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, max((dy+1)*sh/dh, dy*sh/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, max((dx+1)*sw/dw, dx*sw/dw+1)
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					r, g, b, a = r+int(p[0]), g+int(p[1]), b+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[dy*dst.Stride+dx*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+func opaque(img *image.RGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// file is one encoded variant of an upload.
+type file struct {
+	name        string
+	data        []byte
+	contentType string
+}
+
+// encode makes a variant in the upload's own family, JPEG for opaque images
+// and PNG otherwise, and its WebP twin. The WebP encoder is lossless, so its
+// output is often larger than a JPEG; the twin is left out when it does not
+// save anything.
+func encode(img *image.RGBA, name string) ([]file, error) {
+	var buf bytes.Buffer
+	var f file
+	if opaque(img) {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		f = file{name + ".jpg", buf.Bytes(), "image/jpeg"}
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		f = file{name + ".png", buf.Bytes(), "image/png"}
+	}
+
+	var lossless bytes.Buffer
+	if err := webp.Encode(&lossless, img); err != nil {
+		return nil, err
+	}
+	if lossless.Len() >= len(f.data) {
+		return []file{f}, nil
+	}
+	return []file{f, {name + ".webp", lossless.Bytes(), "image/webp"}}, nil
+}
+
+// webpURL is the URL of a variant's WebP twin, or empty when it has none.
+func webpURL(prefix string, files []file) string {
+	if len(files) < 2 {
+		return ""
+	}
+	return store.URL(prefix + files[1].name)
+}
+
+// Upload checks an uploaded image, makes its large and thumbnail sizes, each
+// as JPEG or PNG and, when smaller, as WebP, and stores them under the
+// owner's prefix.
+// Uploads are re-encoded rather than kept as sent, which also drops any
+// metadata such as location. It returns the URLs of the stored files.
+func Upload(ctx context.Context, kind Kind, owner string, data []byte) (models.Image, error) {
+	if store == nil {
+		return models.Image{}, ErrStoreNotConfigured
+	}
+	if len(data) == 0 {
+		return models.Image{}, ErrNoFile
+	}
+	if len(data) > configs.EnvMaxImageBytes() {
+		return models.Image{}, ErrTooLarge
+	}
+	// The type is sniffed from the content; the client's claim is ignored
+	if !supportedTypes[http.DetectContentType(data)] {
+		return models.Image{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return models.Image{}, ErrInvalidImage
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width*config.Height > maxPixels {
+		return models.Image{}, ErrInvalidDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return models.Image{}, ErrInvalidImage
+	}
+
+	large := scale(img, kind.Large)
+	thumbnail := scale(large, kind.Thumbnail)
+	largeFiles, err := encode(large, "large")
+	if err != nil {
+		return models.Image{}, err
+	}
+	thumbnailFiles, err := encode(thumbnail, "thumb")
+	if err != nil {
+		return models.Image{}, err
+	}
+
+	prefix := kind.Prefix + "/" + owner + "/" + primitive.NewObjectID().Hex() + "/"
+	files := append(largeFiles, thumbnailFiles...)
+	for i, f := range files {
+		if err := store.Put(ctx, prefix+f.name, f.data, f.contentType); err != nil {
+			// Leave nothing behind from a failed upload
+			for _, written := range files[:i] {
+				store.Delete(context.Background(), prefix+written.name)
+			}
+			return models.Image{}, err
+		}
+	}
+
+	return models.Image{
+		URL:           store.URL(prefix + largeFiles[0].name),
+		WebP:          webpURL(prefix, largeFiles),
+		Thumbnail:     store.URL(prefix + thumbnailFiles[0].name),
+		ThumbnailWebP: webpURL(prefix, thumbnailFiles),
+		Width:         large.Bounds().Dx(),
+		Height:        large.Bounds().Dy(),
+	}, nil
+}
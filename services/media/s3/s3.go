@@ -0,0 +1,159 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config addresses a bucket on S3 or a compatible service such as MinIO
+// or R2. Objects are addressed path style, which every one of them accepts.
+type Config struct {
+	Endpoint        string // https://s3.ap-south-1.amazonaws.com
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PublicURL is where the bucket's objects are read from, such as a CDN.
+	// It defaults to the bucket on the endpoint.
+	PublicURL string
+}
+
+// Store keeps files in an S3 bucket, signing requests with AWS
+// Signature Version 4.
+type Store struct {
+	config Config
+	client *http.Client
+}
+
+func New(config Config) (*Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("the S3 store needs S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT: %w", err)
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	if config.PublicURL == "" {
+		config.PublicURL = config.Endpoint + "/" + config.Bucket
+	}
+	config.PublicURL = strings.TrimSuffix(config.PublicURL, "/")
+	return &Store{config: config, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// escapePath encodes a key for a URL path as SigV4 expects: everything but
+// unreserved characters, keeping the slashes between segments.
+func escapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sign sets the x-amz headers and the Authorization header on req. path is
+// the canonical URI, already escaped, and headers are signed along with the
+// host, payload hash and date.
+func (s *Store) sign(req *http.Request, path, payloadHash string, headers map[string]string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	signed := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	for name, value := range headers {
+		signed[strings.ToLower(name)] = value
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(signed[name]) + "\n")
+		if name != "host" {
+			req.Header.Set(name, signed[name])
+		}
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.config.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func (s *Store) do(ctx context.Context, method, key string, body []byte, headers map[string]string) error {
+	path := "/" + escapePath(s.config.Bucket) + "/" + escapePath(key)
+	req, err := http.NewRequestWithContext(ctx, method, s.config.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	payloadHash := sha256.Sum256(body)
+	s.sign(req, path, hex.EncodeToString(payloadHash[:]), headers, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && !(method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// Put uploads the file. Keys are never reused, so it may be cached forever.
+func (s *Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	return s.do(ctx, http.MethodPut, key, data, map[string]string{
+		"Content-Type":  contentType,
+		"Cache-Control": "public, max-age=31536000, immutable",
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, http.MethodDelete, key, nil, nil)
+}
+
+func (s *Store) URL(key string) string {
+	return s.config.PublicURL + "/" + escapePath(key)
+}
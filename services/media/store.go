@@ -0,0 +1,60 @@
+package mediaService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/services/media/s3"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// BlobStore keeps uploaded files under keys such as
+// "products/<id>/<image>/thumb.webp" and tells where they are served from.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL is the public address of the file stored under key
+	URL(key string) string
+}
+
+var ErrStoreNotConfigured = errors.New("no blob store is configured")
+
+var store BlobStore
+
+// SetStore sets the store uploads are written to.
+func SetStore(s BlobStore) {
+	store = s
+}
+
+// NewStoreFromEnv builds the store MEDIA_STORE selects: "local" (the
+// default) or "s3" for S3 and compatible services.
+func NewStoreFromEnv() (BlobStore, error) {
+	switch kind := configs.EnvMediaStore(); kind {
+	case "local":
+		return NewLocalStore(configs.EnvMediaDir(), configs.EnvMediaBaseURL())
+	case "s3":
+		return s3.New(s3.Config{
+			Endpoint:        configs.EnvS3Endpoint(),
+			Region:          configs.EnvS3Region(),
+			Bucket:          configs.EnvS3Bucket(),
+			AccessKeyID:     configs.EnvS3AccessKeyID(),
+			SecretAccessKey: configs.EnvS3SecretAccessKey(),
+			PublicURL:       configs.EnvS3PublicURL(),
+		})
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORE %q, use local or s3", kind)
+	}
+}
+
+// ValidURL reports whether an image reference is an http or https URL or,
+// as the local store makes them, a path on this server.
+func ValidURL(image string) bool {
+	u, err := url.Parse(image)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" ||
+		u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/")
+}
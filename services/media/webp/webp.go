@@ -0,0 +1,367 @@
+package webp
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// The standard library and our dependencies can decode but not encode WebP,
+// so this is a small lossless (VP8L) encoder. It applies the subtract green
+// and a single-mode predictor transform and entropy codes every pixel as
+// literals: no backward references or colour cache. Files come out larger
+// than a full encoder would make them, but any WebP decoder reads them.
+
+const (
+	maxCodeLength           = 15
+	maxCodeLengthCodeLength = 7
+	// predictorBits sizes the blocks of the predictor transform. Every block
+	// uses the same mode, so the largest blocks keep its sub-image smallest.
+	predictorBits = 9
+	// predictorAverageLT predicts a pixel as the average of its left and top
+	// neighbours.
+	predictorAverageLT = 7
+	// greenAlphabet is the 256 green literals, 24 length prefixes and no
+	// colour cache.
+	greenAlphabet    = 256 + 24
+	distanceAlphabet = 40
+)
+
+// codeLengthOrder is the order code length code lengths are written in.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// bitWriter writes bits least significant first, as VP8L reads them.
+type bitWriter struct {
+	buf []byte
+	acc uint64
+	n   uint
+}
+
+func (w *bitWriter) write(bits uint32, n uint) {
+	w.acc |= uint64(bits) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.n -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.n > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.n = 0, 0
+	}
+	return w.buf
+}
+
+type huffmanNode struct {
+	weight      int
+	symbol      int
+	left, right *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int            { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool  { return h[i].weight < h[j].weight }
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	node := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return node
+}
+
+// codeLengths returns Huffman code lengths for the symbol counts, no longer
+// than limit. When the plain Huffman code is too deep, small counts are
+// raised and the code rebuilt, which keeps it complete as VP8L requires.
+func codeLengths(counts []int, limit int) []int {
+	lengths := make([]int, len(counts))
+	for minCount := 1; ; minCount *= 2 {
+		h := &huffmanHeap{}
+		for symbol, count := range counts {
+			if count > 0 {
+				*h = append(*h, &huffmanNode{weight: max(count, minCount), symbol: symbol})
+			}
+		}
+		if h.Len() == 0 {
+			return lengths
+		}
+		if h.Len() == 1 {
+			lengths[(*h)[0].symbol] = 1
+			return lengths
+		}
+
+		heap.Init(h)
+		for h.Len() > 1 {
+			a := heap.Pop(h).(*huffmanNode)
+			b := heap.Pop(h).(*huffmanNode)
+			heap.Push(h, &huffmanNode{weight: a.weight + b.weight, symbol: -1, left: a, right: b})
+		}
+
+		deepest := 0
+		var walk func(node *huffmanNode, depth int)
+		walk = func(node *huffmanNode, depth int) {
+			if node.left == nil {
+				lengths[node.symbol] = depth
+				deepest = max(deepest, depth)
+				return
+			}
+			walk(node.left, depth+1)
+			walk(node.right, depth+1)
+		}
+		walk((*h)[0], 0)
+		if deepest <= limit {
+			return lengths
+		}
+	}
+}
+
+// prefixCode writes symbols with canonical Huffman codes.
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+	// zeroBits is set for a code with a single symbol, which takes no bits
+	zeroBits bool
+}
+
+func newPrefixCode(lengths []int) *prefixCode {
+	used := 0
+	var count [maxCodeLength + 1]int
+	for _, length := range lengths {
+		if length > 0 {
+			used++
+			count[length]++
+		}
+	}
+
+	// Canonical codes: shorter codes first, then by symbol
+	var next [maxCodeLength + 1]uint32
+	code := uint32(0)
+	for length := 1; length <= maxCodeLength; length++ {
+		next[length] = code
+		code = (code + uint32(count[length])) << 1
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		// Codes are read from their first bit on, so they go out reversed
+		c := next[length]
+		next[length]++
+		reversed := uint32(0)
+		for i := 0; i < length; i++ {
+			reversed = reversed<<1 | c&1
+			c >>= 1
+		}
+		codes[symbol] = reversed
+	}
+	return &prefixCode{lengths: lengths, codes: codes, zeroBits: used <= 1}
+}
+
+func (p *prefixCode) write(w *bitWriter, symbol int) {
+	if !p.zeroBits {
+		w.write(p.codes[symbol], uint(p.lengths[symbol]))
+	}
+}
+
+// writePrefixCode chooses and writes the code for the symbol counts. One or
+// two literal symbols fit the simple code; anything else gets a normal code
+// with its lengths in turn Huffman coded.
+func writePrefixCode(w *bitWriter, counts []int) *prefixCode {
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		lengths := make([]int, len(counts))
+		first := 0
+		if len(used) > 0 {
+			first = used[0]
+		}
+		w.write(1, 1)
+		w.write(uint32(max(len(used), 1)-1), 1)
+		if first < 2 {
+			w.write(0, 1)
+			w.write(uint32(first), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(first), 8)
+		}
+		lengths[first] = 1
+		if len(used) == 2 {
+			w.write(uint32(used[1]), 8)
+			lengths[used[1]] = 1
+		}
+		return newPrefixCode(lengths)
+	}
+
+	lengths := codeLengths(counts, maxCodeLength)
+	var lengthCounts [19]int
+	for _, length := range lengths {
+		lengthCounts[length]++
+	}
+	lengthCode := newPrefixCode(codeLengths(lengthCounts[:], maxCodeLengthCodeLength))
+
+	last := 3
+	for i, symbol := range codeLengthOrder {
+		if lengthCode.lengths[symbol] > 0 {
+			last = max(last, i)
+		}
+	}
+	w.write(0, 1)
+	w.write(uint32(last+1-4), 4)
+	for _, symbol := range codeLengthOrder[:last+1] {
+		w.write(uint32(lengthCode.lengths[symbol]), 3)
+	}
+	// Lengths follow for the whole alphabet
+	w.write(0, 1)
+	for _, length := range lengths {
+		lengthCode.write(w, length)
+	}
+	return newPrefixCode(lengths)
+}
+
+// writeImageData entropy codes ARGB pixels as literals. The main image also
+// says it uses a single set of prefix codes.
+func writeImageData(w *bitWriter, pixels []uint32, main bool) {
+	w.write(0, 1) // No colour cache
+	if main {
+		w.write(0, 1) // No meta prefix codes
+	}
+
+	green := make([]int, greenAlphabet)
+	red := make([]int, 256)
+	blue := make([]int, 256)
+	alpha := make([]int, 256)
+	for _, p := range pixels {
+		alpha[p>>24]++
+		red[p>>16&0xff]++
+		green[p>>8&0xff]++
+		blue[p&0xff]++
+	}
+
+	greenCode := writePrefixCode(w, green)
+	redCode := writePrefixCode(w, red)
+	blueCode := writePrefixCode(w, blue)
+	alphaCode := writePrefixCode(w, alpha)
+	writePrefixCode(w, make([]int, distanceAlphabet))
+
+	for _, p := range pixels {
+		greenCode.write(w, int(p>>8&0xff))
+		redCode.write(w, int(p>>16&0xff))
+		blueCode.write(w, int(p&0xff))
+		alphaCode.write(w, int(p>>24))
+	}
+}
+
+// average2 averages two ARGB pixels channel by channel.
+func average2(a, b uint32) uint32 {
+	return (((a ^ b) & 0xfefefefe) >> 1) + (a & b)
+}
+
+// subPixels subtracts b from a channel by channel, modulo 256.
+func subPixels(a, b uint32) uint32 {
+	alphaGreen := 0x00ff00ff + (a & 0xff00ff00) - (b & 0xff00ff00)
+	redBlue := 0xff00ff00 + (a & 0x00ff00ff) - (b & 0x00ff00ff)
+	return alphaGreen&0xff00ff00 | redBlue&0x00ff00ff
+}
+
+// ErrDimensions is returned for images WebP cannot hold.
+var ErrDimensions = errors.New("webp: images must be 1 to 16384 pixels on each side")
+
+// Encode writes img as a lossless WebP.
+func Encode(out io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > 1<<14 || height > 1<<14 {
+		return ErrDimensions
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	pixels := make([]uint32, width*height)
+	hasAlpha := false
+	for i := range pixels {
+		p := nrgba.Pix[i*4 : i*4+4]
+		r, g, b, a := uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+		hasAlpha = hasAlpha || a != 0xff
+		// Subtract green transform
+		pixels[i] = a<<24 | ((r-g)&0xff)<<16 | g<<8 | (b-g)&0xff
+	}
+
+	// Predictor transform: the top left pixel is predicted as opaque black,
+	// the rest of the top row from the left and the left column from the top
+	residuals := make([]uint32, len(pixels))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			var predicted uint32
+			switch {
+			case x == 0 && y == 0:
+				predicted = 0xff000000
+			case y == 0:
+				predicted = pixels[i-1]
+			case x == 0:
+				predicted = pixels[i-width]
+			default:
+				predicted = average2(pixels[i-1], pixels[i-width])
+			}
+			residuals[i] = subPixels(pixels[i], predicted)
+		}
+	}
+
+	w := &bitWriter{}
+	w.write(0x2f, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	if hasAlpha {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+	w.write(0, 3) // Version
+
+	w.write(1, 1)
+	w.write(2, 2) // Subtract green
+
+	w.write(1, 1)
+	w.write(0, 2) // Predictor
+	w.write(predictorBits-2, 3)
+	blockSize := 1 << predictorBits
+	modes := make([]uint32, ((width+blockSize-1)/blockSize)*((height+blockSize-1)/blockSize))
+	for i := range modes {
+		modes[i] = 0xff000000 | predictorAverageLT<<8
+	}
+	writeImageData(w, modes, false)
+
+	w.write(0, 1) // No more transforms
+	writeImageData(w, residuals, true)
+
+	data := w.bytes()
+	chunk := len(data) + len(data)&1
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+chunk))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+	if len(data)&1 == 1 {
+		data = append(data, 0)
+	}
+	_, err := out.Write(data)
+	return err
+}
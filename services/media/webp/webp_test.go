@@ -0,0 +1,105 @@
+package webp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// testImage fills a width by height image with gradients, flat areas and
+// noise, so the encoder sees both long runs and wide alphabets.
+func testImage(width, height int, alpha bool, seed int64) *image.NRGBA {
+	random := rand.New(rand.NewSource(seed))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{uint8(x * 255 / width), uint8(y * 255 / height), uint8(x ^ y), 0xff}
+			if x > width/2 {
+				c = color.NRGBA{uint8(random.Intn(256)), uint8(random.Intn(256)), uint8(random.Intn(256)), 0xff}
+			}
+			if alpha {
+				c.A = uint8((x + y) % 256)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		alpha         bool
+	}{
+		{"single pixel", 1, 1, false},
+		{"single row", 37, 1, false},
+		{"single column", 1, 29, true},
+		{"opaque", 320, 240, false},
+		{"alpha", 257, 130, true},
+		{"wider than a predictor block", 600, 9, false},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := testImage(test.width, test.height, test.alpha, int64(i))
+			var buf bytes.Buffer
+			if err := Encode(&buf, want); err != nil {
+				t.Fatal(err)
+			}
+			got, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("bounds = %v, want %v", got.Bounds(), want.Bounds())
+			}
+			for y := 0; y < test.height; y++ {
+				for x := 0; x < test.width; x++ {
+					g := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+					w := want.NRGBAAt(x, y)
+					// Fully transparent pixels may come back with any colour
+					if g != w && !(w.A == 0 && g.A == 0) {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeFlatImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	config, err := webp.DecodeConfig(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 64 || config.Height != 64 {
+		t.Errorf("config = %dx%d, want 64x64", config.Width, config.Height)
+	}
+	got, err := webp.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.NRGBAModel.Convert(got.At(63, 63)).(color.NRGBA); c != (color.NRGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("pixel = %v, want white", c)
+	}
+}
+
+func TestEncodeDimensions(t *testing.T) {
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 0, 10), image.Rect(0, 0, 1<<14+1, 1)} {
+		if err := Encode(&bytes.Buffer{}, image.NewNRGBA(r)); err != ErrDimensions {
+			t.Errorf("Encode(%v) = %v, want ErrDimensions", r, err)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package merchandisingService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	categoryService "fiber-mongo-api/services/categories"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var collectionCollection *mongo.Collection = configs.GetCollection(configs.DB, "collections")
+var sectionCollection *mongo.Collection = configs.GetCollection(configs.DB, "homeSections")
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+
+// maxManualProducts caps the products picked for a manual collection.
+const maxManualProducts = 200
+
+var (
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrNameRequired       = errors.New("a name is required")
+	ErrInvalidSlug        = errors.New("slugs may only hold lowercase letters, digits and hyphens")
+	ErrSlugTaken          = errors.New("another collection has this slug")
+	ErrInvalidType        = errors.New("type must be manual or rule")
+	ErrNoProducts         = errors.New("manual collections need at least one product")
+	ErrTooManyProducts    = errors.New("too many products for one collection")
+	ErrProductNotFound    = errors.New("unknown product in productIds")
+	ErrRuleRequired       = errors.New("rule collections need a rule")
+	ErrInvalidSort        = errors.New("sort must be newest, price_asc or price_desc")
+	ErrInvalidPriceRange  = errors.New("the price range is invalid")
+	ErrCategoryNotFound   = errors.New("unknown category in the rule")
+	ErrCollectionInUse    = errors.New("remove the home sections showing this collection first")
+)
+
+// sorts are the orders rule-based collections can list products in.
+var sorts = map[string]bson.D{
+	"newest":     {{Key: "_id", Value: -1}},
+	"price_asc":  {{Key: "price", Value: 1}, {Key: "_id", Value: 1}},
+	"price_desc": {{Key: "price", Value: -1}, {Key: "_id", Value: 1}},
+}
+
+// CollectionInput is the editable part of a collection. An empty slug is
+// made from the name.
+type CollectionInput struct {
+	Name       string
+	Slug       string
+	Type       string
+	ProductIDs []primitive.ObjectID
+	Rule       *models.CollectionRule
+}
+
+// EnsureIndexes makes collection slugs unique and orders home sections. It
+// is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := collectionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = sectionCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "position", Value: 1}}},
+		{Keys: bson.D{{Key: "collectionId", Value: 1}}},
+	})
+	return err
+}
+
+// clean trims and checks the input. Manual collections drop any rule and
+// rule-based ones any products.
+func (in *CollectionInput) clean(ctx context.Context) error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Slug = strings.TrimSpace(in.Slug)
+	if in.Name == "" {
+		return ErrNameRequired
+	}
+	if in.Slug == "" {
+		in.Slug = categoryService.Slugify(in.Name)
+	}
+	if !categoryService.ValidSlug(in.Slug) {
+		return ErrInvalidSlug
+	}
+
+	switch in.Type {
+	case models.CollectionManual:
+		in.Rule = nil
+		seen := map[primitive.ObjectID]bool{}
+		unique := []primitive.ObjectID{}
+		for _, id := range in.ProductIDs {
+			if !seen[id] {
+				seen[id] = true
+				unique = append(unique, id)
+			}
+		}
+		in.ProductIDs = unique
+		if len(in.ProductIDs) == 0 {
+			return ErrNoProducts
+		}
+		if len(in.ProductIDs) > maxManualProducts {
+			return ErrTooManyProducts
+		}
+		count, err := productCollection.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": in.ProductIDs}})
+		if err != nil {
+			return err
+		}
+		if int(count) != len(in.ProductIDs) {
+			return ErrProductNotFound
+		}
+
+	case models.CollectionRuleBased:
+		in.ProductIDs = nil
+		if in.Rule == nil {
+			return ErrRuleRequired
+		}
+		if in.Rule.Sort == "" {
+			in.Rule.Sort = "newest"
+		}
+		if _, ok := sorts[in.Rule.Sort]; !ok {
+			return ErrInvalidSort
+		}
+		if in.Rule.MinPrice < 0 || in.Rule.MaxPrice < 0 || (in.Rule.MaxPrice > 0 && in.Rule.MaxPrice < in.Rule.MinPrice) {
+			return ErrInvalidPriceRange
+		}
+		err := categoryService.CheckIDs(ctx, in.Rule.CategoryIDs)
+		if err == categoryService.ErrCategoryNotFound {
+			return ErrCategoryNotFound
+		} else if err != nil {
+			return err
+		}
+
+	default:
+		return ErrInvalidType
+	}
+	return nil
+}
+
+// CreateCollection adds a collection.
+func CreateCollection(ctx context.Context, in CollectionInput) (models.Collection, error) {
+	if err := in.clean(ctx); err != nil {
+		return models.Collection{}, err
+	}
+
+	now := time.Now()
+	collection := models.Collection{
+		ID:         primitive.NewObjectID(),
+		Name:       in.Name,
+		Slug:       in.Slug,
+		Type:       in.Type,
+		ProductIDs: in.ProductIDs,
+		Rule:       in.Rule,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	_, err := collectionCollection.InsertOne(ctx, collection)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.Collection{}, ErrSlugTaken
+	} else if err != nil {
+		return models.Collection{}, err
+	}
+	Invalidate()
+	return collection, nil
+}
+
+// UpdateCollection replaces a collection's name, slug and contents.
+func UpdateCollection(ctx context.Context, id primitive.ObjectID, in CollectionInput) (models.Collection, error) {
+	if err := in.clean(ctx); err != nil {
+		return models.Collection{}, err
+	}
+
+	set := bson.M{
+		"name":      in.Name,
+		"slug":      in.Slug,
+		"type":      in.Type,
+		"updatedAt": time.Now(),
+	}
+	update := bson.M{"$set": set}
+	if in.Type == models.CollectionManual {
+		set["productIds"] = in.ProductIDs
+		update["$unset"] = bson.M{"rule": ""}
+	} else {
+		set["rule"] = in.Rule
+		update["$unset"] = bson.M{"productIds": ""}
+	}
+
+	var collection models.Collection
+	err := collectionCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&collection)
+	if mongo.IsDuplicateKeyError(err) {
+		return collection, ErrSlugTaken
+	} else if err == mongo.ErrNoDocuments {
+		return collection, ErrCollectionNotFound
+	} else if err != nil {
+		return collection, err
+	}
+	Invalidate()
+	return collection, nil
+}
+
+// DeleteCollection removes a collection no home section shows.
+func DeleteCollection(ctx context.Context, id primitive.ObjectID) error {
+	inUse, err := sectionCollection.CountDocuments(ctx, bson.M{"collectionId": id})
+	if err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return ErrCollectionInUse
+	}
+
+	result, err := collectionCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCollectionNotFound
+	}
+	Invalidate()
+	return nil
+}
+
+// ListCollections returns every collection by name.
+func ListCollections(ctx context.Context) ([]models.Collection, error) {
+	cursor, err := collectionCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	collections := []models.Collection{}
+	err = cursor.All(ctx, &collections)
+	return collections, err
+}
+
+func findCollection(ctx context.Context, filter bson.M) (models.Collection, error) {
+	var collection models.Collection
+	err := collectionCollection.FindOne(ctx, filter).Decode(&collection)
+	if err == mongo.ErrNoDocuments {
+		return collection, ErrCollectionNotFound
+	}
+	return collection, err
+}
+
+// CollectionBySlug returns the collection with the slug.
+func CollectionBySlug(ctx context.Context, slug string) (models.Collection, error) {
+	return findCollection(ctx, bson.M{"slug": slug})
+}
+
+// query is the filter selecting the collection's products. Manual
+// collections select their picks; rule-based ones apply the rule.
+func query(ctx context.Context, collection models.Collection) (bson.M, error) {
+	if collection.Type == models.CollectionManual {
+		return bson.M{"_id": bson.M{"$in": collection.ProductIDs}}, nil
+	}
+
+	query := bson.M{}
+	rule := collection.Rule
+	if rule == nil {
+		return bson.M{"_id": bson.M{"$exists": false}}, nil
+	}
+	if len(rule.CategoryIDs) > 0 {
+		var ids []primitive.ObjectID
+		for _, id := range rule.CategoryIDs {
+			subtree, err := categoryService.Subtree(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, subtree...)
+		}
+		query["categoryIds"] = bson.M{"$in": ids}
+	}
+	if len(rule.Brands) > 0 {
+		query["brand"] = bson.M{"$in": rule.Brands}
+	}
+	price := bson.M{}
+	if rule.MinPrice > 0 {
+		price["$gte"] = rule.MinPrice
+	}
+	if rule.MaxPrice > 0 {
+		price["$lte"] = rule.MaxPrice
+	}
+	if len(price) > 0 {
+		query["price"] = price
+	}
+	if rule.InStockOnly {
+		query["quantity"] = bson.M{"$gt": 0}
+	}
+	return query, nil
+}
+
+// Products lists up to limit products of the collection that also match
+// filter: manual collections in their order, rule-based ones in the rule's.
+func Products(ctx context.Context, collection models.Collection, filter bson.M, limit int64) ([]models.Product, error) {
+	q, err := query(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	if len(filter) > 0 {
+		q = bson.M{"$and": bson.A{q, filter}}
+	}
+	products := []models.Product{}
+
+	if collection.Type == models.CollectionManual {
+		cursor, err := productCollection.Find(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		var found []models.Product
+		if err := cursor.All(ctx, &found); err != nil {
+			return nil, err
+		}
+		byID := make(map[primitive.ObjectID]models.Product, len(found))
+		for _, product := range found {
+			byID[product.ID] = product
+		}
+		for _, id := range collection.ProductIDs {
+			if product, ok := byID[id]; ok && int64(len(products)) < limit {
+				products = append(products, product)
+			}
+		}
+		return products, nil
+	}
+
+	sort := sorts["newest"]
+	if rule := collection.Rule; rule != nil && sorts[rule.Sort] != nil {
+		sort = sorts[rule.Sort]
+	}
+	cursor, err := productCollection.Find(ctx, q, options.Find().SetSort(sort).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &products)
+	return products, err
+}
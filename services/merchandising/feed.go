@@ -0,0 +1,327 @@
+package merchandisingService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	mediaService "fiber-mongo-api/services/media"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultSectionLimit and maxSectionLimit bound the products a
+	// collection section shows.
+	defaultSectionLimit = 10
+	maxSectionLimit     = 50
+	// maxBanners caps the banners of one section.
+	maxBanners = 20
+)
+
+var (
+	ErrSectionNotFound     = errors.New("home section not found")
+	ErrTitleRequired       = errors.New("a title is required")
+	ErrInvalidSectionType  = errors.New("type must be collection or banners")
+	ErrCollectionRequired  = errors.New("collection sections need a collectionId")
+	ErrBannersRequired     = errors.New("banners sections need at least one banner")
+	ErrTooManyBanners      = errors.New("too many banners for one section")
+	ErrInvalidBanner       = errors.New("banners need an image that is an http or https URL or a path on this server")
+	ErrInvalidWindow       = errors.New("endsAt must be after startsAt")
+	ErrInvalidSectionLimit = errors.New("limit must be between 1 and 50")
+)
+
+// SectionInput is the editable part of a home section.
+type SectionInput struct {
+	Title        string
+	Type         string
+	CollectionID *primitive.ObjectID
+	Limit        int
+	Banners      []models.Banner
+	Position     int
+	StartsAt     *time.Time
+	EndsAt       *time.Time
+}
+
+// FeedSection is a home section as the app shows it.
+type FeedSection struct {
+	ID         primitive.ObjectID `json:"id"`
+	Title      string             `json:"title"`
+	Type       string             `json:"type"`
+	Collection *models.Collection `json:"collection,omitempty"`
+	Products   []models.Product   `json:"products,omitempty"`
+	Banners    []models.Banner    `json:"banners,omitempty"`
+}
+
+// Feed is the home feed as of At.
+type Feed struct {
+	At       time.Time     `json:"at"`
+	Sections []FeedSection `json:"sections"`
+	// changesAt is when the next section or banner starts or ends
+	changesAt time.Time
+}
+
+// live reports whether a scheduling window is open at t.
+func live(startsAt, endsAt *time.Time, t time.Time) bool {
+	return (startsAt == nil || !startsAt.After(t)) && (endsAt == nil || endsAt.After(t))
+}
+
+func validWindow(startsAt, endsAt *time.Time) bool {
+	return startsAt == nil || endsAt == nil || endsAt.After(*startsAt)
+}
+
+// clean trims and checks the input, dropping what does not belong to the
+// section's type.
+func (in *SectionInput) clean(ctx context.Context) error {
+	in.Title = strings.TrimSpace(in.Title)
+	if in.Title == "" {
+		return ErrTitleRequired
+	}
+	if !validWindow(in.StartsAt, in.EndsAt) {
+		return ErrInvalidWindow
+	}
+
+	switch in.Type {
+	case models.SectionCollection:
+		in.Banners = nil
+		if in.CollectionID == nil {
+			return ErrCollectionRequired
+		}
+		if _, err := findCollection(ctx, bson.M{"_id": *in.CollectionID}); err != nil {
+			return err
+		}
+		if in.Limit == 0 {
+			in.Limit = defaultSectionLimit
+		}
+		if in.Limit < 1 || in.Limit > maxSectionLimit {
+			return ErrInvalidSectionLimit
+		}
+
+	case models.SectionBanners:
+		in.CollectionID, in.Limit = nil, 0
+		if len(in.Banners) == 0 {
+			return ErrBannersRequired
+		}
+		if len(in.Banners) > maxBanners {
+			return ErrTooManyBanners
+		}
+		for i := range in.Banners {
+			banner := &in.Banners[i]
+			banner.Image = strings.TrimSpace(banner.Image)
+			if !mediaService.ValidURL(banner.Image) {
+				return ErrInvalidBanner
+			}
+			if !validWindow(banner.StartsAt, banner.EndsAt) {
+				return ErrInvalidWindow
+			}
+			if banner.ID.IsZero() {
+				banner.ID = primitive.NewObjectID()
+			}
+		}
+
+	default:
+		return ErrInvalidSectionType
+	}
+	return nil
+}
+
+// CreateSection adds a section to the home feed.
+func CreateSection(ctx context.Context, in SectionInput) (models.HomeSection, error) {
+	if err := in.clean(ctx); err != nil {
+		return models.HomeSection{}, err
+	}
+
+	now := time.Now()
+	section := models.HomeSection{
+		ID:           primitive.NewObjectID(),
+		Title:        in.Title,
+		Type:         in.Type,
+		CollectionID: in.CollectionID,
+		Limit:        in.Limit,
+		Banners:      in.Banners,
+		Position:     in.Position,
+		StartsAt:     in.StartsAt,
+		EndsAt:       in.EndsAt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if _, err := sectionCollection.InsertOne(ctx, section); err != nil {
+		return models.HomeSection{}, err
+	}
+	Invalidate()
+	return section, nil
+}
+
+// UpdateSection replaces a home section.
+func UpdateSection(ctx context.Context, id primitive.ObjectID, in SectionInput) (models.HomeSection, error) {
+	if err := in.clean(ctx); err != nil {
+		return models.HomeSection{}, err
+	}
+
+	var section models.HomeSection
+	err := sectionCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&section)
+	if err == mongo.ErrNoDocuments {
+		return section, ErrSectionNotFound
+	} else if err != nil {
+		return section, err
+	}
+
+	section.Title = in.Title
+	section.Type = in.Type
+	section.CollectionID = in.CollectionID
+	section.Limit = in.Limit
+	section.Banners = in.Banners
+	section.Position = in.Position
+	section.StartsAt = in.StartsAt
+	section.EndsAt = in.EndsAt
+	section.UpdatedAt = time.Now()
+	result, err := sectionCollection.ReplaceOne(ctx, bson.M{"_id": id}, section)
+	if err != nil {
+		return section, err
+	}
+	if result.MatchedCount == 0 {
+		return section, ErrSectionNotFound
+	}
+	Invalidate()
+	return section, nil
+}
+
+// DeleteSection removes a section from the home feed.
+func DeleteSection(ctx context.Context, id primitive.ObjectID) error {
+	result, err := sectionCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSectionNotFound
+	}
+	Invalidate()
+	return nil
+}
+
+// ListSections returns every home section in feed order, scheduled or not.
+func ListSections(ctx context.Context) ([]models.HomeSection, error) {
+	findOptions := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "createdAt", Value: 1}})
+	cursor, err := sectionCollection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	sections := []models.HomeSection{}
+	err = cursor.All(ctx, &sections)
+	return sections, err
+}
+
+// BuildFeed assembles the home feed as it is at t: the live sections in
+// order with their live banners or collection products. Sections with
+// nothing to show are left out.
+func BuildFeed(ctx context.Context, t time.Time) (Feed, error) {
+	feed := Feed{At: t, Sections: []FeedSection{}}
+	sections, err := ListSections(ctx)
+	if err != nil {
+		return feed, err
+	}
+
+	// The feed changes when any window opens or closes after t
+	watch := func(times ...*time.Time) {
+		for _, at := range times {
+			if at != nil && at.After(t) && (feed.changesAt.IsZero() || at.Before(feed.changesAt)) {
+				feed.changesAt = *at
+			}
+		}
+	}
+
+	for _, section := range sections {
+		watch(section.StartsAt, section.EndsAt)
+		if !live(section.StartsAt, section.EndsAt, t) {
+			continue
+		}
+		out := FeedSection{ID: section.ID, Title: section.Title, Type: section.Type}
+
+		switch section.Type {
+		case models.SectionBanners:
+			for _, banner := range section.Banners {
+				watch(banner.StartsAt, banner.EndsAt)
+				if live(banner.StartsAt, banner.EndsAt, t) {
+					out.Banners = append(out.Banners, banner)
+				}
+			}
+			if len(out.Banners) == 0 {
+				continue
+			}
+
+		case models.SectionCollection:
+			collection, err := findCollection(ctx, bson.M{"_id": section.CollectionID})
+			if err == ErrCollectionNotFound {
+				continue
+			} else if err != nil {
+				return feed, err
+			}
+			out.Products, err = Products(ctx, collection, nil, int64(section.Limit))
+			if err != nil {
+				return feed, err
+			}
+			if len(out.Products) == 0 {
+				continue
+			}
+			out.Collection = &collection
+		}
+		feed.Sections = append(feed.Sections, out)
+	}
+	return feed, nil
+}
+
+// feedCache holds the current home feed until it goes stale or a window
+// opens or closes. Edits bump the generation, so a feed assembled during an
+// edit is not kept.
+var feedCache struct {
+	sync.Mutex
+	feed       *Feed
+	expires    time.Time
+	generation int
+}
+
+// Invalidate drops the cached home feed after an edit. Other instances of
+// the service pick the edit up within EnvHomeFeedTTL.
+func Invalidate() {
+	feedCache.Lock()
+	feedCache.feed = nil
+	feedCache.generation++
+	feedCache.Unlock()
+}
+
+// HomeFeed returns the current home feed and until when it may be cached.
+// Assembled feeds are reused for EnvHomeFeedTTL, but never past the next
+// scheduled change.
+func HomeFeed(ctx context.Context) (Feed, time.Time, error) {
+	now := time.Now()
+	feedCache.Lock()
+	if feedCache.feed != nil && now.Before(feedCache.expires) {
+		feed, expires := *feedCache.feed, feedCache.expires
+		feedCache.Unlock()
+		return feed, expires, nil
+	}
+	generation := feedCache.generation
+	feedCache.Unlock()
+
+	feed, err := BuildFeed(ctx, now)
+	if err != nil {
+		return feed, now, err
+	}
+	expires := now.Add(configs.EnvHomeFeedTTL())
+	if !feed.changesAt.IsZero() && feed.changesAt.Before(expires) {
+		expires = feed.changesAt
+	}
+
+	feedCache.Lock()
+	if feedCache.generation == generation {
+		feedCache.feed, feedCache.expires = &feed, expires
+	}
+	feedCache.Unlock()
+	return feed, expires, nil
+}
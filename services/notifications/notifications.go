@@ -0,0 +1,106 @@
+package notificationService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var notificationCollection *mongo.Collection = configs.GetCollection(configs.DB, "notifications")
+
+// retention is how long notifications are kept in the inbox.
+const retention = 90 * 24 * time.Hour
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// EnsureIndexes indexes the inbox, makes keys unique per user and expires
+// old notifications. It is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := notificationCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"key": bson.M{"$type": "string"}}),
+		},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds()))},
+	})
+	return err
+}
+
+// Notify delivers a notification to its user. A notification with the Key
+// of one the user already has is dropped, so jobs can retry safely.
+func Notify(ctx context.Context, notification models.Notification) error {
+	notification.ID = primitive.NewObjectID()
+	notification.CreatedAt = time.Now()
+	notification.ReadAt = nil
+	_, err := notificationCollection.InsertOne(ctx, notification)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// List returns a page of the user's notifications, newest first, with their
+// total and how many are unread.
+func List(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, page, limit int64) ([]models.Notification, int64, int64, error) {
+	notifications := []models.Notification{}
+	filter := bson.M{"userId": userID}
+	unreadFilter := bson.M{"userId": userID, "readAt": bson.M{"$exists": false}}
+	if unreadOnly {
+		filter = unreadFilter
+	}
+
+	total, err := notificationCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	unread, err := notificationCollection.CountDocuments(ctx, unreadFilter)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	cursor, err := notificationCollection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, 0, 0, err
+	}
+	return notifications, total, unread, nil
+}
+
+// MarkRead marks one of the user's notifications read.
+func MarkRead(ctx context.Context, userID, id primitive.ObjectID) error {
+	result, err := notificationCollection.UpdateOne(ctx,
+		bson.M{"_id": id, "userId": userID},
+		bson.M{"$min": bson.M{"readAt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks all of the user's notifications read and returns how
+// many were unread.
+func MarkAllRead(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := notificationCollection.UpdateMany(ctx,
+		bson.M{"userId": userID, "readAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"readAt": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
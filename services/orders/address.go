@@ -0,0 +1,33 @@
+package orderService
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AddressSnapshot copies the parts of an address an order ships to.
+func AddressSnapshot(address models.Address) *models.OrderAddress {
+	return &models.OrderAddress{
+		StreetAddress: address.StreetAddress,
+		City:          address.City,
+		State:         address.State,
+		ZipCode:       address.ZipCode,
+	}
+}
+
+// SnapshotAddress stores the address on orders that reference it but were
+// placed before orders kept their own copy. It is called before an address is
+// edited or deleted so those orders keep shipping where they were placed to.
+func SnapshotAddress(ctx context.Context, address models.Address) error {
+	_, err := orderCollection.UpdateMany(ctx,
+		bson.M{
+			"userId":          address.UserId,
+			"addressId":       address.Id,
+			"shippingAddress": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"shippingAddress": AddressSnapshot(address)}},
+	)
+	return err
+}
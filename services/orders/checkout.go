@@ -0,0 +1,85 @@
+package orderService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	promotionService "fiber-mongo-api/services/promotions"
+	"log"
+)
+
+// PlaceOrder takes the order's coupon uses, reserves its stock and stores it
+// in one transaction, so either all three happen or none does. Without
+// transaction support the steps run in turn and are undone on failure.
+// It returns promotionService.ErrCouponUsedUp, ErrCouponUserLimit or
+// ErrOutOfStock when the order cannot be placed.
+func PlaceOrder(ctx context.Context, order *models.Order) error {
+	return configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		return place(ctx, order, atomic)
+	})
+}
+
+// place runs the steps of PlaceOrder with the transaction's context.
+func place(ctx context.Context, order *models.Order, atomic bool) error {
+	if err := promotionService.Redeem(ctx, order.Discounts, order.UserID, order.ID); err != nil {
+		return err
+	}
+
+	if err := ReserveStock(ctx, order.Items); err != nil {
+		if !atomic {
+			promotionService.Release(ctx, order.Discounts, order.ID)
+		}
+		return err
+	}
+	order.StockReserved = true
+
+	if _, err := orderCollection.InsertOne(ctx, order); err != nil {
+		if !atomic {
+			ReturnStock(ctx, order.Items)
+			promotionService.Release(ctx, order.Discounts, order.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// CompletePayment marks a pending order paid and clears the ordered items
+// from the cart in one transaction, then issues the invoice. It reports false
+// when the order was no longer pending. Without transaction support a failed
+// cart clear is left on the order for the reconciliation job to retry, as is
+// a failed invoice either way. Pre-orders paid on dispatch are invoiced when
+// their payment is captured.
+func CompletePayment(ctx context.Context, order models.Order, paymentID string) (bool, error) {
+	var updated bool
+	err := configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		var err error
+		updated, err = MarkPaid(ctx, order, paymentID)
+		if err != nil || !updated {
+			return err
+		}
+
+		order.PendingSteps = []string{models.OrderStepClearCart}
+		if err := RunPendingSteps(ctx, order); err != nil {
+			if atomic {
+				return err
+			}
+			log.Printf("orders: post-payment steps for order %s: %v", order.ID.Hex(), err)
+		}
+		return nil
+	})
+	if err != nil || !updated {
+		return updated, err
+	}
+	invalidateRollup(ctx, order)
+	if CapturesOnDispatch(order) {
+		return updated, nil
+	}
+
+	// Invoice numbers are taken outside the transaction, so a payment never
+	// waits on or fails because of one
+	order.PendingSteps = []string{models.OrderStepIssueInvoice}
+	if err := RunPendingSteps(ctx, order); err != nil {
+		log.Printf("orders: issuing invoice for order %s: %v", order.ID.Hex(), err)
+	}
+	return updated, nil
+}
@@ -0,0 +1,152 @@
+package orderService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var shipmentCollection *mongo.Collection = configs.GetCollection(configs.DB, "shipments")
+
+var (
+	ErrCODCurrency       = errors.New("Cash on delivery is only available in " + configs.EnvBaseCurrency())
+	ErrCODPinCode        = errors.New("Cash on delivery is not available for this PIN code")
+	ErrCODOrderValue     = errors.New("Order value is above the cash on delivery limit")
+	ErrCODUserLimit      = errors.New("You have too many cash on delivery orders awaiting delivery")
+	ErrCODNotCollecting  = errors.New("Order is not awaiting cash on delivery collection")
+	ErrCODPreOrder       = errors.New("Cash on delivery is not available for pre-orders")
+	ErrCODNotDeliverable = errors.New("Order has a shipment that failed delivery or was returned")
+)
+
+// CheckCODEligibility decides whether an order can be paid cash on delivery:
+// it must hold no pre-ordered items, be in the base currency, ship to a PIN
+// code that allows COD, stay under the order value cap, and the user must
+// not have too many uncollected COD orders.
+func CheckCODEligibility(ctx context.Context, userID primitive.ObjectID, order models.Order) error {
+	if order.PreOrder != nil {
+		return ErrCODPreOrder
+	}
+	if order.Currency != configs.EnvBaseCurrency() {
+		return ErrCODCurrency
+	}
+
+	if order.Shipping == nil || !order.Shipping.CODAvailable {
+		return ErrCODPinCode
+	}
+
+	maxValue := configs.EnvCODMaxOrderValue()
+	if maxValue > 0 && order.Base != nil && order.Base.Total > currencyService.ToMinor(maxValue, order.Base.Currency) {
+		return ErrCODOrderValue
+	}
+
+	if limit := configs.EnvCODMaxOpenOrders(); limit > 0 {
+		open, err := orderCollection.CountDocuments(ctx, bson.M{
+			"userId":        userID,
+			"paymentMethod": models.PaymentCOD,
+			"paymentStatus": models.PaymentCODPending,
+		})
+		if err != nil {
+			return err
+		}
+		if open >= int64(limit) {
+			return ErrCODUserLimit
+		}
+	}
+	return nil
+}
+
+// PlaceCODOrder places a cash on delivery order. Unlike a prepaid order it is
+// confirmed straight away, so the ordered items leave the cart in the same
+// transaction.
+func PlaceCODOrder(ctx context.Context, order *models.Order) error {
+	return configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		order.PendingSteps = []string{models.OrderStepClearCart}
+		if err := place(ctx, order, atomic); err != nil {
+			return err
+		}
+
+		// Without a transaction a failed cart clear stays on the order for
+		// the reconciliation job to retry
+		if err := RunPendingSteps(ctx, *order); err != nil && atomic {
+			return err
+		}
+		return nil
+	})
+}
+
+// MarkCODCollected records that the cash for a COD order was collected on
+// delivery and issues its invoice. An order that is not delivered yet is
+// moved to delivered first, recorded in its history as done by adminID. It
+// returns ErrCODNotDeliverable when a shipment of the order failed delivery
+// or came back, and ErrInvalidStatusChange when the order cannot be
+// delivered from its status.
+func MarkCODCollected(ctx context.Context, orderID, adminID primitive.ObjectID) (models.Order, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return order, ErrOrderNotFound
+		}
+		return order, err
+	}
+	if order.PaymentMethod != models.PaymentCOD || order.PaymentStatus != models.PaymentCODPending {
+		return order, ErrCODNotCollecting
+	}
+
+	undeliverable, err := shipmentCollection.CountDocuments(ctx, bson.M{
+		"orderId": orderID,
+		"status":  bson.M{"$in": bson.A{models.ShipmentFailedDelivery, models.ShipmentReturned}},
+	})
+	if err != nil {
+		return order, err
+	}
+	if undeliverable > 0 {
+		return order, ErrCODNotDeliverable
+	}
+
+	// The carrier may already have reported the order delivered
+	if order.Status != "delivered" {
+		order, err = ChangeStatus(ctx, orderID, "delivered", models.OrderStatusChange{
+			Note: "Cash on delivery collected",
+			By:   &adminID,
+		})
+		if err != nil {
+			return order, err
+		}
+	}
+
+	now := time.Now()
+	result, err := orderCollection.UpdateOne(ctx,
+		bson.M{"_id": orderID, "status": "delivered", "paymentStatus": models.PaymentCODPending},
+		bson.M{
+			"$set": bson.M{
+				"paymentStatus": "completed",
+				"collectedAt":   now,
+				"updatedAt":     now,
+			},
+			"$addToSet": bson.M{"pendingSteps": models.OrderStepIssueInvoice},
+		},
+	)
+	if err != nil {
+		return order, err
+	}
+	if result.MatchedCount == 0 {
+		return order, ErrCODNotCollecting
+	}
+	invalidateRollup(ctx, order)
+
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		return order, err
+	}
+	if err := RunPendingSteps(ctx, order); err != nil {
+		log.Printf("orders: steps after collecting order %s: %v", order.ID.Hex(), err)
+	}
+	return order, nil
+}
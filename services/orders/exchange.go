@@ -0,0 +1,39 @@
+package orderService
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PlaceReplacement places the order that ships exchanged items, with the
+// given ID, to the original order's address. It is already paid for by the
+// original order, so it carries no charges and goes straight to processing.
+// Placing the same ID again is a no-op, so a failed exchange can be retried.
+// It returns ErrOutOfStock when the items cannot be reserved.
+func PlaceReplacement(ctx context.Context, id primitive.ObjectID, original models.Order, items []models.OrderItem, returnID primitive.ObjectID) (models.Order, error) {
+	now := time.Now()
+	order := models.Order{
+		ID:              id,
+		UserID:          original.UserID,
+		AddressID:       original.AddressID,
+		ShippingAddress: original.ShippingAddress,
+		Items:           items,
+		Currency:        original.Currency,
+		Status:          "processing",
+		PaymentMethod:   models.PaymentExchange,
+		PaymentStatus:   "completed",
+		ReplacementFor:  &returnID,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	err := PlaceOrder(ctx, &order)
+	if mongo.IsDuplicateKeyError(err) {
+		return order, nil
+	}
+	return order, err
+}
@@ -0,0 +1,142 @@
+package orderService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	invoiceService "fiber-mongo-api/services/invoices"
+	promotionService "fiber-mongo-api/services/promotions"
+	reportService "fiber-mongo-api/services/reports"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MarkPaid records a verified payment on a pending order and queues the
+// post-payment steps. A pre-order paid on dispatch is only authorized, and
+// is invoiced once the payment is captured. It reports false when the order
+// was no longer pending, for example because it was verified or expired in
+// the meantime.
+func MarkPaid(ctx context.Context, order models.Order, paymentID string) (bool, error) {
+	now := time.Now()
+	set := bson.M{
+		"paymentStatus": "completed",
+		"status":        "processing", // Change order status to processing after payment
+		"paymentId":     paymentID,
+		"pendingSteps":  []string{models.OrderStepClearCart, models.OrderStepIssueInvoice},
+		"updatedAt":     now,
+	}
+	if CapturesOnDispatch(order) {
+		set["paymentStatus"] = models.PaymentAuthorized
+		set["authorizedAt"] = now
+		set["pendingSteps"] = []string{models.OrderStepClearCart}
+	}
+
+	result, err := orderCollection.UpdateOne(ctx,
+		bson.M{"_id": order.ID, "paymentStatus": "pending"},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// invalidateRollup marks the sales rollup of the day the order was placed
+// stale after its payment or refunds changed, so the rollup job rebuilds it.
+// The change stands if this fails; an admin rebuild of the day corrects it.
+func invalidateRollup(ctx context.Context, order models.Order) {
+	if err := reportService.Invalidate(ctx, order.CreatedAt); err != nil {
+		log.Printf("orders: invalidating the sales rollup for order %s: %v", order.ID.Hex(), err)
+	}
+}
+
+// closeUnpaid moves a pending order to status with a failed payment and gives
+// back its stock and coupon uses, in one transaction where supported. It
+// reports false when the order was no longer pending.
+func closeUnpaid(ctx context.Context, order models.Order, status string) (bool, error) {
+	var closed bool
+	err := configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		result, err := orderCollection.UpdateOne(ctx,
+			bson.M{"_id": order.ID, "status": "pending", "paymentStatus": "pending"},
+			bson.M{"$set": bson.M{
+				"status":        status,
+				"paymentStatus": "failed",
+				"updatedAt":     time.Now(),
+			}},
+		)
+		if err != nil {
+			return err
+		}
+		closed = result.ModifiedCount > 0
+		if !closed {
+			return nil
+		}
+
+		if err := ReleaseStock(ctx, order); err != nil {
+			return err
+		}
+		return promotionService.Release(ctx, order.Discounts, order.ID)
+	})
+	return closed, err
+}
+
+// clearOrderedCart removes the ordered lines from the shopper's cart, and the
+// coupon if it is still the one the order used. Lines added after checkout
+// are kept.
+func clearOrderedCart(ctx context.Context, order models.Order) error {
+	lineIDs := make([]primitive.ObjectID, 0, len(order.Items))
+	for _, item := range order.Items {
+		if !item.LineID.IsZero() {
+			lineIDs = append(lineIDs, item.LineID)
+		}
+	}
+
+	if len(lineIDs) > 0 {
+		_, err := userCollection.UpdateOne(ctx, bson.M{"_id": order.UserID},
+			bson.M{"$pull": bson.M{"cart": bson.M{"lineId": bson.M{"$in": lineIDs}}}})
+		if err != nil {
+			return err
+		}
+	}
+
+	if order.CouponCode != "" {
+		_, err := userCollection.UpdateOne(ctx, bson.M{"_id": order.UserID, "couponCode": order.CouponCode},
+			bson.M{"$unset": bson.M{"couponCode": ""}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunPendingSteps runs the post-payment steps still recorded on the order,
+// removing each one once it succeeds. Steps that fail stay on the order and
+// are retried by the reconciliation job.
+func RunPendingSteps(ctx context.Context, order models.Order) error {
+	for _, step := range order.PendingSteps {
+		var err error
+		switch step {
+		case models.OrderStepClearCart:
+			err = clearOrderedCart(ctx, order)
+		case models.OrderStepCapturePayment:
+			err = capturePayment(ctx, order)
+		case models.OrderStepIssueInvoice:
+			_, err = invoiceService.Issue(ctx, order.ID)
+			if err == invoiceService.ErrNotInvoiceable {
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := orderCollection.UpdateOne(ctx, bson.M{"_id": order.ID},
+			bson.M{"$pull": bson.M{"pendingSteps": step}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
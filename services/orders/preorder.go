@@ -0,0 +1,106 @@
+package orderService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	productService "fiber-mongo-api/services/products"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// captureProvider captures the authorized payments of pre-orders as they
+// are dispatched.
+var captureProvider PaymentProvider = NewRazorpayProvider()
+
+// ApplyPreOrder stamps the release date on each pre-ordered item of a new
+// order and flags the order as a pre-order. The order is paid on dispatch
+// only when every pre-ordered item allows it, it is paid online and the
+// latest release falls within the time an authorized payment stays valid;
+// otherwise it is charged in full at checkout.
+func ApplyPreOrder(order *models.Order, now time.Time) {
+	order.PreOrder = nil
+	authorize := true
+	for i := range order.Items {
+		product := order.Items[i].Product
+		if !productService.PreOrderActive(product, now) {
+			continue
+		}
+		releaseDate := product.PreOrder.ReleaseDate
+		order.Items[i].ReleaseDate = &releaseDate
+
+		if order.PreOrder == nil {
+			order.PreOrder = &models.OrderPreOrder{}
+		}
+		if releaseDate.After(order.PreOrder.ReleaseDate) {
+			order.PreOrder.ReleaseDate = releaseDate
+		}
+		if product.PreOrder.Payment != models.PreOrderAuthorize {
+			authorize = false
+		}
+	}
+
+	if order.PreOrder != nil {
+		order.PreOrder.CaptureOnDispatch = authorize &&
+			order.PaymentMethod == models.PaymentRazorpay &&
+			order.PreOrder.ReleaseDate.Before(now.Add(configs.EnvPreOrderAuthValidity()))
+	}
+}
+
+// CapturesOnDispatch reports whether the order's payment is only authorized
+// at checkout and captured when the order ships.
+func CapturesOnDispatch(order models.Order) bool {
+	return order.PreOrder != nil && order.PreOrder.CaptureOnDispatch
+}
+
+// hasStep reports whether the step is still pending on the order.
+func hasStep(order models.Order, step string) bool {
+	for _, pending := range order.PendingSteps {
+		if pending == step {
+			return true
+		}
+	}
+	return false
+}
+
+// capturePayment captures the authorized payment of a dispatched order and
+// marks the order paid. A payment the provider already captured, because an
+// earlier attempt failed after the capture, is not captured again.
+func capturePayment(ctx context.Context, order models.Order) error {
+	if order.PaymentStatus != models.PaymentAuthorized {
+		return nil
+	}
+
+	if err := captureProvider.CapturePayment(ctx, order.PaymentID, order.TotalAmount.Amount, order.TotalAmount.Currency); err != nil {
+		attempts, lookupErr := captureProvider.OrderPayments(ctx, order.RazorpayID)
+		if lookupErr != nil || !isCaptured(attempts, order.PaymentID) {
+			return err
+		}
+	}
+
+	now := time.Now()
+	_, err := orderCollection.UpdateOne(ctx,
+		bson.M{"_id": order.ID, "paymentStatus": models.PaymentAuthorized},
+		bson.M{"$set": bson.M{
+			"paymentStatus": "completed",
+			"capturedAt":    now,
+			"updatedAt":     now,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	invalidateRollup(ctx, order)
+	return nil
+}
+
+// isCaptured reports whether the payment is among the captured attempts.
+func isCaptured(attempts []PaymentAttempt, paymentID string) bool {
+	for _, attempt := range attempts {
+		if attempt.ID == paymentID && attempt.Status == "captured" {
+			return true
+		}
+	}
+	return false
+}
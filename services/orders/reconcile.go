@@ -0,0 +1,207 @@
+package orderService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"log"
+	"time"
+
+	"github.com/razorpay/razorpay-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reconcileBatchSize caps how many orders one run looks at, so a backlog is
+// worked through over several runs instead of one long one.
+const reconcileBatchSize = 100
+
+// stepRetryDelay leaves VerifyPayment time to run the post-payment steps
+// itself before the job retries them.
+const stepRetryDelay = time.Minute
+
+// PaymentAttempt is a payment made against a provider order.
+type PaymentAttempt struct {
+	ID     string
+	Status string // created, authorized, captured, refunded, failed
+}
+
+// PaymentProvider looks up the payments made against a provider order,
+// captures authorized ones and refunds them.
+type PaymentProvider interface {
+	OrderPayments(ctx context.Context, providerOrderID string) ([]PaymentAttempt, error)
+	// CapturePayment captures amount, in minor units of currency, of an
+	// authorized payment.
+	CapturePayment(ctx context.Context, paymentID string, amount int64, currency string) error
+	// RefundPayment refunds amount, in minor units, of a captured payment and
+	// returns the provider's refund ID.
+	RefundPayment(ctx context.Context, paymentID string, amount int64) (string, error)
+}
+
+type razorpayProvider struct {
+	client *razorpay.Client
+}
+
+// NewRazorpayProvider returns the provider backed by the Razorpay API.
+func NewRazorpayProvider() PaymentProvider {
+	return razorpayProvider{client: razorpay.NewClient(configs.EnvRazorpayKeyId(), configs.EnvRazorpayKeySecret())}
+}
+
+func (p razorpayProvider) OrderPayments(ctx context.Context, providerOrderID string) ([]PaymentAttempt, error) {
+	body, err := p.client.Order.Payments(providerOrderID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items, _ := body["items"].([]interface{})
+	attempts := make([]PaymentAttempt, 0, len(items))
+	for _, item := range items {
+		payment, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := payment["id"].(string)
+		status, _ := payment["status"].(string)
+		attempts = append(attempts, PaymentAttempt{ID: id, Status: status})
+	}
+	return attempts, nil
+}
+
+func (p razorpayProvider) CapturePayment(ctx context.Context, paymentID string, amount int64, currency string) error {
+	_, err := p.client.Payment.Capture(paymentID, int(amount), map[string]interface{}{"currency": currency}, nil)
+	return err
+}
+
+func (p razorpayProvider) RefundPayment(ctx context.Context, paymentID string, amount int64) (string, error) {
+	body, err := p.client.Payment.Refund(paymentID, int(amount), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	id, _ := body["id"].(string)
+	return id, nil
+}
+
+// ReconcileJob returns the background job that settles stuck orders.
+func ReconcileJob(provider PaymentProvider) func(ctx context.Context) (map[string]int, error) {
+	return func(ctx context.Context) (map[string]int, error) {
+		return Reconcile(ctx, provider)
+	}
+}
+
+// Reconcile settles orders whose payment was never verified and retries
+// post-payment steps that failed. Pending orders older than the stale period
+// are looked up at the payment provider: captured payments, or authorized
+// ones on pre-orders paid on dispatch, mark the order paid; past the expiry
+// period the order is cancelled if payment attempts failed or expired if
+// there were none, and its stock and coupon uses are released. The returned
+// counts describe what the run did.
+func Reconcile(ctx context.Context, provider PaymentProvider) (map[string]int, error) {
+	results := map[string]int{}
+	now := time.Now()
+
+	if err := retryPendingSteps(ctx, now, results); err != nil {
+		return results, err
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(reconcileBatchSize)
+	cursor, err := orderCollection.Find(ctx, bson.M{
+		"status":        "pending",
+		"paymentStatus": "pending",
+		"createdAt":     bson.M{"$lt": now.Add(-configs.EnvOrderStaleAfter())},
+	}, findOptions)
+	if err != nil {
+		return results, err
+	}
+	var orders []models.Order
+	if err := cursor.All(ctx, &orders); err != nil {
+		return results, err
+	}
+
+	expireBefore := now.Add(-configs.EnvOrderExpireAfter())
+	for _, order := range orders {
+		results["checked"]++
+
+		attempts, err := provider.OrderPayments(ctx, order.RazorpayID)
+		if err != nil {
+			log.Printf("reconcile: fetching payments for order %s: %v", order.ID.Hex(), err)
+			results["providerErrors"]++
+			continue
+		}
+
+		captured, failed := "", false
+		for _, attempt := range attempts {
+			switch attempt.Status {
+			case "captured":
+				captured = attempt.ID
+			case "authorized":
+				// A pre-order paid on dispatch is paid once authorized
+				if CapturesOnDispatch(order) && captured == "" {
+					captured = attempt.ID
+				}
+			case "failed":
+				failed = true
+			}
+		}
+
+		switch {
+		case captured != "":
+			if _, err := CompletePayment(ctx, order, captured); err != nil {
+				log.Printf("reconcile: marking order %s paid: %v", order.ID.Hex(), err)
+				results["errors"]++
+				continue
+			}
+			results["paid"]++
+		case order.CreatedAt.Before(expireBefore) && !hasAuthorized(attempts):
+			status, counter := "expired", "expired"
+			if failed {
+				status, counter = "cancelled", "failed"
+			}
+			if _, err := closeUnpaid(ctx, order, status); err != nil {
+				log.Printf("reconcile: closing order %s: %v", order.ID.Hex(), err)
+				results["errors"]++
+				continue
+			}
+			results[counter]++
+		default:
+			results["stillPending"]++
+		}
+	}
+
+	return results, nil
+}
+
+// hasAuthorized reports whether a payment is authorized but not captured
+// yet. Such orders are left alone; the provider captures or refunds them.
+func hasAuthorized(attempts []PaymentAttempt) bool {
+	for _, attempt := range attempts {
+		if attempt.Status == "authorized" {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPendingSteps runs post-payment steps left over on paid orders.
+func retryPendingSteps(ctx context.Context, now time.Time, results map[string]int) error {
+	cursor, err := orderCollection.Find(ctx, bson.M{
+		"pendingSteps": bson.M{"$exists": true, "$ne": bson.A{}},
+		"updatedAt":    bson.M{"$lt": now.Add(-stepRetryDelay)},
+	}, options.Find().SetLimit(reconcileBatchSize))
+	if err != nil {
+		return err
+	}
+	var orders []models.Order
+	if err := cursor.All(ctx, &orders); err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		if err := RunPendingSteps(ctx, order); err != nil {
+			log.Printf("reconcile: retrying steps for order %s: %v", order.ID.Hex(), err)
+			results["stepsFailed"]++
+			continue
+		}
+		results["stepsRetried"]++
+	}
+	return nil
+}
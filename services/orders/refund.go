@@ -0,0 +1,142 @@
+package orderService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrNotRefundable     = errors.New("only paid orders can be refunded")
+	ErrRefundExceedsPaid = errors.New("refund is more than what is left to refund on the order")
+	ErrRefundCurrency    = errors.New("refund must be in the order's currency")
+)
+
+// refundMethod is how an order's payment is paid back.
+func refundMethod(order models.Order) string {
+	if order.PaymentMethod == models.PaymentCOD || order.PaymentID == "" {
+		return models.RefundManual
+	}
+	return models.RefundRazorpay
+}
+
+// Refund pays amount, in the order's currency, back on a paid order: through
+// the payment provider for online payments, or recorded as a manual payout
+// for cash on delivery. The refund is recorded on the order before the
+// provider is called and removed again if the call fails, so the order's
+// refunds can never add up to more than it was paid. With a returnID only one
+// refund is made per return; repeating the call returns the first one.
+func Refund(ctx context.Context, provider PaymentProvider, orderID primitive.ObjectID, amount models.Money, reason string, returnID *primitive.ObjectID) (models.Refund, error) {
+	var refund models.Refund
+	if amount.Amount <= 0 {
+		return refund, ErrRefundExceedsPaid
+	}
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return refund, ErrOrderNotFound
+		}
+		return refund, err
+	}
+	if order.PaymentStatus != "completed" {
+		return refund, ErrNotRefundable
+	}
+	if amount.Currency != order.TotalAmount.Currency {
+		return refund, ErrRefundCurrency
+	}
+
+	refund = models.Refund{
+		ID:        primitive.NewObjectID(),
+		Amount:    amount,
+		Method:    refundMethod(order),
+		Status:    models.RefundPending,
+		ReturnID:  returnID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if refund.Method == models.RefundManual {
+		refund.Status = models.RefundProcessed
+	}
+
+	// Older orders kept the refunded total as a plain number, which cannot
+	// be incremented field by field
+	if _, err := orderCollection.UpdateOne(ctx, bson.M{"_id": orderID, "refundedTotal": bson.M{"$type": "double"}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"refundedTotal": bson.M{
+			"amount":   bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{"$refundedTotal", 100}}, 0}}},
+			"currency": order.TotalAmount.Currency,
+		}}}},
+	}); err != nil {
+		return refund, err
+	}
+
+	// The order total never changes, so it is compared as read
+	filter := bson.M{
+		"_id":           orderID,
+		"paymentStatus": "completed",
+		"$expr": bson.M{"$lte": bson.A{
+			bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$refundedTotal.amount", 0}}, amount.Amount}},
+			order.TotalAmount.Amount,
+		}},
+	}
+	if returnID != nil {
+		filter["refunds.returnId"] = bson.M{"$ne": *returnID}
+	}
+	result, err := orderCollection.UpdateOne(ctx, filter, bson.M{
+		"$push": bson.M{"refunds": refund},
+		"$inc":  bson.M{"refundedTotal.amount": amount.Amount},
+		"$set":  bson.M{"refundedTotal.currency": amount.Currency, "updatedAt": time.Now()},
+	})
+	if err != nil {
+		return refund, err
+	}
+	if result.MatchedCount == 0 {
+		if returnID != nil {
+			if existing, ok := refundForReturn(ctx, orderID, *returnID); ok {
+				return existing, nil
+			}
+		}
+		return refund, ErrRefundExceedsPaid
+	}
+	invalidateRollup(ctx, order)
+
+	if refund.Method == models.RefundManual {
+		return refund, nil
+	}
+
+	providerID, err := provider.RefundPayment(ctx, order.PaymentID, amount.Amount)
+	if err != nil {
+		orderCollection.UpdateOne(ctx, bson.M{"_id": orderID, "refunds._id": refund.ID}, bson.M{
+			"$pull": bson.M{"refunds": bson.M{"_id": refund.ID}},
+			"$inc":  bson.M{"refundedTotal.amount": -amount.Amount},
+		})
+		return refund, err
+	}
+
+	refund.Status = models.RefundProcessed
+	refund.ProviderRefundID = providerID
+	_, err = orderCollection.UpdateOne(ctx, bson.M{"_id": orderID, "refunds._id": refund.ID}, bson.M{"$set": bson.M{
+		"refunds.$.status":           refund.Status,
+		"refunds.$.providerRefundId": providerID,
+	}})
+	return refund, err
+}
+
+func refundForReturn(ctx context.Context, orderID, returnID primitive.ObjectID) (models.Refund, bool) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		return models.Refund{}, false
+	}
+	for _, refund := range order.Refunds {
+		if refund.ReturnID != nil && *refund.ReturnID == returnID {
+			return refund, true
+		}
+	}
+	return models.Refund{}, false
+}
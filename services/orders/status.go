@@ -0,0 +1,141 @@
+package orderService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrInvalidStatusChange = errors.New("order cannot move to that status from its current one")
+
+// statusTransitions lists where an order can move from each status. Orders
+// leave pending through payment, or are cancelled; expired, cancelled and
+// delivered orders stay where they are.
+var statusTransitions = map[string][]string{
+	"pending":    {"cancelled"},
+	"processing": {"shipped", "delivered", "cancelled"},
+	"shipped":    {"delivered"},
+}
+
+// CanChangeStatus reports whether an order can move from one status to the
+// other.
+func CanChangeStatus(from, to string) bool {
+	for _, status := range statusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeStatus moves an order to status if the state machine allows it, and
+// records the change in its history. Cancelling gives back the order's
+// stock; an unpaid order also gets its coupon uses back. Paid orders are not
+// refunded here, see Refund. An authorized pre-order payment is captured
+// when the order ships, or voided when it is cancelled; the provider
+// releases an authorization that is never captured. It returns
+// ErrInvalidStatusChange when the order cannot make the move, including
+// when it changed status concurrently.
+func ChangeStatus(ctx context.Context, orderID primitive.ObjectID, status string, change models.OrderStatusChange) (models.Order, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return order, ErrOrderNotFound
+		}
+		return order, err
+	}
+	if !CanChangeStatus(order.Status, status) {
+		return order, ErrInvalidStatusChange
+	}
+
+	change.Status = status
+	if change.At.IsZero() {
+		change.At = time.Now()
+	}
+	push := bson.M{"statusHistory": change}
+
+	if order.Status == "pending" {
+		closed, err := closeUnpaid(ctx, order, status)
+		if err == nil && !closed {
+			err = ErrInvalidStatusChange
+		}
+		if err != nil {
+			return order, err
+		}
+		if _, err := orderCollection.UpdateOne(ctx, bson.M{"_id": orderID}, bson.M{"$push": push}); err != nil {
+			return order, err
+		}
+		return order, orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order)
+	}
+
+	set := bson.M{"status": status, "updatedAt": time.Now()}
+	switch status {
+	case "delivered":
+		set["deliveredAt"] = change.At
+	case "cancelled":
+		if order.PaymentStatus == models.PaymentCODPending {
+			set["paymentStatus"] = "failed"
+		}
+		if order.PaymentStatus == models.PaymentAuthorized {
+			set["paymentStatus"] = models.PaymentVoided
+		}
+	}
+
+	// A pre-order paid on dispatch is charged as it leaves, then invoiced
+	capture := (status == "shipped" || status == "delivered") &&
+		order.PaymentStatus == models.PaymentAuthorized && !hasStep(order, models.OrderStepCapturePayment)
+	if capture {
+		push["pendingSteps"] = bson.M{"$each": bson.A{models.OrderStepCapturePayment, models.OrderStepIssueInvoice}}
+	}
+
+	err := configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		result, err := orderCollection.UpdateOne(ctx,
+			bson.M{"_id": orderID, "status": order.Status},
+			bson.M{"$set": set, "$push": push},
+		)
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return ErrInvalidStatusChange
+		}
+		if status == "cancelled" {
+			return ReleaseStock(ctx, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return order, err
+	}
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		return order, err
+	}
+
+	// The move stands if the capture fails; the reconciliation job retries it
+	if capture {
+		if err := RunPendingSteps(ctx, order); err != nil {
+			log.Printf("orders: capturing payment for order %s: %v", order.ID.Hex(), err)
+		}
+		return order, orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order)
+	}
+	return order, nil
+}
+
+// AddNote leaves an internal note on an order.
+func AddNote(ctx context.Context, orderID primitive.ObjectID, note models.OrderNote) error {
+	result, err := orderCollection.UpdateOne(ctx, bson.M{"_id": orderID}, bson.M{"$push": bson.M{"notes": note}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
+}
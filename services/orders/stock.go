@@ -0,0 +1,80 @@
+package orderService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var orderCollection *mongo.Collection = configs.GetCollection(configs.DB, "orders")
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+var userCollection *mongo.Collection = configs.GetCollection(configs.DB, "users")
+
+var ErrOutOfStock = errors.New("Not enough stock for one or more items")
+
+// stockByProduct adds up the ordered quantity per product across sizes.
+func stockByProduct(items []models.OrderItem) ([]primitive.ObjectID, map[primitive.ObjectID]int) {
+	var productIDs []primitive.ObjectID
+	quantities := make(map[primitive.ObjectID]int)
+	for _, item := range items {
+		if _, seen := quantities[item.ProductID]; !seen {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+	return productIDs, quantities
+}
+
+// ReserveStock takes the ordered quantities out of stock so they cannot be
+// sold twice while the order waits for payment. Either every item is reserved
+// or none is, and ErrOutOfStock is returned.
+func ReserveStock(ctx context.Context, items []models.OrderItem) error {
+	productIDs, quantities := stockByProduct(items)
+	for i, productID := range productIDs {
+		quantity := quantities[productID]
+		result, err := productCollection.UpdateOne(ctx,
+			bson.M{"_id": productID, "quantity": bson.M{"$gte": quantity}},
+			bson.M{"$inc": bson.M{"quantity": -quantity}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrOutOfStock
+		}
+		if err != nil {
+			for _, reserved := range productIDs[:i] {
+				productCollection.UpdateOne(ctx, bson.M{"_id": reserved}, bson.M{"$inc": bson.M{"quantity": quantities[reserved]}})
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ReturnStock puts reserved quantities back. Use it for a reservation that
+// never made it onto a stored order; stored orders go through ReleaseStock.
+func ReturnStock(ctx context.Context, items []models.OrderItem) error {
+	productIDs, quantities := stockByProduct(items)
+	for _, productID := range productIDs {
+		if _, err := productCollection.UpdateOne(ctx, bson.M{"_id": productID}, bson.M{"$inc": bson.M{"quantity": quantities[productID]}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseStock returns an order's reserved stock. The order's stockReserved
+// flag is cleared first, so stock is only ever returned once.
+func ReleaseStock(ctx context.Context, order models.Order) error {
+	result, err := orderCollection.UpdateOne(ctx,
+		bson.M{"_id": order.ID, "stockReserved": true},
+		bson.M{"$unset": bson.M{"stockReserved": ""}},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return err
+	}
+	return ReturnStock(ctx, order.Items)
+}
@@ -0,0 +1,60 @@
+package pricing
+
+import "math"
+
+// Arithmetic on amounts in minor units, kept free of the database so it can
+// be tested on its own. Every function rounds to the nearest minor unit.
+
+// Percent is percent of an amount.
+func Percent(amount int64, percent float64) int64 {
+	return int64(math.Round(float64(amount) * percent / 100))
+}
+
+// Share is part/whole of an amount, such as the refund for some of the units
+// of an order line.
+func Share(amount, part, whole int64) int64 {
+	return int64(math.Round(float64(amount) * float64(part) / float64(whole)))
+}
+
+// ClampDiscount caps a discount at maxDiscount, when that is set, and at the
+// value of the items it covers.
+func ClampDiscount(discount, maxDiscount, subtotal int64) int64 {
+	if maxDiscount > 0 && discount > maxDiscount {
+		discount = maxDiscount
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+	return discount
+}
+
+// Apportion spreads an amount over lines in proportion to their values. The
+// last line takes what rounding leaves, so the shares always add up to the
+// amount.
+func Apportion(amount int64, values []int64) []int64 {
+	var whole int64
+	for _, value := range values {
+		whole += value
+	}
+
+	shares := make([]int64, len(values))
+	left := amount
+	for i, value := range values {
+		if i == len(values)-1 {
+			shares[i] = left
+			break
+		}
+		if whole > 0 {
+			shares[i] = Share(amount, value, whole)
+		}
+		left -= shares[i]
+	}
+	return shares
+}
+
+// SplitHalves splits an amount into two halves that add back up to it, the
+// first taking the odd minor unit.
+func SplitHalves(amount int64) (int64, int64) {
+	first := (amount + 1) / 2
+	return first, amount - first
+}
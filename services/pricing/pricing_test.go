@@ -0,0 +1,112 @@
+package pricing
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClampDiscount(t *testing.T) {
+	tests := []struct {
+		name                            string
+		discount, maxDiscount, subtotal int64
+		want                            int64
+	}{
+		{"under both caps", 5000, 10000, 20000, 5000},
+		{"capped at max discount", 15000, 10000, 20000, 10000},
+		{"no max discount", 15000, 0, 20000, 15000},
+		{"capped at subtotal", 25000, 0, 20000, 20000},
+		{"max discount above subtotal", 25000, 30000, 20000, 20000},
+		{"equal to subtotal", 20000, 0, 20000, 20000},
+	}
+	for _, tt := range tests {
+		if got := ClampDiscount(tt.discount, tt.maxDiscount, tt.subtotal); got != tt.want {
+			t.Errorf("%s: ClampDiscount(%d, %d, %d) = %d, want %d",
+				tt.name, tt.discount, tt.maxDiscount, tt.subtotal, got, tt.want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount  int64
+		percent float64
+		want    int64
+	}{
+		{100000, 18, 18000},
+		{999, 5, 50},
+		{333, 12, 40},
+		{1, 50, 1},
+		{0, 28, 0},
+	}
+	for _, tt := range tests {
+		if got := Percent(tt.amount, tt.percent); got != tt.want {
+			t.Errorf("Percent(%d, %v) = %d, want %d", tt.amount, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestApportion(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		values []int64
+		want   []int64
+	}{
+		{"even split", 1000, []int64{5000, 5000}, []int64{500, 500}},
+		{"last line takes the remainder", 100, []int64{1, 1, 1}, []int64{33, 33, 34}},
+		{"rounding up leaves less for the last line", 200, []int64{1, 1, 1}, []int64{67, 67, 66}},
+		{"proportional", 1500, []int64{10000, 20000}, []int64{500, 1000}},
+		{"single line", 777, []int64{12345}, []int64{777}},
+		{"no value", 100, []int64{0, 0}, []int64{0, 100}},
+		{"no amount", 0, []int64{3000, 7000}, []int64{0, 0}},
+		{"no lines", 100, nil, []int64{}},
+	}
+	for _, tt := range tests {
+		got := Apportion(tt.amount, tt.values)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Apportion(%d, %v) = %v, want %v", tt.name, tt.amount, tt.values, got, tt.want)
+		}
+		var sum int64
+		for _, share := range got {
+			sum += share
+		}
+		if len(tt.values) > 0 && sum != tt.amount {
+			t.Errorf("%s: shares add up to %d, want %d", tt.name, sum, tt.amount)
+		}
+	}
+}
+
+func TestSplitHalves(t *testing.T) {
+	tests := []struct {
+		amount        int64
+		first, second int64
+	}{
+		{1800, 900, 900},
+		{1801, 901, 900},
+		{1, 1, 0},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		first, second := SplitHalves(tt.amount)
+		if first != tt.first || second != tt.second {
+			t.Errorf("SplitHalves(%d) = %d, %d, want %d, %d", tt.amount, first, second, tt.first, tt.second)
+		}
+	}
+}
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		amount, part, whole int64
+		want                int64
+	}{
+		{10000, 1, 3, 3333},
+		{10000, 2, 3, 6667},
+		{10000, 3, 3, 10000},
+		{5, 1, 2, 3},
+	}
+	for _, tt := range tests {
+		if got := Share(tt.amount, tt.part, tt.whole); got != tt.want {
+			t.Errorf("Share(%d, %d, %d) = %d, want %d", tt.amount, tt.part, tt.whole, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package productService
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fiber-mongo-api/models"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Columns of the catalogue CSV. productId is only informative on import;
+// rows are matched by SKU. images and prices hold several values separated
+// by "|", prices as CODE:amount in minor units (USD:1299) and categories as
+// category slugs.
+var columns = []string{
+	"productId", "sku", "name", "brand", "description", "category", "price", "quantity",
+	"size", "hsnCode", "weightGrams", "images", "prices", "categories",
+}
+
+// requiredColumns must be in the header of an imported CSV.
+var requiredColumns = []string{"sku", "name", "brand", "description", "category", "price", "quantity", "images"}
+
+// catalogRow is one product as imported and exported.
+type catalogRow struct {
+	ProductID   string           `json:"productId,omitempty"`
+	SKU         string           `json:"sku"`
+	Name        string           `json:"name"`
+	Brand       string           `json:"brand"`
+	Description string           `json:"description"`
+	Category    string           `json:"category"`
+	Price       float64          `json:"price"`
+	Quantity    int              `json:"quantity"`
+	Size        string           `json:"size,omitempty"`
+	HSNCode     string           `json:"hsnCode,omitempty"`
+	WeightGrams int              `json:"weightGrams,omitempty"`
+	Images      []string         `json:"images"`
+	Prices      map[string]int64 `json:"prices,omitempty"`
+	Categories  []string         `json:"categories,omitempty"`
+}
+
+// rowFromProduct describes the product, naming its categories by slug.
+func rowFromProduct(product models.Product, slugs map[primitive.ObjectID]string) catalogRow {
+	var categories []string
+	for _, id := range product.CategoryIDs {
+		if slug, ok := slugs[id]; ok {
+			categories = append(categories, slug)
+		}
+	}
+	return catalogRow{
+		ProductID:   product.ID.Hex(),
+		SKU:         product.SKU,
+		Name:        product.Name,
+		Brand:       product.Brand,
+		Description: product.Description,
+		Category:    product.Category,
+		Price:       product.Price,
+		Quantity:    product.Quantity,
+		Size:        product.Size,
+		HSNCode:     product.HSNCode,
+		WeightGrams: product.WeightGrams,
+		Images:      product.Images,
+		Prices:      product.Prices,
+		Categories:  categories,
+	}
+}
+
+func (r catalogRow) product() models.Product {
+	return models.Product{
+		SKU:         strings.TrimSpace(r.SKU),
+		Name:        strings.TrimSpace(r.Name),
+		Brand:       strings.TrimSpace(r.Brand),
+		Description: strings.TrimSpace(r.Description),
+		Category:    strings.TrimSpace(r.Category),
+		Price:       r.Price,
+		Quantity:    r.Quantity,
+		Size:        strings.TrimSpace(r.Size),
+		HSNCode:     strings.TrimSpace(r.HSNCode),
+		WeightGrams: r.WeightGrams,
+		Images:      r.Images,
+		Prices:      r.Prices,
+	}
+}
+
+// parsedRow is an imported row, or why it could not be read. Categories
+// holds the slugs of its categories until they are looked up.
+type parsedRow struct {
+	Row        int
+	Product    models.Product
+	Categories []string
+	Errors     []models.ImportRowError
+}
+
+func (p *parsedRow) fail(field, message string) {
+	p.Errors = append(p.Errors, models.ImportRowError{Row: p.Row, SKU: p.Product.SKU, Field: field, Message: message})
+}
+
+// parse reads every row of an import file. Rows that cannot be read carry
+// their errors; an error is returned only when the file as a whole is
+// unreadable, such as a CSV with missing columns.
+func parse(format string, data []byte) ([]parsedRow, error) {
+	switch format {
+	case models.ImportCSV:
+		return parseCSV(data)
+	case models.ImportNDJSON:
+		return parseNDJSON(data)
+	}
+	return nil, ErrInvalidFormat
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func parseCSV(data []byte) ([]parsedRow, error) {
+	// Spreadsheet apps often start their CSV files with a byte order mark
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrEmptyFile
+	} else if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, column := range columns {
+			known = known || column == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		index[name] = i
+	}
+	for _, column := range requiredColumns {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	var rows []parsedRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		// Rows are numbered by the line they start on, as spreadsheets show them
+		var row parsedRow
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			row.Row = parseErr.StartLine
+			row.fail("", parseErr.Err.Error())
+			rows = append(rows, row)
+			continue
+		}
+		row.Row, _ = reader.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		var r catalogRow
+		r.SKU = get("sku")
+		r.Name = get("name")
+		r.Brand = get("brand")
+		r.Description = get("description")
+		r.Category = get("category")
+		r.Size = get("size")
+		r.HSNCode = get("hsnCode")
+		r.Images = splitList(get("images"))
+		r.Categories = splitList(get("categories"))
+		row.Product.SKU = r.SKU
+
+		if r.Price, err = strconv.ParseFloat(get("price"), 64); err != nil {
+			row.fail("price", "must be a number")
+		}
+		if r.Quantity, err = strconv.Atoi(get("quantity")); err != nil {
+			row.fail("quantity", "must be a whole number")
+		}
+		if value := get("weightGrams"); value != "" {
+			if r.WeightGrams, err = strconv.Atoi(value); err != nil {
+				row.fail("weightGrams", "must be a whole number")
+			}
+		}
+		for _, price := range splitList(get("prices")) {
+			code, amount, ok := strings.Cut(price, ":")
+			minor, err := strconv.ParseInt(strings.TrimSpace(amount), 10, 64)
+			if !ok || err != nil {
+				row.fail("prices", "must be CODE:amount pairs such as USD:1299")
+				break
+			}
+			if r.Prices == nil {
+				r.Prices = map[string]int64{}
+			}
+			r.Prices[strings.ToUpper(strings.TrimSpace(code))] = minor
+		}
+
+		if len(row.Errors) == 0 {
+			row.Product = r.product()
+			row.Categories = r.Categories
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// maxLineSize bounds one NDJSON line.
+const maxLineSize = 1 << 20
+
+func parseNDJSON(data []byte) ([]parsedRow, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var rows []parsedRow
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := parsedRow{Row: line}
+		var r catalogRow
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&r); err != nil {
+			row.Product.SKU = strings.TrimSpace(r.SKU)
+			row.fail("", "invalid JSON: "+err.Error())
+		} else {
+			row.Product = r.product()
+			row.Categories = r.Categories
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading NDJSON: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyFile
+	}
+	return rows, nil
+}
+
+// writer writes products in one of the catalogue formats.
+type writer interface {
+	write(product models.Product) error
+	flush() error
+}
+
+type csvWriter struct {
+	w     *csv.Writer
+	slugs map[primitive.ObjectID]string
+}
+
+func (c csvWriter) write(product models.Product) error {
+	r := rowFromProduct(product, c.slugs)
+
+	codes := make([]string, 0, len(r.Prices))
+	for code := range r.Prices {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	prices := make([]string, 0, len(codes))
+	for _, code := range codes {
+		prices = append(prices, code+":"+strconv.FormatInt(r.Prices[code], 10))
+	}
+
+	weight := ""
+	if r.WeightGrams != 0 {
+		weight = strconv.Itoa(r.WeightGrams)
+	}
+
+	return c.w.Write([]string{
+		r.ProductID, r.SKU, r.Name, r.Brand, r.Description, r.Category,
+		strconv.FormatFloat(r.Price, 'f', -1, 64), strconv.Itoa(r.Quantity),
+		r.Size, r.HSNCode, weight, strings.Join(r.Images, "|"), strings.Join(prices, "|"),
+		strings.Join(r.Categories, "|"),
+	})
+}
+
+func (c csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	e     *json.Encoder
+	slugs map[primitive.ObjectID]string
+}
+
+func (n ndjsonWriter) write(product models.Product) error {
+	return n.e.Encode(rowFromProduct(product, n.slugs))
+}
+
+func (n ndjsonWriter) flush() error { return nil }
+
+func newWriter(format string, w io.Writer, slugs map[primitive.ObjectID]string) (writer, error) {
+	switch format {
+	case models.ImportCSV:
+		c := csv.NewWriter(w)
+		return csvWriter{c, slugs}, c.Write(columns)
+	case models.ImportNDJSON:
+		return ndjsonWriter{json.NewEncoder(w), slugs}, nil
+	}
+	return nil, ErrInvalidFormat
+}
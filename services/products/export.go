@@ -0,0 +1,67 @@
+package productService
+
+import (
+	"context"
+	"fiber-mongo-api/models"
+	categoryService "fiber-mongo-api/services/categories"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportFlushEvery is how many products are written between flushes.
+const exportFlushEvery = 200
+
+// ValidFormat reports whether format is one imports and exports support.
+func ValidFormat(format string) bool {
+	return format == models.ImportCSV || format == models.ImportNDJSON
+}
+
+// Export writes the whole catalogue to w in the format, in the columns an
+// import reads. If w can be flushed it is flushed as products are written,
+// so the export streams instead of building up in memory.
+func Export(ctx context.Context, w io.Writer, format string) error {
+	slugs, err := categoryService.Slugs(ctx)
+	if err != nil {
+		return err
+	}
+	out, err := newWriter(format, w, slugs)
+	if err != nil {
+		return err
+	}
+	flush := func() error {
+		if err := out.flush(); err != nil {
+			return err
+		}
+		if flusher, ok := w.(interface{ Flush() error }); ok {
+			return flusher.Flush()
+		}
+		return nil
+	}
+
+	cursor, err := productCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for n := 1; cursor.Next(ctx); n++ {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return err
+		}
+		if err := out.write(product); err != nil {
+			return err
+		}
+		if n%exportFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
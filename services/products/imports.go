@@ -0,0 +1,355 @@
+package productService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/models"
+	categoryService "fiber-mongo-api/services/categories"
+	stockService "fiber-mongo-api/services/stock"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// ImportLease is how long an import may run before another instance
+	// takes it over.
+	ImportLease = 10 * time.Minute
+	// importBatchSize is how many rows are written per bulk write.
+	importBatchSize = 500
+	// maxImportErrors caps the row errors kept on an import.
+	maxImportErrors = 1000
+)
+
+var (
+	ErrInvalidFormat  = errors.New("format must be csv or ndjson")
+	ErrEmptyFile      = errors.New("the file has no rows")
+	ErrImportNotFound = errors.New("import not found")
+)
+
+// CreateImport queues an import of the file. Run it with Process; imports
+// that are never processed are picked up by the import job.
+func CreateImport(ctx context.Context, format, filename string, data []byte, by primitive.ObjectID) (models.ProductImport, error) {
+	if !ValidFormat(format) {
+		return models.ProductImport{}, ErrInvalidFormat
+	}
+	if len(data) == 0 {
+		return models.ProductImport{}, ErrEmptyFile
+	}
+
+	productImport := models.ProductImport{
+		ID:        primitive.NewObjectID(),
+		Format:    format,
+		Filename:  filename,
+		Status:    models.ImportQueued,
+		CreatedBy: by,
+		Data:      data,
+		Errors:    []models.ImportRowError{},
+		CreatedAt: time.Now(),
+	}
+	if _, err := importCollection.InsertOne(ctx, productImport); err != nil {
+		return models.ProductImport{}, err
+	}
+	productImport.Data = nil
+	return productImport, nil
+}
+
+// GetImport returns an import with its counts and row errors.
+func GetImport(ctx context.Context, id primitive.ObjectID) (models.ProductImport, error) {
+	var productImport models.ProductImport
+	err := importCollection.FindOne(ctx, bson.M{"_id": id},
+		options.FindOne().SetProjection(bson.M{"data": 0}),
+	).Decode(&productImport)
+	if err == mongo.ErrNoDocuments {
+		return productImport, ErrImportNotFound
+	}
+	return productImport, err
+}
+
+// ListImports returns a page of imports, newest first, without their row
+// errors.
+func ListImports(ctx context.Context, page, limit int64) ([]models.ProductImport, int64, error) {
+	total, err := importCollection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit).
+		SetProjection(bson.M{"data": 0, "errors": 0})
+	cursor, err := importCollection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	imports := []models.ProductImport{}
+	if err := cursor.All(ctx, &imports); err != nil {
+		return nil, 0, err
+	}
+	return imports, total, nil
+}
+
+// claim takes a queued import, or one whose lease ran out, for this run.
+// It returns ErrImportNotFound when there is nothing to take.
+func claim(ctx context.Context, filter bson.M) (models.ProductImport, error) {
+	now := time.Now()
+	filter["$or"] = bson.A{
+		bson.M{"status": models.ImportQueued},
+		bson.M{"status": models.ImportRunning, "leaseUntil": bson.M{"$lt": now}},
+	}
+
+	var productImport models.ProductImport
+	err := importCollection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"status": models.ImportRunning, "startedAt": now, "leaseUntil": now.Add(ImportLease)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&productImport)
+	if err == mongo.ErrNoDocuments {
+		return productImport, ErrImportNotFound
+	}
+	return productImport, err
+}
+
+// Process runs the import if nothing else is running it. Every valid row is
+// upserted by SKU; rows that fail are recorded on the import. Running an
+// import again gives the same result, so one abandoned half way is simply
+// started over.
+func Process(ctx context.Context, id primitive.ObjectID) error {
+	productImport, err := claim(ctx, bson.M{"_id": id})
+	if err == ErrImportNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return run(ctx, productImport)
+}
+
+// ImportJob processes imports that were queued but never run, or whose run
+// was abandoned, for the job runner.
+func ImportJob() func(ctx context.Context) (map[string]int, error) {
+	return func(ctx context.Context) (map[string]int, error) {
+		results := map[string]int{}
+		for {
+			productImport, err := claim(ctx, bson.M{})
+			if err == ErrImportNotFound {
+				return results, nil
+			} else if err != nil {
+				return results, err
+			}
+			if err := run(ctx, productImport); err != nil {
+				return results, err
+			}
+			results["imports"]++
+		}
+	}
+}
+
+// progress is the running state of an import.
+type progress struct {
+	models.ProductImport
+}
+
+func (p *progress) fail(errs ...models.ImportRowError) {
+	p.Failed++
+	for _, e := range errs {
+		if len(p.Errors) >= maxImportErrors {
+			p.ErrorsTruncated = true
+			return
+		}
+		p.Errors = append(p.Errors, e)
+	}
+}
+
+func (p *progress) save(ctx context.Context, set bson.M) error {
+	set["rows"] = p.Rows
+	set["created"] = p.Created
+	set["updated"] = p.Updated
+	set["failed"] = p.Failed
+	set["errors"] = p.Errors
+	set["errorsTruncated"] = p.ErrorsTruncated
+
+	update := bson.M{"$set": set}
+	if set["status"] == models.ImportCompleted || set["status"] == models.ImportFailed {
+		update["$unset"] = bson.M{"data": "", "leaseUntil": ""}
+	}
+	_, err := importCollection.UpdateOne(ctx, bson.M{"_id": p.ID, "status": models.ImportRunning}, update)
+	return err
+}
+
+func run(ctx context.Context, productImport models.ProductImport) error {
+	p := &progress{productImport}
+	p.Rows, p.Created, p.Updated, p.Failed = 0, 0, 0, 0
+	p.Errors, p.ErrorsTruncated = []models.ImportRowError{}, false
+
+	rows, err := parse(p.Format, p.Data)
+	if err != nil {
+		return p.save(ctx, bson.M{"status": models.ImportFailed, "error": err.Error(), "finishedAt": time.Now()})
+	}
+	p.Rows = len(rows)
+
+	var slugs []string
+	for _, row := range rows {
+		slugs = append(slugs, row.Categories...)
+	}
+	categoryIDs, err := categoryService.IDsBySlug(ctx, slugs)
+	if err != nil {
+		return err
+	}
+
+	// A SKU given twice fails on its later rows instead of racing with itself
+	// within a batch
+	seen := map[string]int{}
+	var batch []parsedRow
+	for _, row := range rows {
+		if len(row.Errors) == 0 {
+			for _, slug := range row.Categories {
+				if id, ok := categoryIDs[slug]; ok {
+					row.Product.CategoryIDs = append(row.Product.CategoryIDs, id)
+				} else {
+					row.fail("categories", "no category has the slug "+slug)
+				}
+			}
+			if row.Product.SKU == "" {
+				row.fail("sku", "is required")
+			} else if first, ok := seen[row.Product.SKU]; ok {
+				row.fail("sku", fmt.Sprintf("is also on row %d", first))
+			} else {
+				seen[row.Product.SKU] = row.Row
+			}
+			for _, e := range Validate(row.Product) {
+				row.fail(e.Field, e.Message)
+			}
+		}
+		if len(row.Errors) > 0 {
+			p.fail(row.Errors...)
+			continue
+		}
+
+		batch = append(batch, row)
+		if len(batch) == importBatchSize {
+			if err := p.write(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := p.write(ctx, batch); err != nil {
+		return err
+	}
+
+	return p.save(ctx, bson.M{"status": models.ImportCompleted, "finishedAt": time.Now()})
+}
+
+// write upserts a batch of valid rows by SKU and saves the progress.
+func (p *progress) write(ctx context.Context, batch []parsedRow) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	restocking, err := outOfStock(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(batch))
+	for _, row := range batch {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"sku": row.Product.SKU}).
+			SetUpdate(upsertUpdate(row.Product)).
+			SetUpsert(true))
+	}
+
+	result, err := productCollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if err != nil && !errors.As(err, &bulkErr) {
+		return err
+	}
+	if result != nil {
+		p.Created += int(result.UpsertedCount)
+		p.Updated += int(result.MatchedCount)
+	}
+	failed := make(map[string]bool, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		row := batch[writeErr.Index]
+		failed[row.Product.SKU] = true
+		p.fail(models.ImportRowError{Row: row.Row, SKU: row.Product.SKU, Message: writeErr.Message})
+	}
+	for sku, productID := range restocking {
+		if !failed[sku] {
+			stockService.Restocked(productID)
+		}
+	}
+
+	return p.save(ctx, bson.M{"leaseUntil": time.Now().Add(ImportLease)})
+}
+
+// outOfStock returns the existing products, by SKU, that are out of stock
+// and that the batch brings back in stock.
+func outOfStock(ctx context.Context, batch []parsedRow) (map[string]primitive.ObjectID, error) {
+	skus := make([]string, 0, len(batch))
+	for _, row := range batch {
+		if row.Product.Quantity > 0 {
+			skus = append(skus, row.Product.SKU)
+		}
+	}
+	restocking := map[string]primitive.ObjectID{}
+	if len(skus) == 0 {
+		return restocking, nil
+	}
+
+	cursor, err := productCollection.Find(ctx,
+		bson.M{"sku": bson.M{"$in": skus}, "quantity": bson.M{"$lte": 0}},
+		options.Find().SetProjection(bson.M{"_id": 1, "sku": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		restocking[product.SKU] = product.ID
+	}
+	return restocking, nil
+}
+
+// upsertUpdate sets every catalogue field of the product. Optional fields
+// left empty in the file are removed, so the file describes the product
+// completely.
+func upsertUpdate(product models.Product) bson.M {
+	set := bson.M{
+		"sku":         product.SKU,
+		"name":        product.Name,
+		"brand":       product.Brand,
+		"description": product.Description,
+		"category":    product.Category,
+		"price":       product.Price,
+		"quantity":    product.Quantity,
+		"images":      product.Images,
+	}
+	unset := bson.M{}
+	optional := func(field string, value interface{}, empty bool) {
+		if empty {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+	optional("size", product.Size, product.Size == "")
+	optional("hsnCode", product.HSNCode, product.HSNCode == "")
+	optional("weightGrams", product.WeightGrams, product.WeightGrams == 0)
+	optional("prices", product.Prices, len(product.Prices) == 0)
+	optional("categoryIds", product.CategoryIDs, len(product.CategoryIDs) == 0)
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
@@ -0,0 +1,63 @@
+package productService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrProductNotFound        = errors.New("product not found")
+	ErrReleaseDatePassed      = errors.New("release date must be in the future")
+	ErrInvalidPreOrderPayment = errors.New("payment must be upfront or authorize")
+)
+
+// PreOrderActive reports whether the product is sold as a pre-order at now.
+// A pre-order whose release date has passed is an ordinary product again.
+func PreOrderActive(product models.Product, now time.Time) bool {
+	return product.PreOrder != nil && now.Before(product.PreOrder.ReleaseDate)
+}
+
+// SetPreOrder opens the product for pre-order until its release date. The
+// payment mode defaults to upfront.
+func SetPreOrder(ctx context.Context, productID primitive.ObjectID, preOrder models.PreOrder) (models.Product, error) {
+	var product models.Product
+	if preOrder.Payment == "" {
+		preOrder.Payment = models.PreOrderUpfront
+	}
+	if preOrder.Payment != models.PreOrderUpfront && preOrder.Payment != models.PreOrderAuthorize {
+		return product, ErrInvalidPreOrderPayment
+	}
+	if !preOrder.ReleaseDate.After(time.Now()) {
+		return product, ErrReleaseDatePassed
+	}
+
+	err := productCollection.FindOneAndUpdate(ctx, bson.M{"_id": productID},
+		bson.M{"$set": bson.M{"preOrder": preOrder}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return product, ErrProductNotFound
+	}
+	return product, err
+}
+
+// ClearPreOrder sells the product as an ordinary product again. Orders
+// already placed keep their release dates.
+func ClearPreOrder(ctx context.Context, productID primitive.ObjectID) (models.Product, error) {
+	var product models.Product
+	err := productCollection.FindOneAndUpdate(ctx, bson.M{"_id": productID},
+		bson.M{"$unset": bson.M{"preOrder": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return product, ErrProductNotFound
+	}
+	return product, err
+}
@@ -0,0 +1,87 @@
+package productService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	mediaService "fiber-mongo-api/services/media"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+var importCollection *mongo.Collection = configs.GetCollection(configs.DB, "productImports")
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// FieldError is a rule a product breaks.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// EnsureIndexes makes SKUs unique among the products that have one and
+// indexes imports by creation time. It is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := productCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sku", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"sku": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = importCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "leaseUntil", Value: 1}}},
+	})
+	return err
+}
+
+// Validate checks a product against the rules of models.Product and
+// returns every rule it breaks.
+func Validate(product models.Product) []FieldError {
+	var errs []FieldError
+	required := func(field, value string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, FieldError{field, "is required"})
+		}
+	}
+
+	required("name", product.Name)
+	required("brand", product.Brand)
+	required("description", product.Description)
+	required("category", product.Category)
+	if product.Quantity < 1 {
+		errs = append(errs, FieldError{"quantity", "must be at least 1"})
+	}
+	if !(product.Price > 0) {
+		errs = append(errs, FieldError{"price", "must be greater than 0"})
+	}
+	if product.WeightGrams < 0 {
+		errs = append(errs, FieldError{"weightGrams", "must not be negative"})
+	}
+
+	if len(product.Images) == 0 {
+		errs = append(errs, FieldError{"images", "at least one image is required"})
+	}
+	for _, image := range product.Images {
+		if !mediaService.ValidURL(image) {
+			errs = append(errs, FieldError{"images", "must be http or https URLs or paths on this server"})
+			break
+		}
+	}
+
+	for code, amount := range product.Prices {
+		if !currencyCode.MatchString(code) || amount <= 0 {
+			errs = append(errs, FieldError{"prices", "must map currency codes such as USD to positive amounts in minor units"})
+			break
+		}
+	}
+	return errs
+}
@@ -0,0 +1,334 @@
+package promotionService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	"fiber-mongo-api/services/pricing"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var promotionCollection *mongo.Collection = configs.GetCollection(configs.DB, "promotions")
+var redemptionCollection *mongo.Collection = configs.GetCollection(configs.DB, "promotionRedemptions")
+
+var (
+	ErrCouponNotFound      = errors.New("Coupon not found")
+	ErrCouponNotActive     = errors.New("Coupon is not active yet")
+	ErrCouponExpired       = errors.New("Coupon has expired")
+	ErrCouponUsedUp        = errors.New("Coupon usage limit reached")
+	ErrCouponUserLimit     = errors.New("You have already used this coupon the maximum number of times")
+	ErrCouponMinCartValue  = errors.New("Cart value is below the minimum for this coupon")
+	ErrCouponNotApplicable = errors.New("Coupon does not apply to any item in the cart")
+)
+
+// NormalizeCode upper-cases and trims a coupon code so lookups are case
+// insensitive.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// EnsureIndexes creates the unique coupon code index and the redemption
+// lookup index. It is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := promotionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"code": bson.M{"$exists": true},
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = redemptionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "promotionId", Value: 1}, {Key: "userId", Value: 1}},
+	})
+	return err
+}
+
+// eligibleSubtotal is the value of the cart lines the promotion is scoped
+// to, in minor units. A promotion without brand or category scope covers the
+// whole cart.
+func eligibleSubtotal(promotion models.Promotion, cart []models.CartItem) int64 {
+	var subtotal int64
+	for _, cartItem := range cart {
+		if len(promotion.Brands) > 0 && !containsFold(promotion.Brands, cartItem.Product.Brand) {
+			continue
+		}
+		if len(promotion.Categories) > 0 && !containsFold(promotion.Categories, cartItem.Product.Category) {
+			continue
+		}
+		subtotal += currencyService.LineTotal(cartItem)
+	}
+	return subtotal
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// discountFor computes the discount the promotion gives on the cart, or an
+// error saying why it does not apply. Usage limits are not checked here. The
+// cart is priced in rate's currency; promotion amounts are entered in the base
+// currency and converted with rate.
+func discountFor(promotion models.Promotion, cart []models.CartItem, rate currencyService.Rate, now time.Time) (models.Money, error) {
+	fromBase := func(amount float64) int64 {
+		return rate.FromBase(currencyService.BaseMoney(amount)).Amount
+	}
+
+	if !promotion.Active || (promotion.StartsAt != nil && now.Before(*promotion.StartsAt)) {
+		return models.Money{}, ErrCouponNotActive
+	}
+	if promotion.EndsAt != nil && !now.Before(*promotion.EndsAt) {
+		return models.Money{}, ErrCouponExpired
+	}
+
+	subtotal := eligibleSubtotal(promotion, cart)
+	if subtotal == 0 {
+		return models.Money{}, ErrCouponNotApplicable
+	}
+	if subtotal < fromBase(promotion.MinCartValue) {
+		return models.Money{}, ErrCouponMinCartValue
+	}
+
+	var discount, maxDiscount int64
+	switch promotion.Type {
+	case models.PromotionPercentage:
+		discount = pricing.Percent(subtotal, promotion.Value)
+		maxDiscount = fromBase(promotion.MaxDiscount)
+	case models.PromotionFlat:
+		discount = fromBase(promotion.Value)
+	}
+
+	// A discount never exceeds the value of the items it covers
+	return rate.Money(pricing.ClampDiscount(discount, maxDiscount, subtotal)), nil
+}
+
+// checkUsage enforces the global and per-user usage limits. Per-user limits
+// can only be checked for signed-in shoppers; guests are checked again at
+// checkout once they have signed in.
+func checkUsage(ctx context.Context, promotion models.Promotion, userID *primitive.ObjectID) error {
+	if promotion.UsageLimit > 0 && promotion.UsedCount >= promotion.UsageLimit {
+		return ErrCouponUsedUp
+	}
+	if promotion.PerUserLimit > 0 && userID != nil {
+		used, err := redemptionCollection.CountDocuments(ctx, bson.M{
+			"promotionId": promotion.ID,
+			"userId":      *userID,
+		})
+		if err != nil {
+			return err
+		}
+		if int(used) >= promotion.PerUserLimit {
+			return ErrCouponUserLimit
+		}
+	}
+	return nil
+}
+
+// FindCoupon loads the promotion for a coupon code.
+func FindCoupon(ctx context.Context, code string) (models.Promotion, error) {
+	var promotion models.Promotion
+	err := promotionCollection.FindOne(ctx, bson.M{"code": NormalizeCode(code)}).Decode(&promotion)
+	if err == mongo.ErrNoDocuments {
+		return promotion, ErrCouponNotFound
+	}
+	return promotion, err
+}
+
+// ValidateCoupon checks that the coupon exists, is within its validity
+// window and usage limits, and gives a discount on this cart, which must be
+// priced with rate.
+func ValidateCoupon(ctx context.Context, code string, cart []models.CartItem, userID *primitive.ObjectID, rate currencyService.Rate) (models.AppliedDiscount, error) {
+	promotion, err := FindCoupon(ctx, code)
+	if err != nil {
+		return models.AppliedDiscount{}, err
+	}
+
+	amount, err := discountFor(promotion, cart, rate, time.Now())
+	if err != nil {
+		return models.AppliedDiscount{}, err
+	}
+	if err := checkUsage(ctx, promotion, userID); err != nil {
+		return models.AppliedDiscount{}, err
+	}
+
+	return models.AppliedDiscount{
+		PromotionID: promotion.ID,
+		Code:        promotion.Code,
+		Name:        promotion.Name,
+		Amount:      amount,
+	}, nil
+}
+
+// Evaluation is the outcome of running the promotions over a cart.
+type Evaluation struct {
+	Discounts     []models.AppliedDiscount
+	DiscountTotal models.Money
+	// CouponError explains why the applied coupon no longer gives a
+	// discount, for example because it expired after it was applied.
+	CouponError string
+}
+
+// Evaluate applies every eligible automatic promotion and the coupon, if
+// any, to the cart priced with rate. Automatic promotions stack with the
+// coupon, but the total discount never exceeds the cart subtotal.
+func Evaluate(ctx context.Context, cart []models.CartItem, couponCode string, userID *primitive.ObjectID, rate currencyService.Rate) (Evaluation, error) {
+	evaluation := Evaluation{DiscountTotal: rate.Money(0)}
+	if len(cart) == 0 {
+		return evaluation, nil
+	}
+
+	now := time.Now()
+	cursor, err := promotionCollection.Find(ctx, bson.M{"automatic": true, "active": true})
+	if err != nil {
+		return evaluation, err
+	}
+	var automatic []models.Promotion
+	if err := cursor.All(ctx, &automatic); err != nil {
+		return evaluation, err
+	}
+
+	for _, promotion := range automatic {
+		amount, err := discountFor(promotion, cart, rate, now)
+		if err != nil {
+			continue
+		}
+		if err := checkUsage(ctx, promotion, userID); err != nil {
+			continue
+		}
+		evaluation.Discounts = append(evaluation.Discounts, models.AppliedDiscount{
+			PromotionID: promotion.ID,
+			Name:        promotion.Name,
+			Amount:      amount,
+		})
+	}
+
+	if couponCode != "" {
+		discount, err := ValidateCoupon(ctx, couponCode, cart, userID, rate)
+		if err != nil {
+			evaluation.CouponError = err.Error()
+		} else {
+			evaluation.Discounts = append(evaluation.Discounts, discount)
+		}
+	}
+
+	var subtotal int64
+	for _, cartItem := range cart {
+		subtotal += currencyService.LineTotal(cartItem)
+	}
+	for i := range evaluation.Discounts {
+		remaining := subtotal - evaluation.DiscountTotal.Amount
+		if evaluation.Discounts[i].Amount.Amount > remaining {
+			evaluation.Discounts[i].Amount.Amount = remaining
+		}
+		evaluation.DiscountTotal.Amount += evaluation.Discounts[i].Amount.Amount
+	}
+
+	return evaluation, nil
+}
+
+// Redeem records the discounts used by an order. The global usage counter
+// is only incremented while it is below the limit, so two concurrent orders
+// cannot both take the last use; ErrCouponUsedUp is returned in that case.
+// The per-user limit is checked after the redemption is recorded, counting
+// it, so of two concurrent orders by the same shopper at most one gets the
+// last use; ErrCouponUserLimit is returned for the other. On either error
+// nothing is recorded for any of the discounts.
+func Redeem(ctx context.Context, discounts []models.AppliedDiscount, userID, orderID primitive.ObjectID) error {
+	now := time.Now()
+	for i, discount := range discounts {
+		filter := bson.M{
+			"_id": discount.PromotionID,
+			"$or": bson.A{
+				bson.M{"usageLimit": bson.M{"$exists": false}},
+				bson.M{"usageLimit": 0},
+				bson.M{"$expr": bson.M{"$lt": bson.A{"$usedCount", "$usageLimit"}}},
+			},
+		}
+		// In a transaction the increment also makes concurrent redemptions of
+		// the promotion conflict, so they are retried one after the other
+		var promotion models.Promotion
+		err := promotionCollection.FindOneAndUpdate(ctx, filter, bson.M{
+			"$inc": bson.M{"usedCount": 1},
+			"$set": bson.M{"updatedAt": now},
+		}, options.FindOneAndUpdate().SetProjection(bson.M{"perUserLimit": 1})).Decode(&promotion)
+		if err == mongo.ErrNoDocuments {
+			Release(ctx, discounts[:i], orderID)
+			return ErrCouponUsedUp
+		} else if err != nil {
+			Release(ctx, discounts[:i], orderID)
+			return err
+		}
+
+		_, err = redemptionCollection.InsertOne(ctx, models.PromotionRedemption{
+			ID:          primitive.NewObjectID(),
+			PromotionID: discount.PromotionID,
+			UserID:      userID,
+			OrderID:     orderID,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			decrementUsage(ctx, discount.PromotionID)
+			Release(ctx, discounts[:i], orderID)
+			return err
+		}
+
+		if promotion.PerUserLimit > 0 {
+			used, err := redemptionCollection.CountDocuments(ctx, bson.M{
+				"promotionId": discount.PromotionID,
+				"userId":      userID,
+			})
+			if err == nil && int(used) > promotion.PerUserLimit {
+				err = ErrCouponUserLimit
+			}
+			if err != nil {
+				Release(ctx, discounts[:i+1], orderID)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Release undoes Redeem for an order that was never paid, giving the uses
+// back to the promotions.
+func Release(ctx context.Context, discounts []models.AppliedDiscount, orderID primitive.ObjectID) error {
+	for _, discount := range discounts {
+		result, err := redemptionCollection.DeleteOne(ctx, bson.M{
+			"promotionId": discount.PromotionID,
+			"orderId":     orderID,
+		})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			continue
+		}
+		if err := decrementUsage(ctx, discount.PromotionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decrementUsage(ctx context.Context, promotionID primitive.ObjectID) error {
+	_, err := promotionCollection.UpdateOne(ctx,
+		bson.M{"_id": promotionID, "usedCount": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"usedCount": -1}},
+	)
+	return err
+}
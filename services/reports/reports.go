@@ -0,0 +1,222 @@
+package reportService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	currencyService "fiber-mongo-api/services/currency"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidInterval  = errors.New("interval must be day, week or month")
+	ErrInvalidDimension = errors.New("by must be product, brand or size")
+)
+
+// RevenuePoint is the sales of one day, week or month. Amounts are in the
+// base currency; net revenue is revenue less the refunds on those orders.
+type RevenuePoint struct {
+	Period            time.Time `json:"period" bson:"_id"`
+	Orders            int64     `json:"orders" bson:"orders"`
+	PaidOrders        int64     `json:"paidOrders" bson:"paidOrders"`
+	Units             int64     `json:"units" bson:"units"`
+	Revenue           float64   `json:"revenue" bson:"-"`
+	Refunds           float64   `json:"refunds" bson:"-"`
+	NetRevenue        float64   `json:"netRevenue" bson:"-"`
+	AverageOrderValue float64   `json:"averageOrderValue" bson:"-"`
+	RevenueMinor      int64     `json:"-" bson:"revenue"`
+	RefundsMinor      int64     `json:"-" bson:"refunds"`
+}
+
+// UnitsLine is what a product, brand or size sold over a date range.
+type UnitsLine struct {
+	Key          string  `json:"key" bson:"_id"`
+	Name         string  `json:"name,omitempty" bson:"name"`
+	Units        int64   `json:"units" bson:"units"`
+	Revenue      float64 `json:"revenue" bson:"-"`
+	RevenueMinor int64   `json:"-" bson:"revenue"`
+}
+
+// Summary is the headline figures for a date range. Rates are fractions
+// between 0 and 1.
+type Summary struct {
+	Currency           string  `json:"currency"`
+	Orders             int64   `json:"orders"`
+	PaidOrders         int64   `json:"paidOrders"`
+	Units              int64   `json:"units"`
+	Revenue            float64 `json:"revenue"`
+	Refunds            float64 `json:"refunds"`
+	NetRevenue         float64 `json:"netRevenue"`
+	Discounts          float64 `json:"discounts"`
+	Tax                float64 `json:"tax"`
+	Shipping           float64 `json:"shipping"`
+	AverageOrderValue  float64 `json:"averageOrderValue"`
+	PaymentSuccessRate float64 `json:"paymentSuccessRate"`
+	CancellationRate   float64 `json:"cancellationRate"`
+	Returns            int64   `json:"returns"`
+	ReturnRate         float64 `json:"returnRate"`
+}
+
+// inRange matches the rollups of the days from from to to, inclusive.
+func inRange(from, to time.Time) bson.D {
+	return bson.D{{Key: "$match", Value: bson.M{"_id": bson.M{
+		"$gte": StartOfDay(from).Format(DateLayout),
+		"$lte": StartOfDay(to).Format(DateLayout),
+	}}}}
+}
+
+func fromMinor(amount int64) float64 {
+	return currencyService.FromMinor(amount, configs.EnvBaseCurrency())
+}
+
+func ratio(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*10000) / 10000
+}
+
+// Revenue sums the rollups per day, week (starting Monday) or month.
+func Revenue(ctx context.Context, from, to time.Time, interval string) ([]RevenuePoint, error) {
+	if interval != "day" && interval != "week" && interval != "month" {
+		return nil, ErrInvalidInterval
+	}
+
+	cursor, err := salesDailyCollection.Aggregate(ctx, mongo.Pipeline{
+		inRange(from, to),
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$dateTrunc": bson.M{
+				"date":        "$day",
+				"unit":        interval,
+				"timezone":    "+05:30",
+				"startOfWeek": "monday",
+			}},
+			"orders":     bson.M{"$sum": "$orders"},
+			"paidOrders": bson.M{"$sum": "$paidOrders"},
+			"units":      bson.M{"$sum": "$units"},
+			"revenue":    bson.M{"$sum": "$revenue"},
+			"refunds":    bson.M{"$sum": "$refunds"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	points := []RevenuePoint{}
+	if err := cursor.All(ctx, &points); err != nil {
+		return nil, err
+	}
+
+	for i := range points {
+		points[i].Period = points[i].Period.In(IST)
+		points[i].Revenue = fromMinor(points[i].RevenueMinor)
+		points[i].Refunds = fromMinor(points[i].RefundsMinor)
+		points[i].NetRevenue = fromMinor(points[i].RevenueMinor - points[i].RefundsMinor)
+		if points[i].PaidOrders > 0 {
+			points[i].AverageOrderValue = fromMinor(points[i].RevenueMinor / points[i].PaidOrders)
+		}
+	}
+	return points, nil
+}
+
+// Units sums the units sold and revenue per product, brand or size, best
+// sellers first.
+func Units(ctx context.Context, from, to time.Time, by string) ([]UnitsLine, error) {
+	field := map[string]string{"product": "$products", "brand": "$brands", "size": "$sizes"}[by]
+	if field == "" {
+		return nil, ErrInvalidDimension
+	}
+
+	cursor, err := salesDailyCollection.Aggregate(ctx, mongo.Pipeline{
+		inRange(from, to),
+		{{Key: "$unwind", Value: field}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     field + ".key",
+			"name":    bson.M{"$last": field + ".name"},
+			"units":   bson.M{"$sum": field + ".units"},
+			"revenue": bson.M{"$sum": field + ".revenue"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "units", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	lines := []UnitsLine{}
+	if err := cursor.All(ctx, &lines); err != nil {
+		return nil, err
+	}
+
+	for i := range lines {
+		lines[i].Revenue = fromMinor(lines[i].RevenueMinor)
+	}
+	return lines, nil
+}
+
+// GetSummary adds up the rollups of a date range. The payment success rate
+// is paid orders over orders whose payment finished one way or the other;
+// the return rate is returned units over units sold.
+func GetSummary(ctx context.Context, from, to time.Time) (Summary, error) {
+	summary := Summary{Currency: configs.EnvBaseCurrency()}
+
+	cursor, err := salesDailyCollection.Aggregate(ctx, mongo.Pipeline{
+		inRange(from, to),
+		{{Key: "$group", Value: bson.M{
+			"_id":            nil,
+			"orders":         bson.M{"$sum": "$orders"},
+			"paidOrders":     bson.M{"$sum": "$paidOrders"},
+			"failedPayments": bson.M{"$sum": "$failedPayments"},
+			"cancelled":      bson.M{"$sum": "$cancelled"},
+			"units":          bson.M{"$sum": "$units"},
+			"revenue":        bson.M{"$sum": "$revenue"},
+			"refunds":        bson.M{"$sum": "$refunds"},
+			"discounts":      bson.M{"$sum": "$discounts"},
+			"tax":            bson.M{"$sum": "$tax"},
+			"shipping":       bson.M{"$sum": "$shipping"},
+			"returns":        bson.M{"$sum": "$returns"},
+			"returnedUnits":  bson.M{"$sum": "$returnedUnits"},
+		}}},
+	})
+	if err != nil {
+		return summary, err
+	}
+	var totals []struct {
+		Orders         int64 `bson:"orders"`
+		PaidOrders     int64 `bson:"paidOrders"`
+		FailedPayments int64 `bson:"failedPayments"`
+		Cancelled      int64 `bson:"cancelled"`
+		Units          int64 `bson:"units"`
+		Revenue        int64 `bson:"revenue"`
+		Refunds        int64 `bson:"refunds"`
+		Discounts      int64 `bson:"discounts"`
+		Tax            int64 `bson:"tax"`
+		Shipping       int64 `bson:"shipping"`
+		Returns        int64 `bson:"returns"`
+		ReturnedUnits  int64 `bson:"returnedUnits"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil || len(totals) == 0 {
+		return summary, err
+	}
+	t := totals[0]
+
+	summary.Orders = t.Orders
+	summary.PaidOrders = t.PaidOrders
+	summary.Units = t.Units
+	summary.Revenue = fromMinor(t.Revenue)
+	summary.Refunds = fromMinor(t.Refunds)
+	summary.NetRevenue = fromMinor(t.Revenue - t.Refunds)
+	summary.Discounts = fromMinor(t.Discounts)
+	summary.Tax = fromMinor(t.Tax)
+	summary.Shipping = fromMinor(t.Shipping)
+	if t.PaidOrders > 0 {
+		summary.AverageOrderValue = fromMinor(t.Revenue / t.PaidOrders)
+	}
+	summary.PaymentSuccessRate = ratio(t.PaidOrders, t.PaidOrders+t.FailedPayments)
+	summary.CancellationRate = ratio(t.Cancelled, t.Orders)
+	summary.Returns = t.Returns
+	summary.ReturnRate = ratio(t.ReturnedUnits, t.Units)
+	return summary, nil
+}
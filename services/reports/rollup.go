@@ -0,0 +1,283 @@
+package reportService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var salesDailyCollection *mongo.Collection = configs.GetCollection(configs.DB, "salesDaily")
+var orderCollection *mongo.Collection = configs.GetCollection(configs.DB, "orders")
+var returnCollection *mongo.Collection = configs.GetCollection(configs.DB, "returns")
+
+// DateLayout is how days are written in rollups and report parameters.
+const DateLayout = "2006-01-02"
+
+// IST is Indian Standard Time, which report days follow.
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// EnsureIndexes indexes orders and returns by creation time for the rollups.
+// It is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	if _, err := orderCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "createdAt", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	_, err := returnCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "createdAt", Value: 1}},
+	})
+	return err
+}
+
+// StartOfDay returns midnight IST of the day t falls on.
+func StartOfDay(t time.Time) time.Time {
+	t = t.In(IST)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, IST)
+}
+
+// paid matches orders whose money was received.
+var paid = bson.M{"$eq": bson.A{"$paymentStatus", "completed"}}
+
+func countIf(condition interface{}) bson.M {
+	return bson.M{"$sum": bson.M{"$cond": bson.A{condition, 1, 0}}}
+}
+
+// minor rounds a summed amount to whole minor units.
+func minor(field string) bson.M {
+	return bson.M{"$toLong": bson.M{"$round": bson.A{field, 0}}}
+}
+
+// baseAmount is an order amount in base currency minor units. Orders placed
+// before base amounts were recorded were all in the base currency, so their
+// order-level field is converted instead; one that is missing sums as zero.
+func baseAmount(baseField, legacyField string, unit float64) bson.M {
+	return bson.M{"$ifNull": bson.A{"$base." + baseField, bson.M{"$multiply": bson.A{"$" + legacyField, unit}}}}
+}
+
+// refunded is what was paid back on an order, in base currency minor units.
+// Refunds are in the order's currency, so they are converted at the ratio of
+// the order's base total to its charged total. Orders without base amounts
+// were in the base currency, and the oldest kept the refunded total as a
+// plain number.
+func refunded() bson.M {
+	amount := bson.M{"$cond": bson.A{
+		bson.M{"$isNumber": "$refundedTotal"},
+		bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{"$refundedTotal", 100}}, 0}},
+		bson.M{"$ifNull": bson.A{"$refundedTotal.amount", 0}},
+	}}
+	return bson.M{"$cond": bson.A{
+		bson.M{"$and": bson.A{
+			bson.M{"$gt": bson.A{"$base.total", nil}},
+			bson.M{"$gt": bson.A{"$totalAmount.amount", 0}},
+		}},
+		bson.M{"$divide": bson.A{bson.M{"$multiply": bson.A{amount, "$base.total"}}, "$totalAmount.amount"}},
+		amount,
+	}}
+}
+
+// itemLines groups the paid order lines by key into SalesLines. Lines record
+// their base total; older lines only have plain amounts in the order's
+// currency, which are converted.
+func itemLines(key interface{}, name interface{}, unit float64) bson.A {
+	legacyValue := bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{"$items.tax", nil}},
+		bson.M{"$add": bson.A{"$items.tax.taxableValue", "$items.tax.amount"}},
+		bson.M{"$multiply": bson.A{"$items.product.price", "$items.quantity"}},
+	}}
+	rate := bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$base.exchangeRate", 0}}, "$base.exchangeRate", 1}}
+	legacyRevenue := bson.M{"$multiply": bson.A{bson.M{"$divide": bson.A{legacyValue, rate}}, unit}}
+
+	group := bson.M{
+		"_id":     key,
+		"units":   bson.M{"$sum": "$items.quantity"},
+		"revenue": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$items.baseTotal", legacyRevenue}}},
+	}
+	if name != nil {
+		group["name"] = bson.M{"$first": name}
+	}
+	return bson.A{
+		bson.M{"$match": bson.M{"paymentStatus": "completed"}},
+		bson.M{"$unwind": "$items"},
+		bson.M{"$group": group},
+		bson.M{"$project": bson.M{
+			"_id":     0,
+			"key":     bson.M{"$toString": "$_id"},
+			"name":    1,
+			"units":   1,
+			"revenue": minor("$revenue"),
+		}},
+		bson.M{"$sort": bson.M{"units": -1}},
+	}
+}
+
+// Rollup recomputes and stores the sales rollup of the day starting at day.
+func Rollup(ctx context.Context, day time.Time) (models.SalesDay, error) {
+	day = StartOfDay(day)
+	end := day.AddDate(0, 0, 1)
+	base := configs.EnvBaseCurrency()
+	unit := float64(currencyService.ToMinor(1, base))
+
+	cursor, err := orderCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"createdAt":     bson.M{"$gte": day, "$lt": end},
+			"paymentMethod": bson.M{"$ne": models.PaymentExchange},
+		}}},
+		{{Key: "$facet", Value: bson.M{
+			"totals": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":            nil,
+					"orders":         bson.M{"$sum": 1},
+					"paidOrders":     countIf(paid),
+					"failedPayments": countIf(bson.M{"$eq": bson.A{"$paymentStatus", "failed"}}),
+					"cancelled":      countIf(bson.M{"$eq": bson.A{"$status", "cancelled"}}),
+					"expired":        countIf(bson.M{"$eq": bson.A{"$status", "expired"}}),
+					"revenue":        bson.M{"$sum": bson.M{"$cond": bson.A{paid, baseAmount("total", "totalAmount", unit), 0}}},
+					"refunds":        bson.M{"$sum": bson.M{"$cond": bson.A{paid, refunded(), 0}}},
+					"discounts":      bson.M{"$sum": bson.M{"$cond": bson.A{paid, baseAmount("discountTotal", "discountTotal", unit), 0}}},
+					"tax":            bson.M{"$sum": bson.M{"$cond": bson.A{paid, baseAmount("tax", "tax.total", unit), 0}}},
+					"shipping":       bson.M{"$sum": bson.M{"$cond": bson.A{paid, baseAmount("shipping", "shipping.charge", unit), 0}}},
+					"units":          bson.M{"$sum": bson.M{"$cond": bson.A{paid, bson.M{"$sum": "$items.quantity"}, 0}}},
+				}},
+				bson.M{"$project": bson.M{
+					"_id":            0,
+					"orders":         1,
+					"paidOrders":     1,
+					"failedPayments": 1,
+					"cancelled":      1,
+					"expired":        1,
+					"units":          1,
+					"revenue":        minor("$revenue"),
+					"refunds":        minor("$refunds"),
+					"discounts":      minor("$discounts"),
+					"tax":            minor("$tax"),
+					"shipping":       minor("$shipping"),
+				}},
+			},
+			"products": itemLines("$items.productId", "$items.product.name", unit),
+			"brands":   itemLines("$items.product.brand", nil, unit),
+			"sizes":    itemLines(bson.M{"$ifNull": bson.A{"$items.size", ""}}, nil, unit),
+		}}},
+	})
+	if err != nil {
+		return models.SalesDay{}, err
+	}
+	var facets []struct {
+		Totals   []models.SalesDay  `bson:"totals"`
+		Products []models.SalesLine `bson:"products"`
+		Brands   []models.SalesLine `bson:"brands"`
+		Sizes    []models.SalesLine `bson:"sizes"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil {
+		return models.SalesDay{}, err
+	}
+
+	var rollup models.SalesDay
+	if len(facets) > 0 {
+		if len(facets[0].Totals) > 0 {
+			rollup = facets[0].Totals[0]
+		}
+		rollup.Products, rollup.Brands, rollup.Sizes = facets[0].Products, facets[0].Brands, facets[0].Sizes
+	}
+
+	cursor, err = returnCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"createdAt": bson.M{"$gte": day, "$lt": end},
+			"status":    bson.M{"$nin": bson.A{models.ReturnRejected, models.ReturnCancelled}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           nil,
+			"returns":       bson.M{"$sum": 1},
+			"returnedUnits": bson.M{"$sum": bson.M{"$sum": "$items.quantity"}},
+		}}},
+	})
+	if err != nil {
+		return rollup, err
+	}
+	var returns []models.SalesDay
+	if err := cursor.All(ctx, &returns); err != nil {
+		return rollup, err
+	}
+	if len(returns) > 0 {
+		rollup.Returns, rollup.ReturnedUnits = returns[0].Returns, returns[0].ReturnedUnits
+	}
+
+	rollup.Date = day.Format(DateLayout)
+	rollup.Day = day
+	rollup.Currency = base
+	rollup.ComputedAt = time.Now()
+	if rollup.Products == nil {
+		rollup.Products = []models.SalesLine{}
+	}
+	if rollup.Brands == nil {
+		rollup.Brands = []models.SalesLine{}
+	}
+	if rollup.Sizes == nil {
+		rollup.Sizes = []models.SalesLine{}
+	}
+
+	_, err = salesDailyCollection.ReplaceOne(ctx, bson.M{"_id": rollup.Date}, rollup, options.Replace().SetUpsert(true))
+	return rollup, err
+}
+
+// RollupRange recomputes the rollups of every day from from to to,
+// inclusive, and returns how many it stored.
+func RollupRange(ctx context.Context, from, to time.Time) (int, error) {
+	count := 0
+	for day := StartOfDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if _, err := Rollup(ctx, day); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Invalidate marks the rollup of the day t falls on stale, for an order
+// placed that day whose payment or refunds changed. Orders paid on delivery
+// or dispatch, and refunds, often come after the days RollupJob rebuilds.
+func Invalidate(ctx context.Context, t time.Time) error {
+	_, err := salesDailyCollection.UpdateOne(ctx,
+		bson.M{"_id": StartOfDay(t).Format(DateLayout)},
+		bson.M{"$set": bson.M{"stale": true}},
+	)
+	return err
+}
+
+// RollupJob returns the background job that keeps the rollups of recent days
+// up to date. Older days are rebuilt when they were invalidated.
+func RollupJob() func(ctx context.Context) (map[string]int, error) {
+	return func(ctx context.Context) (map[string]int, error) {
+		now := time.Now()
+		from := StartOfDay(now.AddDate(0, 0, 1-configs.EnvReportRollupDays()))
+		results := map[string]int{}
+		days, err := RollupRange(ctx, from, now)
+		results["days"] = days
+		if err != nil {
+			return results, err
+		}
+
+		cursor, err := salesDailyCollection.Find(ctx, bson.M{"stale": true, "day": bson.M{"$lt": from}},
+			options.Find().SetProjection(bson.M{"day": 1}))
+		if err != nil {
+			return results, err
+		}
+		var stale []models.SalesDay
+		if err := cursor.All(ctx, &stale); err != nil {
+			return results, err
+		}
+		for _, rollup := range stale {
+			if _, err := Rollup(ctx, rollup.Day); err != nil {
+				return results, err
+			}
+			results["staleDays"]++
+		}
+		return results, nil
+	}
+}
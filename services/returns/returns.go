@@ -0,0 +1,411 @@
+package returnService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	orderService "fiber-mongo-api/services/orders"
+	"fiber-mongo-api/services/pricing"
+	"fiber-mongo-api/services/sizes"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var returnCollection *mongo.Collection = configs.GetCollection(configs.DB, "returns")
+var orderCollection *mongo.Collection = configs.GetCollection(configs.DB, "orders")
+
+// MaxPhotos caps the photos attached to a return request.
+const MaxPhotos = 5
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrNotDelivered      = errors.New("only delivered orders can be returned")
+	ErrWindowClosed      = errors.New("the return window for this order has closed")
+	ErrInvalidType       = errors.New("type must be return or exchange")
+	ErrNoItems           = errors.New("at least one item is required")
+	ErrInvalidItem       = errors.New("item is not on the order or more than its remaining quantity is requested")
+	ErrExchangeSize      = errors.New("exchanges need a different size, one of " + sizes.List() + ", for every item")
+	ErrReasonRequired    = errors.New("a reason is required")
+	ErrTooManyPhotos     = errors.New("too many photos")
+	ErrInvalidPhoto      = errors.New("photos must be http or https URLs")
+	ErrReturnNotFound    = errors.New("return not found")
+	ErrInvalidTransition = errors.New("return is not in a status that allows this")
+	ErrInvalidResolution = errors.New("resolution must be refund, or exchange for exchange requests")
+)
+
+// Resolutions of a received return
+const (
+	ResolveRefund   = "refund"
+	ResolveExchange = "exchange"
+)
+
+// closed are the statuses of returns that no longer hold any items.
+var closed = bson.A{models.ReturnRejected, models.ReturnCancelled}
+
+// ItemRequest picks the order line being returned by product and size.
+type ItemRequest struct {
+	ProductID    primitive.ObjectID
+	Size         string
+	Quantity     int
+	ExchangeSize string
+}
+
+// RequestInput is a customer's return or exchange request.
+type RequestInput struct {
+	Type   string
+	Reason string
+	Photos []string
+	Items  []ItemRequest
+}
+
+// EnsureIndexes indexes returns by order, customer and status. It is safe to
+// call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := returnCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "orderId", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	return err
+}
+
+// ForOrder returns the order's returns, oldest first.
+func ForOrder(ctx context.Context, orderID primitive.ObjectID) ([]models.Return, error) {
+	returns := []models.Return{}
+	cursor, err := returnCollection.Find(ctx, bson.M{"orderId": orderID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+// deliveredAt is when the order was delivered. Orders delivered before the
+// time was recorded fall back to their last update.
+func deliveredAt(order models.Order) time.Time {
+	if order.DeliveredAt != nil {
+		return *order.DeliveredAt
+	}
+	return order.UpdatedAt
+}
+
+// refundFor is what the customer paid for quantity units of the item: their
+// share of the discounted price plus GST. Shipping and the platform fee are
+// not refunded.
+func refundFor(order models.Order, item models.OrderItem, quantity int) models.Money {
+	if item.Tax != nil && item.Quantity > 0 {
+		paid := item.Tax.TaxableValue.Amount + item.Tax.Amount.Amount
+		return models.Money{Amount: pricing.Share(paid, int64(quantity), int64(item.Quantity)), Currency: item.Tax.Amount.Currency}
+	}
+	// Orders from before GST was kept per line, which were all in the base
+	// currency
+	price := currencyService.BasePrice(item.Product)
+	price.Amount *= int64(quantity)
+	if order.Subtotal.Amount > 0 {
+		price.Amount -= pricing.Share(price.Amount, order.DiscountTotal.Amount, order.Subtotal.Amount)
+	}
+	return price
+}
+
+func itemKey(productID primitive.ObjectID, size string) string {
+	return productID.Hex() + "/" + size
+}
+
+// returnedQuantities adds up what open and completed returns of the order
+// already take back, per product and size.
+func returnedQuantities(ctx context.Context, orderID primitive.ObjectID) (map[string]int, error) {
+	var returns []models.Return
+	cursor, err := returnCollection.Find(ctx, bson.M{"orderId": orderID, "status": bson.M{"$nin": closed}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+
+	quantities := map[string]int{}
+	for _, ret := range returns {
+		for _, item := range ret.Items {
+			quantities[itemKey(item.ProductID, item.Size)] += item.Quantity
+		}
+	}
+	return quantities, nil
+}
+
+func validPhoto(photo string) bool {
+	u, err := url.Parse(photo)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Create records a customer's request to return or exchange items of one of
+// their delivered orders, within the return window.
+func Create(ctx context.Context, userID, orderID primitive.ObjectID, input RequestInput) (models.Return, error) {
+	var ret models.Return
+
+	if input.Type != models.ReturnTypeReturn && input.Type != models.ReturnTypeExchange {
+		return ret, ErrInvalidType
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		return ret, ErrReasonRequired
+	}
+	if len(input.Items) == 0 {
+		return ret, ErrNoItems
+	}
+	if len(input.Photos) > MaxPhotos {
+		return ret, ErrTooManyPhotos
+	}
+	for _, photo := range input.Photos {
+		if !validPhoto(photo) {
+			return ret, ErrInvalidPhoto
+		}
+	}
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID, "userId": userID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ret, ErrOrderNotFound
+		}
+		return ret, err
+	}
+	if order.Status != "delivered" {
+		return ret, ErrNotDelivered
+	}
+	if time.Since(deliveredAt(order)) > configs.EnvReturnWindow() {
+		return ret, ErrWindowClosed
+	}
+
+	returned, err := returnedQuantities(ctx, orderID)
+	if err != nil {
+		return ret, err
+	}
+
+	var items []models.ReturnItem
+	total := models.Money{Currency: order.TotalAmount.Currency}
+	for _, request := range input.Items {
+		var ordered *models.OrderItem
+		for i := range order.Items {
+			if order.Items[i].ProductID == request.ProductID && order.Items[i].Size == request.Size {
+				ordered = &order.Items[i]
+				break
+			}
+		}
+		key := itemKey(request.ProductID, request.Size)
+		if ordered == nil || request.Quantity < 1 || returned[key]+request.Quantity > ordered.Quantity {
+			return ret, ErrInvalidItem
+		}
+		returned[key] += request.Quantity
+
+		item := models.ReturnItem{
+			ProductID:    request.ProductID,
+			Name:         ordered.Product.Name,
+			Size:         request.Size,
+			Quantity:     request.Quantity,
+			RefundAmount: refundFor(order, *ordered, request.Quantity),
+		}
+		if input.Type == models.ReturnTypeExchange {
+			// The replacement ships in this size, so it must be one the
+			// shop sells and not the size being returned
+			size, err := sizes.Normalize(request.ExchangeSize)
+			if err != nil || size == "" || strings.EqualFold(size, request.Size) {
+				return ret, ErrExchangeSize
+			}
+			item.ExchangeSize = size
+		}
+		items = append(items, item)
+		total.Amount += item.RefundAmount.Amount
+	}
+
+	now := time.Now()
+	ret = models.Return{
+		ID:           primitive.NewObjectID(),
+		OrderID:      order.ID,
+		UserID:       userID,
+		Type:         input.Type,
+		Items:        items,
+		Reason:       input.Reason,
+		Photos:       input.Photos,
+		Status:       models.ReturnRequested,
+		RefundAmount: total,
+		Currency:     order.Currency,
+		History:      []models.ReturnEvent{{Status: models.ReturnRequested, At: now}},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	_, err = returnCollection.InsertOne(ctx, ret)
+	return ret, err
+}
+
+// transition moves the return matching filter from one of the from statuses
+// to status, applying set and recording the change in its history.
+func transition(ctx context.Context, filter bson.M, from []string, status string, set bson.M, note string) (models.Return, error) {
+	var ret models.Return
+	now := time.Now()
+
+	update := bson.M{"status": status, "updatedAt": now}
+	for key, value := range set {
+		update[key] = value
+	}
+	filter["status"] = bson.M{"$in": from}
+
+	err := returnCollection.FindOneAndUpdate(ctx, filter,
+		bson.M{
+			"$set":  update,
+			"$push": bson.M{"history": models.ReturnEvent{Status: status, Note: note, At: now}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&ret)
+	if err != mongo.ErrNoDocuments {
+		return ret, err
+	}
+
+	delete(filter, "status")
+	if err := returnCollection.FindOne(ctx, filter).Decode(&ret); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ret, ErrReturnNotFound
+		}
+		return ret, err
+	}
+	return ret, ErrInvalidTransition
+}
+
+// Cancel withdraws a customer's return before it is picked up.
+func Cancel(ctx context.Context, userID, id primitive.ObjectID) (models.Return, error) {
+	return transition(ctx, bson.M{"_id": id, "userId": userID},
+		[]string{models.ReturnRequested, models.ReturnApproved}, models.ReturnCancelled, nil, "Cancelled by customer")
+}
+
+// Approve accepts a return request.
+func Approve(ctx context.Context, id primitive.ObjectID, note string) (models.Return, error) {
+	return transition(ctx, bson.M{"_id": id},
+		[]string{models.ReturnRequested}, models.ReturnApproved, nil, note)
+}
+
+// Reject turns a return request down with a reason shown to the customer.
+func Reject(ctx context.Context, id primitive.ObjectID, reason string) (models.Return, error) {
+	return transition(ctx, bson.M{"_id": id},
+		[]string{models.ReturnRequested}, models.ReturnRejected, bson.M{"rejectionReason": reason}, reason)
+}
+
+// SchedulePickup sets or moves the pickup of an approved return.
+func SchedulePickup(ctx context.Context, id primitive.ObjectID, pickup models.ReturnPickup, note string) (models.Return, error) {
+	return transition(ctx, bson.M{"_id": id},
+		[]string{models.ReturnApproved, models.ReturnPickupScheduled}, models.ReturnPickupScheduled, bson.M{"pickup": pickup}, note)
+}
+
+func returnedItems(ret models.Return) []models.OrderItem {
+	items := make([]models.OrderItem, 0, len(ret.Items))
+	for _, item := range ret.Items {
+		items = append(items, models.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return items
+}
+
+// Receive records that the returned items arrived, puts them back in stock
+// and settles the return with resolution: a refund of what was paid for the
+// items, or a replacement order for the exchange sizes. An empty resolution
+// follows the request type. A return that was received but could not be
+// settled, for example because the exchange size is out of stock, can be
+// received again to retry or to refund instead.
+func Receive(ctx context.Context, provider orderService.PaymentProvider, id primitive.ObjectID, resolution, note string) (models.Return, error) {
+	var ret models.Return
+	err := configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		var err error
+		ret, err = transition(ctx, bson.M{"_id": id},
+			[]string{models.ReturnApproved, models.ReturnPickupScheduled}, models.ReturnReceived, nil, note)
+		if err != nil {
+			return err
+		}
+		return orderService.ReturnStock(ctx, returnedItems(ret))
+	})
+	if err == ErrInvalidTransition && ret.Status == models.ReturnReceived {
+		err = nil
+	}
+	if err != nil {
+		return ret, err
+	}
+
+	if resolution == "" {
+		resolution = ResolveRefund
+		if ret.Type == models.ReturnTypeExchange {
+			resolution = ResolveExchange
+		}
+	}
+
+	switch {
+	case resolution == ResolveRefund:
+		return refund(ctx, provider, ret)
+	case resolution == ResolveExchange && ret.Type == models.ReturnTypeExchange:
+		return exchange(ctx, ret)
+	}
+	return ret, ErrInvalidResolution
+}
+
+// refund pays back the returned items. orderService.Refund refunds a return
+// only once, so a retry after a failure here does not pay out twice.
+func refund(ctx context.Context, provider orderService.PaymentProvider, ret models.Return) (models.Return, error) {
+	refund, err := orderService.Refund(ctx, provider, ret.OrderID, ret.RefundAmount, "Return "+ret.ID.Hex(), &ret.ID)
+	if err != nil {
+		return ret, err
+	}
+	return transition(ctx, bson.M{"_id": ret.ID},
+		[]string{models.ReturnReceived}, models.ReturnRefunded, bson.M{"refundId": refund.ID}, "")
+}
+
+// exchange places the replacement order. Its ID is stored on the return
+// before the order is placed, so a retry places the same order.
+func exchange(ctx context.Context, ret models.Return) (models.Return, error) {
+	if ret.ReplacementOrderID == nil {
+		replacementID := primitive.NewObjectID()
+		err := returnCollection.FindOneAndUpdate(ctx,
+			bson.M{"_id": ret.ID, "replacementOrderId": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"replacementOrderId": replacementID}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&ret)
+		if err == mongo.ErrNoDocuments {
+			err = returnCollection.FindOne(ctx, bson.M{"_id": ret.ID}).Decode(&ret)
+		}
+		if err != nil {
+			return ret, err
+		}
+	}
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": ret.OrderID}).Decode(&order); err != nil {
+		return ret, err
+	}
+
+	var items []models.OrderItem
+	for _, item := range ret.Items {
+		for _, ordered := range order.Items {
+			if ordered.ProductID == item.ProductID && ordered.Size == item.Size {
+				product := ordered.Product
+				product.Size = item.ExchangeSize
+				items = append(items, models.OrderItem{
+					ProductID: item.ProductID,
+					Product:   product,
+					Quantity:  item.Quantity,
+					Size:      item.ExchangeSize,
+				})
+				break
+			}
+		}
+	}
+
+	if _, err := orderService.PlaceReplacement(ctx, *ret.ReplacementOrderID, order, items, ret.ID); err != nil {
+		return ret, err
+	}
+	return transition(ctx, bson.M{"_id": ret.ID},
+		[]string{models.ReturnReceived}, models.ReturnExchanged, nil, "Replacement order "+ret.ReplacementOrderID.Hex())
+}
@@ -0,0 +1,561 @@
+package reviewService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	mediaService "fiber-mongo-api/services/media"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var reviewCollection *mongo.Collection = configs.GetCollection(configs.DB, "reviews")
+var voteCollection *mongo.Collection = configs.GetCollection(configs.DB, "reviewVotes")
+var reportCollection *mongo.Collection = configs.GetCollection(configs.DB, "reviewReports")
+var orderCollection *mongo.Collection = configs.GetCollection(configs.DB, "orders")
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+var userCollection *mongo.Collection = configs.GetCollection(configs.DB, "users")
+
+// Limits on what a review may carry
+const (
+	MaxPhotos       = 5
+	maxTitleLength  = 150
+	maxTextLength   = 5000
+	maxReasonLength = 500
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrReviewNotFound  = errors.New("review not found")
+	ErrNotPurchased    = errors.New("only customers who received this product can review it")
+	ErrAlreadyReviewed = errors.New("you have already reviewed this product")
+	ErrInvalidRating   = errors.New("rating must be between 1 and 5 stars")
+	ErrTitleTooLong    = errors.New("title is too long")
+	ErrTextTooLong     = errors.New("review text is too long")
+	ErrTooManyPhotos   = errors.New("too many photos")
+	ErrInvalidPhoto    = errors.New("photos must be http or https URLs or uploaded images")
+	ErrNotVisible      = errors.New("review is not published")
+	ErrOwnReview       = errors.New("you cannot vote on or report your own review")
+	ErrReasonRequired  = errors.New("a reason is required")
+	ErrAlreadyReported = errors.New("you have already reported this review")
+	ErrInvalidSort     = errors.New("sort must be newest, oldest, helpful, rating_desc or rating_asc")
+	ErrInvalidStatus   = errors.New("status must be pending, approved or rejected")
+)
+
+// sorts are the orders reviews of a product can be listed in.
+var sorts = map[string]bson.D{
+	"newest":      {{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}},
+	"oldest":      {{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+	"helpful":     {{Key: "helpfulCount", Value: -1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}},
+	"rating_desc": {{Key: "rating", Value: -1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}},
+	"rating_asc":  {{Key: "rating", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}},
+}
+
+// Input is what a customer writes in a review.
+type Input struct {
+	Rating int
+	Title  string
+	Text   string
+	Photos []string
+}
+
+// EnsureIndexes allows one review per customer and product, one vote and
+// one report per shopper and review, and indexes the listings. It is safe
+// to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := reviewCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "productId", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "productId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "productId", Value: 1}, {Key: "status", Value: 1}, {Key: "helpfulCount", Value: -1}}},
+		{Keys: bson.D{{Key: "productId", Value: 1}, {Key: "status", Value: 1}, {Key: "rating", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "reportCount", Value: -1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = voteCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "reviewId", Value: 1}, {Key: "userId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = reportCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "reviewId", Value: 1}, {Key: "userId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// clean trims the input and checks it.
+func (in *Input) clean() error {
+	if in.Rating < 1 || in.Rating > 5 {
+		return ErrInvalidRating
+	}
+	in.Title = strings.TrimSpace(in.Title)
+	in.Text = strings.TrimSpace(in.Text)
+	if utf8.RuneCountInString(in.Title) > maxTitleLength {
+		return ErrTitleTooLong
+	}
+	if utf8.RuneCountInString(in.Text) > maxTextLength {
+		return ErrTextTooLong
+	}
+	if len(in.Photos) > MaxPhotos {
+		return ErrTooManyPhotos
+	}
+	for _, photo := range in.Photos {
+		if !mediaService.ValidURL(photo) {
+			return ErrInvalidPhoto
+		}
+	}
+	return nil
+}
+
+// applyRating adds (delta 1) or removes (delta -1) a review of stars stars
+// from the product's rating, recomputing the average in the same update.
+func applyRating(ctx context.Context, productID primitive.ObjectID, stars, delta int) error {
+	bucket := "rating.histogram." + strconv.Itoa(stars)
+	_, err := productCollection.UpdateOne(ctx, bson.M{"_id": productID}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"rating.count": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$rating.count", 0}}, delta}},
+			"rating.total": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$rating.total", 0}}, stars * delta}},
+			bucket:         bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$" + bucket, 0}}, delta}},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"rating.average": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$rating.count", 0}},
+				bson.M{"$round": bson.A{bson.M{"$divide": bson.A{"$rating.total", "$rating.count"}}, 2}},
+				0,
+			}},
+		}}},
+	})
+	return err
+}
+
+// Summary is the rating summary of a product with rating, with a bucket for
+// every number of stars.
+func Summary(rating *models.ProductRating) models.RatingSummary {
+	summary := models.RatingSummary{Histogram: make([]models.RatingBucket, 0, 5)}
+	if rating != nil {
+		summary.Average = rating.Average
+		summary.Count = rating.Count
+	}
+	for stars := 5; stars >= 1; stars-- {
+		bucket := models.RatingBucket{Stars: stars}
+		if rating != nil {
+			bucket.Count = rating.Histogram[strconv.Itoa(stars)]
+		}
+		summary.Histogram = append(summary.Histogram, bucket)
+	}
+	return summary
+}
+
+// Recount rebuilds the product's rating from its approved reviews, for
+// ratings that drifted on a server without transactions.
+func Recount(ctx context.Context, productID primitive.ObjectID) (models.ProductRating, error) {
+	rating := models.ProductRating{Histogram: map[string]int{}}
+	cursor, err := reviewCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"productId": productID, "status": models.ReviewApproved}}},
+		{{Key: "$group", Value: bson.M{"_id": "$rating", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return rating, err
+	}
+	var buckets []struct {
+		Stars int `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		return rating, err
+	}
+	for _, bucket := range buckets {
+		rating.Histogram[strconv.Itoa(bucket.Stars)] = bucket.Count
+		rating.Count += bucket.Count
+		rating.Total += bucket.Stars * bucket.Count
+	}
+	if rating.Count > 0 {
+		rating.Average = math.Round(float64(rating.Total)/float64(rating.Count)*100) / 100
+	}
+
+	result, err := productCollection.UpdateOne(ctx, bson.M{"_id": productID}, bson.M{"$set": bson.M{"rating": rating}})
+	if err != nil {
+		return rating, err
+	}
+	if result.MatchedCount == 0 {
+		return rating, ErrProductNotFound
+	}
+	return rating, nil
+}
+
+// Create records a customer's review of a product from one of their
+// delivered orders. It is shown once a moderator approves it.
+func Create(ctx context.Context, userID, productID primitive.ObjectID, input Input) (models.Review, error) {
+	var review models.Review
+	if err := input.clean(); err != nil {
+		return review, err
+	}
+
+	count, err := productCollection.CountDocuments(ctx, bson.M{"_id": productID})
+	if err != nil {
+		return review, err
+	}
+	if count == 0 {
+		return review, ErrProductNotFound
+	}
+
+	var order models.Order
+	err = orderCollection.FindOne(ctx,
+		bson.M{"userId": userID, "status": "delivered", "items.productId": productID},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return review, ErrNotPurchased
+	} else if err != nil {
+		return review, err
+	}
+
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return review, err
+	}
+
+	now := time.Now()
+	review = models.Review{
+		ID:         primitive.NewObjectID(),
+		ProductID:  productID,
+		UserID:     userID,
+		OrderID:    order.ID,
+		AuthorName: user.Name,
+		Rating:     input.Rating,
+		Title:      input.Title,
+		Text:       input.Text,
+		Photos:     input.Photos,
+		Status:     models.ReviewPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	for _, item := range order.Items {
+		if item.ProductID == productID {
+			review.Size = item.Size
+			break
+		}
+	}
+
+	if _, err := reviewCollection.InsertOne(ctx, review); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return review, ErrAlreadyReviewed
+		}
+		return review, err
+	}
+	return review, nil
+}
+
+// Update rewrites a customer's own review. The new text is moderated again,
+// so a shown review is hidden until it is approved.
+func Update(ctx context.Context, userID, id primitive.ObjectID, input Input) (models.Review, error) {
+	var review models.Review
+	if err := input.clean(); err != nil {
+		return review, err
+	}
+
+	err := configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		var before models.Review
+		err := reviewCollection.FindOneAndUpdate(ctx, bson.M{"_id": id, "userId": userID}, bson.M{
+			"$set": bson.M{
+				"rating":      input.Rating,
+				"title":       input.Title,
+				"text":        input.Text,
+				"photos":      input.Photos,
+				"status":      models.ReviewPending,
+				"reportCount": 0,
+				"updatedAt":   time.Now(),
+			},
+		}).Decode(&before)
+		if err == mongo.ErrNoDocuments {
+			return ErrReviewNotFound
+		} else if err != nil {
+			return err
+		}
+		if before.Status == models.ReviewApproved {
+			if err := applyRating(ctx, before.ProductID, before.Rating, -1); err != nil {
+				return err
+			}
+		}
+		return reviewCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&review)
+	})
+	return review, err
+}
+
+// Delete removes a customer's own review with its votes and reports.
+func Delete(ctx context.Context, userID, id primitive.ObjectID) error {
+	return configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		var review models.Review
+		err := reviewCollection.FindOneAndDelete(ctx, bson.M{"_id": id, "userId": userID}).Decode(&review)
+		if err == mongo.ErrNoDocuments {
+			return ErrReviewNotFound
+		} else if err != nil {
+			return err
+		}
+		if review.Status == models.ReviewApproved {
+			if err := applyRating(ctx, review.ProductID, review.Rating, -1); err != nil {
+				return err
+			}
+		}
+		if _, err := voteCollection.DeleteMany(ctx, bson.M{"reviewId": id}); err != nil {
+			return err
+		}
+		_, err = reportCollection.DeleteMany(ctx, bson.M{"reviewId": id})
+		return err
+	})
+}
+
+// Get returns a review.
+func Get(ctx context.Context, id primitive.ObjectID) (models.Review, error) {
+	var review models.Review
+	err := reviewCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&review)
+	if err == mongo.ErrNoDocuments {
+		return review, ErrReviewNotFound
+	}
+	return review, err
+}
+
+// list returns a page of the reviews matching filter and their total.
+func list(ctx context.Context, filter bson.M, sort bson.D, page, limit int64) ([]models.Review, int64, error) {
+	reviews := []models.Review{}
+	total, err := reviewCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	cursor, err := reviewCollection.Find(ctx, filter, options.Find().
+		SetSort(sort).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, 0, err
+	}
+	return reviews, total, nil
+}
+
+// ForProduct returns a page of the product's shown reviews in sort order,
+// only those giving stars stars if stars is set.
+func ForProduct(ctx context.Context, productID primitive.ObjectID, sort string, stars int, page, limit int64) ([]models.Review, int64, error) {
+	order, ok := sorts[sort]
+	if !ok {
+		return nil, 0, ErrInvalidSort
+	}
+	filter := bson.M{"productId": productID, "status": models.ReviewApproved}
+	if stars != 0 {
+		if stars < 1 || stars > 5 {
+			return nil, 0, ErrInvalidRating
+		}
+		filter["rating"] = stars
+	}
+	return list(ctx, filter, order, page, limit)
+}
+
+// ForUser returns a page of the customer's own reviews, newest first, in
+// any status.
+func ForUser(ctx context.Context, userID primitive.ObjectID, page, limit int64) ([]models.Review, int64, error) {
+	return list(ctx, bson.M{"userId": userID}, sorts["newest"], page, limit)
+}
+
+// Queue returns a page of the reviews in status for moderators: the most
+// reported first, then the longest waiting.
+func Queue(ctx context.Context, status string, page, limit int64) ([]models.Review, int64, error) {
+	switch status {
+	case models.ReviewPending, models.ReviewApproved, models.ReviewRejected:
+	default:
+		return nil, 0, ErrInvalidStatus
+	}
+	return list(ctx, bson.M{"status": status},
+		bson.D{{Key: "reportCount", Value: -1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}, page, limit)
+}
+
+// Reports returns the reports of a review, oldest first.
+func Reports(ctx context.Context, id primitive.ObjectID) ([]models.ReviewReport, error) {
+	reports := []models.ReviewReport{}
+	cursor, err := reportCollection.Find(ctx, bson.M{"reviewId": id},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// Moderate approves or rejects a review, keeping the product's rating in
+// step. Approving clears its reports so far.
+func Moderate(ctx context.Context, adminID, id primitive.ObjectID, approve bool, note string) (models.Review, error) {
+	var review models.Review
+	status := models.ReviewRejected
+	if approve {
+		status = models.ReviewApproved
+	}
+	now := time.Now()
+	set := bson.M{
+		"status":         status,
+		"moderationNote": strings.TrimSpace(note),
+		"moderatedBy":    adminID,
+		"moderatedAt":    now,
+		"updatedAt":      now,
+	}
+	if approve {
+		set["reportCount"] = 0
+	}
+
+	err := configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		var before models.Review
+		if err := reviewCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": set}).Decode(&before); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return ErrReviewNotFound
+			}
+			return err
+		}
+
+		delta := 0
+		if before.Status == models.ReviewApproved {
+			delta--
+		}
+		if approve {
+			delta++
+		}
+		if delta != 0 {
+			if err := applyRating(ctx, before.ProductID, before.Rating, delta); err != nil {
+				return err
+			}
+		}
+		return reviewCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&review)
+	})
+	return review, err
+}
+
+// shown returns a review other shoppers can vote on or report.
+func shown(ctx context.Context, userID, id primitive.ObjectID) (models.Review, error) {
+	review, err := Get(ctx, id)
+	if err != nil {
+		return review, err
+	}
+	if review.Status != models.ReviewApproved {
+		return review, ErrNotVisible
+	}
+	if review.UserID == userID {
+		return review, ErrOwnReview
+	}
+	return review, nil
+}
+
+// Vote marks a review helpful for the shopper, or with helpful false takes
+// the vote back. Voting twice counts once.
+func Vote(ctx context.Context, userID, id primitive.ObjectID, helpful bool) (models.Review, error) {
+	review, err := shown(ctx, userID, id)
+	if err != nil {
+		return review, err
+	}
+
+	err = configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		inc := 1
+		if helpful {
+			vote := models.ReviewVote{ReviewID: id, UserID: userID, CreatedAt: time.Now()}
+			if _, err := voteCollection.InsertOne(ctx, vote); err != nil {
+				return err
+			}
+		} else {
+			result, err := voteCollection.DeleteOne(ctx, bson.M{"reviewId": id, "userId": userID})
+			if err != nil || result.DeletedCount == 0 {
+				return err
+			}
+			inc = -1
+		}
+
+		err := reviewCollection.FindOneAndUpdate(ctx, bson.M{"_id": id},
+			bson.M{"$inc": bson.M{"helpfulCount": inc}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&review)
+		if err != nil && !atomic && helpful {
+			voteCollection.DeleteOne(context.Background(), bson.M{"reviewId": id, "userId": userID})
+		}
+		return err
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return Get(ctx, id)
+	}
+	return review, err
+}
+
+// Report flags a review for moderators. Once it has been reported
+// EnvReviewReportThreshold times it is hidden until a moderator approves it
+// again.
+func Report(ctx context.Context, userID, id primitive.ObjectID, reason string) (models.Review, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return models.Review{}, ErrReasonRequired
+	}
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		reason = string([]rune(reason)[:maxReasonLength])
+	}
+	review, err := shown(ctx, userID, id)
+	if err != nil {
+		return review, err
+	}
+
+	err = configs.WithTransaction(ctx, func(ctx context.Context, atomic bool) error {
+		report := models.ReviewReport{
+			ID:        primitive.NewObjectID(),
+			ReviewID:  id,
+			UserID:    userID,
+			Reason:    reason,
+			CreatedAt: time.Now(),
+		}
+		if _, err := reportCollection.InsertOne(ctx, report); err != nil {
+			return err
+		}
+		undo := func(err error) error {
+			if !atomic {
+				reportCollection.DeleteOne(context.Background(), bson.M{"_id": report.ID})
+			}
+			return err
+		}
+
+		err := reviewCollection.FindOneAndUpdate(ctx, bson.M{"_id": id},
+			bson.M{"$inc": bson.M{"reportCount": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&review)
+		if err != nil {
+			return undo(err)
+		}
+		if review.Status != models.ReviewApproved || review.ReportCount < configs.EnvReviewReportThreshold() {
+			return nil
+		}
+
+		// Hide it, unless a moderator or another report got there first
+		result, err := reviewCollection.UpdateOne(ctx, bson.M{"_id": id, "status": models.ReviewApproved},
+			bson.M{"$set": bson.M{"status": models.ReviewPending, "updatedAt": time.Now()}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return nil
+		}
+		review.Status = models.ReviewPending
+		return applyRating(ctx, review.ProductID, review.Rating, -1)
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return review, ErrAlreadyReported
+	}
+	return review, err
+}
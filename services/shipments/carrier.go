@@ -0,0 +1,39 @@
+package shipmentService
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnknownCarrier   = errors.New("unknown carrier")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Carrier is a shipping partner that reports tracking through a webhook.
+type Carrier interface {
+	// Name is the carrier's key in webhook URLs and on shipments.
+	Name() string
+	// ParseWebhook authenticates an inbound webhook from its headers and
+	// body, and returns the tracking updates it carries. It returns
+	// ErrInvalidSignature when the request did not come from the carrier.
+	ParseWebhook(header func(key string) string, body []byte) ([]TrackingUpdate, error)
+}
+
+// carriers holds the carriers whose webhooks are accepted. They are
+// registered at start-up.
+var carriers = map[string]Carrier{}
+
+// Register accepts webhooks from carrier.
+func Register(carrier Carrier) {
+	carriers[strings.ToLower(carrier.Name())] = carrier
+}
+
+// Lookup returns the registered carrier with the given name.
+func Lookup(name string) (Carrier, error) {
+	carrier, ok := carriers[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownCarrier
+	}
+	return carrier, nil
+}
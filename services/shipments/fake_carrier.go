@@ -0,0 +1,67 @@
+package shipmentService
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a fake carrier webhook
+// body, keyed with the shared secret.
+const FakeSignatureHeader = "X-Fake-Carrier-Signature"
+
+// FakeCarrier is a carrier for development and testing. Its webhook body is
+// {"updates": [{"awb", "status", "location", "description", "occurredAt"}]}
+// using the shipment statuses as they are.
+type FakeCarrier struct {
+	secret []byte
+}
+
+// NewFakeCarrier returns a fake carrier that signs webhooks with secret.
+func NewFakeCarrier(secret string) FakeCarrier {
+	return FakeCarrier{secret: []byte(secret)}
+}
+
+func (f FakeCarrier) Name() string {
+	return "fake"
+}
+
+// Sign returns the signature header value for body.
+func (f FakeCarrier) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f FakeCarrier) ParseWebhook(header func(key string) string, body []byte) ([]TrackingUpdate, error) {
+	if !hmac.Equal([]byte(header(FakeSignatureHeader)), []byte(f.Sign(body))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload struct {
+		Updates []struct {
+			AWB         string    `json:"awb"`
+			Status      string    `json:"status"`
+			Location    string    `json:"location"`
+			Description string    `json:"description"`
+			OccurredAt  time.Time `json:"occurredAt"`
+		} `json:"updates"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	updates := make([]TrackingUpdate, 0, len(payload.Updates))
+	for _, u := range payload.Updates {
+		updates = append(updates, TrackingUpdate{
+			AWB:         u.AWB,
+			Status:      u.Status,
+			Location:    u.Location,
+			Description: u.Description,
+			OccurredAt:  u.OccurredAt,
+		})
+	}
+	return updates, nil
+}
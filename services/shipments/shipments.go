@@ -0,0 +1,223 @@
+package shipmentService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	orderService "fiber-mongo-api/services/orders"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var shipmentCollection *mongo.Collection = configs.GetCollection(configs.DB, "shipments")
+var orderCollection *mongo.Collection = configs.GetCollection(configs.DB, "orders")
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrOrderNotShippable = errors.New("only processing or shipped orders can get shipments")
+	ErrDuplicateAWB      = errors.New("a shipment with this tracking number already exists for the carrier")
+	ErrShipmentNotFound  = errors.New("shipment not found")
+	ErrInvalidStatus     = errors.New("unknown shipment status")
+)
+
+// inTransit are the statuses of a parcel that has left the warehouse.
+var inTransit = map[string]bool{
+	models.ShipmentPickedUp:       true,
+	models.ShipmentInTransit:      true,
+	models.ShipmentOutForDelivery: true,
+	models.ShipmentDelivered:      true,
+	models.ShipmentFailedDelivery: true,
+	models.ShipmentReturned:       true,
+}
+
+// TrackingUpdate is a status change for the shipment with the given AWB.
+type TrackingUpdate struct {
+	AWB         string
+	Status      string
+	Location    string
+	Description string
+	OccurredAt  time.Time
+}
+
+// ValidStatus reports whether status is a known shipment status.
+func ValidStatus(status string) bool {
+	return status == models.ShipmentCreated || inTransit[status]
+}
+
+// EnsureIndexes makes AWBs unique per carrier and indexes shipments by
+// order. It is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := shipmentCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "carrier", Value: 1}, {Key: "awb", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "orderId", Value: 1}, {Key: "createdAt", Value: 1}}},
+	})
+	return err
+}
+
+// Create records a shipment for an order that has been paid for, or placed
+// cash on delivery, and not delivered yet.
+func Create(ctx context.Context, orderID primitive.ObjectID, carrier, awb, trackingURL string) (models.Shipment, error) {
+	var shipment models.Shipment
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return shipment, ErrOrderNotFound
+		}
+		return shipment, err
+	}
+	if order.Status != "processing" && order.Status != "shipped" {
+		return shipment, ErrOrderNotShippable
+	}
+
+	now := time.Now()
+	shipment = models.Shipment{
+		ID:          primitive.NewObjectID(),
+		OrderID:     order.ID,
+		UserID:      order.UserID,
+		Carrier:     strings.ToLower(strings.TrimSpace(carrier)),
+		AWB:         strings.TrimSpace(awb),
+		TrackingURL: trackingURL,
+		Status:      models.ShipmentCreated,
+		Events: []models.TrackingEvent{{
+			Status:      models.ShipmentCreated,
+			Description: "Shipment created",
+			OccurredAt:  now,
+		}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := shipmentCollection.InsertOne(ctx, shipment); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return shipment, ErrDuplicateAWB
+		}
+		return shipment, err
+	}
+	return shipment, nil
+}
+
+// ForOrder returns the order's shipments, oldest first.
+func ForOrder(ctx context.Context, orderID primitive.ObjectID) ([]models.Shipment, error) {
+	shipments := []models.Shipment{}
+	cursor, err := shipmentCollection.Find(ctx, bson.M{"orderId": orderID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &shipments); err != nil {
+		return nil, err
+	}
+	return shipments, nil
+}
+
+// Track adds a tracking event to the carrier's shipment with the update's
+// AWB and moves the order along: to shipped once a parcel is picked up, and
+// to delivered once all of its shipments are. Repeated updates are recorded
+// once, and updates that arrive out of order do not move the status back.
+func Track(ctx context.Context, carrier string, update TrackingUpdate) (models.Shipment, error) {
+	var shipment models.Shipment
+	if !ValidStatus(update.Status) {
+		return shipment, ErrInvalidStatus
+	}
+	if update.OccurredAt.IsZero() {
+		update.OccurredAt = time.Now()
+	}
+
+	event := models.TrackingEvent{
+		Status:      update.Status,
+		Location:    update.Location,
+		Description: update.Description,
+		OccurredAt:  update.OccurredAt.UTC().Truncate(time.Millisecond),
+	}
+	err := shipmentCollection.FindOneAndUpdate(ctx,
+		bson.M{"carrier": strings.ToLower(carrier), "awb": strings.TrimSpace(update.AWB)},
+		bson.M{"$addToSet": bson.M{"events": event}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&shipment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return shipment, ErrShipmentNotFound
+		}
+		return shipment, err
+	}
+
+	applyEvents(&shipment)
+	set := bson.M{"status": shipment.Status, "updatedAt": time.Now()}
+	if shipment.ShippedAt != nil {
+		set["shippedAt"] = shipment.ShippedAt
+	}
+	if shipment.DeliveredAt != nil {
+		set["deliveredAt"] = shipment.DeliveredAt
+	}
+	// A concurrent update that added another event computes the status from
+	// all of them, so this one only writes if it saw every event
+	_, err = shipmentCollection.UpdateOne(ctx,
+		bson.M{"_id": shipment.ID, "events": bson.M{"$size": len(shipment.Events)}},
+		bson.M{"$set": set})
+	if err != nil {
+		return shipment, err
+	}
+
+	return shipment, syncOrder(ctx, shipment)
+}
+
+// applyEvents derives the shipment's status and timestamps from its events.
+// The latest event decides the status.
+func applyEvents(shipment *models.Shipment) {
+	sort.SliceStable(shipment.Events, func(i, j int) bool {
+		return shipment.Events[i].OccurredAt.Before(shipment.Events[j].OccurredAt)
+	})
+
+	shipment.ShippedAt, shipment.DeliveredAt = nil, nil
+	for i, event := range shipment.Events {
+		if inTransit[event.Status] && shipment.ShippedAt == nil {
+			shipment.ShippedAt = &shipment.Events[i].OccurredAt
+		}
+		if event.Status == models.ShipmentDelivered {
+			shipment.DeliveredAt = &shipment.Events[i].OccurredAt
+		}
+		shipment.Status = event.Status
+	}
+}
+
+// syncOrder moves the shipment's order to shipped or delivered.
+func syncOrder(ctx context.Context, shipment models.Shipment) error {
+	if inTransit[shipment.Status] {
+		_, err := orderService.ChangeStatus(ctx, shipment.OrderID, "shipped", models.OrderStatusChange{
+			Note: "Shipment " + shipment.AWB + " with " + shipment.Carrier,
+			At:   *shipment.ShippedAt,
+		})
+		if err != nil && err != orderService.ErrInvalidStatusChange {
+			return err
+		}
+	}
+	if shipment.Status != models.ShipmentDelivered {
+		return nil
+	}
+
+	undelivered, err := shipmentCollection.CountDocuments(ctx, bson.M{
+		"orderId": shipment.OrderID,
+		"status":  bson.M{"$ne": models.ShipmentDelivered},
+	})
+	if err != nil || undelivered > 0 {
+		return err
+	}
+	_, err = orderService.ChangeStatus(ctx, shipment.OrderID, "delivered", models.OrderStatusChange{
+		Note: "All shipments delivered",
+		At:   *shipment.DeliveredAt,
+	})
+	if err == orderService.ErrInvalidStatusChange {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package shippingService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var zoneCollection *mongo.Collection = configs.GetCollection(configs.DB, "shippingZones")
+var pinCodeCollection *mongo.Collection = configs.GetCollection(configs.DB, "pinCodeZones")
+
+var (
+	ErrInvalidPinCode  = errors.New("Invalid PIN code")
+	ErrNotServiceable  = errors.New("Delivery is not available to this PIN code")
+	ErrZoneNotFound    = errors.New("Shipping zone not found")
+	pinCodeRegex       = regexp.MustCompile(`^[1-9][0-9]{5}$`)
+	pinCodePrefixRegex = regexp.MustCompile(`^[1-9][0-9]{2}$`)
+)
+
+// NormalizePinCode strips spaces from a PIN code, so "560 001" is accepted.
+func NormalizePinCode(pinCode string) string {
+	return strings.ReplaceAll(strings.TrimSpace(pinCode), " ", "")
+}
+
+// ValidPinCode reports whether pinCode is a six digit Indian PIN code.
+func ValidPinCode(pinCode string) bool {
+	return pinCodeRegex.MatchString(pinCode)
+}
+
+// ValidPinCodeEntry reports whether pinCode can key a zone table entry: a
+// full PIN code or a three digit prefix.
+func ValidPinCodeEntry(pinCode string) bool {
+	return pinCodeRegex.MatchString(pinCode) || pinCodePrefixRegex.MatchString(pinCode)
+}
+
+// EnsureIndexes makes zone codes and PIN code entries unique. It is safe to
+// call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := zoneCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = pinCodeCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "pinCode", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// FindZone resolves the zone serving a PIN code, preferring an exact entry
+// over the entry for its three digit prefix.
+func FindZone(ctx context.Context, pinCode string) (models.PinCodeZone, models.ShippingZone, error) {
+	var entry models.PinCodeZone
+	var zone models.ShippingZone
+
+	pinCode = NormalizePinCode(pinCode)
+	if !ValidPinCode(pinCode) {
+		return entry, zone, ErrInvalidPinCode
+	}
+
+	err := pinCodeCollection.FindOne(ctx, bson.M{"pinCode": pinCode}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		err = pinCodeCollection.FindOne(ctx, bson.M{"pinCode": pinCode[:3]}).Decode(&entry)
+	}
+	if err == mongo.ErrNoDocuments {
+		return entry, zone, ErrNotServiceable
+	} else if err != nil {
+		return entry, zone, err
+	}
+
+	if !entry.Serviceable {
+		return entry, zone, ErrNotServiceable
+	}
+
+	err = zoneCollection.FindOne(ctx, bson.M{"code": entry.ZoneCode}).Decode(&zone)
+	if err == mongo.ErrNoDocuments {
+		// A PIN code pointing at a deleted zone cannot be priced
+		return entry, zone, ErrNotServiceable
+	}
+	return entry, zone, err
+}
+
+// ParcelWeight adds up the weight of the cart lines, using the configured
+// default for products without a weight.
+func ParcelWeight(cart []models.CartItem) int {
+	defaultWeight := configs.EnvShippingDefaultWeightGrams()
+	weight := 0
+	for _, cartItem := range cart {
+		itemWeight := cartItem.Product.WeightGrams
+		if itemWeight <= 0 {
+			itemWeight = defaultWeight
+		}
+		weight += itemWeight * cartItem.Quantity
+	}
+	return weight
+}
+
+// charge prices a parcel in the zone, in the base currency.
+func charge(zone models.ShippingZone, weightGrams int) models.Money {
+	amount := currencyService.BaseMoney(zone.BaseRate)
+	if extra := weightGrams - zone.BaseWeightGrams; extra > 0 {
+		kilograms := int64((extra + 999) / 1000)
+		amount.Amount += kilograms * currencyService.BaseMoney(zone.AdditionalRatePerKg).Amount
+	}
+	return amount
+}
+
+// addWorkingDays moves from by days, skipping Sundays when carriers do not
+// deliver.
+func addWorkingDays(from time.Time, days int) time.Time {
+	date := from
+	for days > 0 {
+		date = date.AddDate(0, 0, 1)
+		if date.Weekday() != time.Sunday {
+			days--
+		}
+	}
+	return date
+}
+
+// Quote prices delivery of a parcel to the PIN code in the base currency.
+// itemTotal is the value of the goods after discounts, in the base currency,
+// and decides free shipping. An unserviceable PIN code returns a quote with
+// Serviceable false together with ErrNotServiceable.
+func Quote(ctx context.Context, pinCode string, weightGrams int, itemTotal models.Money) (models.ShippingQuote, error) {
+	quote := models.ShippingQuote{
+		PinCode:     NormalizePinCode(pinCode),
+		WeightGrams: weightGrams,
+		Charge:      currencyService.BaseMoney(0),
+	}
+
+	entry, zone, err := FindZone(ctx, pinCode)
+	if err != nil {
+		return quote, err
+	}
+
+	quote.ZoneCode = zone.Code
+	quote.Serviceable = true
+	quote.CODAvailable = entry.CODAvailable
+	if zone.FreeShippingThreshold > 0 {
+		threshold := currencyService.BaseMoney(zone.FreeShippingThreshold)
+		quote.FreeShippingThreshold = &threshold
+	}
+	if quote.FreeShippingThreshold != nil && itemTotal.Amount >= quote.FreeShippingThreshold.Amount {
+		quote.FreeShipping = true
+	} else {
+		quote.Charge = charge(zone, weightGrams)
+	}
+
+	dispatch := addWorkingDays(time.Now(), configs.EnvShippingProcessingDays())
+	quote.EstimatedDeliveryFrom = addWorkingDays(dispatch, zone.MinDays)
+	quote.EstimatedDeliveryTo = addWorkingDays(dispatch, zone.MaxDays)
+
+	return quote, nil
+}
@@ -0,0 +1,45 @@
+package sizes
+
+import (
+	"errors"
+	"strings"
+)
+
+// Labels are the sizes products are sold in, smallest first, as the cart
+// stores them.
+var Labels = []string{"S", "M", "L", "XL", "XXL", "XXXL"}
+
+// regionFirst is the numeric size of the smallest label in each sizing
+// region. The larger labels follow one number apart.
+var regionFirst = map[string]int{"EU": 38, "US": 5, "UK": 4}
+
+var ErrInvalid = errors.New("size must be one of " + List())
+
+// List writes the labels for messages, as "S, M, L, XL, XXL or XXXL".
+func List() string {
+	return strings.Join(Labels[:len(Labels)-1], ", ") + " or " + Labels[len(Labels)-1]
+}
+
+// Normalize checks a size label, which may be empty for any size.
+func Normalize(size string) (string, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	if size == "" {
+		return size, nil
+	}
+	for _, label := range Labels {
+		if size == label {
+			return size, nil
+		}
+	}
+	return "", ErrInvalid
+}
+
+// FromRegion returns the label for a numeric size in a sizing region, such
+// as 40 in EU for L.
+func FromRegion(region string, size int) (string, bool) {
+	first, ok := regionFirst[region]
+	if !ok || size < first || size-first >= len(Labels) {
+		return "", false
+	}
+	return Labels[size-first], true
+}
@@ -0,0 +1,53 @@
+package sizes
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		size string
+		want string
+		err  error
+	}{
+		{"", "", nil},
+		{" xl ", "XL", nil},
+		{"XXXL", "XXXL", nil},
+		{"XS", "", ErrInvalid},
+		{"40", "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.size)
+		if got != tt.want || err != tt.err {
+			t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.size, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFromRegion(t *testing.T) {
+	tests := []struct {
+		region string
+		size   int
+		want   string
+		ok     bool
+	}{
+		{"EU", 38, "S", true},
+		{"EU", 43, "XXXL", true},
+		{"EU", 44, "", false},
+		{"US", 7, "L", true},
+		{"US", 4, "", false},
+		{"UK", 4, "S", true},
+		{"UK", 9, "XXXL", true},
+		{"IN", 40, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FromRegion(tt.region, tt.size)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FromRegion(%q, %d) = %q, %v, want %q, %v", tt.region, tt.size, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	if got, want := List(), "S, M, L, XL, XXL or XXXL"; got != want {
+		t.Errorf("List() = %q, want %q", got, want)
+	}
+}
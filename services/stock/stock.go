@@ -0,0 +1,250 @@
+package stockService
+
+import (
+	"context"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	notificationService "fiber-mongo-api/services/notifications"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var subscriptionCollection *mongo.Collection = configs.GetCollection(configs.DB, "stockSubscriptions")
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+
+// notifyTimeout bounds notifying the subscribers of one restock outside a
+// request.
+const notifyTimeout = time.Minute
+
+var (
+	ErrProductNotFound      = errors.New("product not found")
+	ErrInStock              = errors.New("product is in stock")
+	ErrSubscriptionNotFound = errors.New("not subscribed to this product")
+	ErrInvalidQuantity      = errors.New("quantity must not be negative")
+)
+
+// Entry is a subscription with the product as it is now. Product is nil
+// once the product has been removed from the catalogue.
+type Entry struct {
+	models.StockSubscription
+	Product *models.Product `json:"product"`
+}
+
+// EnsureIndexes allows one subscription per user and product and indexes
+// the waiting subscriptions of a product. Subscriptions used to be per size,
+// and older ones still carry it in the index; new ones leave it out. It is
+// safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := subscriptionCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "productId", Value: 1}, {Key: "size", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "productId", Value: 1}, {Key: "notifiedAt", Value: 1}}},
+	})
+	return err
+}
+
+// Subscribe asks for a notification when the product is back in stock.
+// Stock is kept per product, not per size, so so are subscriptions. Only out
+// of stock products can be subscribed to; subscribing again after a
+// notification starts a new wait.
+func Subscribe(ctx context.Context, userID, productID primitive.ObjectID) (models.StockSubscription, error) {
+	var subscription models.StockSubscription
+	var product models.Product
+	if err := productCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&product); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return subscription, ErrProductNotFound
+		}
+		return subscription, err
+	}
+	if product.Quantity > 0 {
+		return subscription, ErrInStock
+	}
+
+	err := subscriptionCollection.FindOneAndUpdate(ctx,
+		bson.M{"userId": userID, "productId": productID},
+		bson.M{
+			"$set":         bson.M{"createdAt": time.Now()},
+			"$unset":       bson.M{"notifiedAt": ""},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&subscription)
+	return subscription, err
+}
+
+// Unsubscribe removes the user's subscription to the product, including any
+// made per size before subscriptions were per product.
+func Unsubscribe(ctx context.Context, userID, productID primitive.ObjectID) error {
+	result, err := subscriptionCollection.DeleteMany(ctx, bson.M{"userId": userID, "productId": productID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// List returns the user's subscriptions, newest first, with their products.
+func List(ctx context.Context, userID primitive.ObjectID) ([]Entry, error) {
+	cursor, err := subscriptionCollection.Find(ctx, bson.M{"userId": userID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []models.StockSubscription
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]primitive.ObjectID, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		productIDs = append(productIDs, subscription.ProductID)
+	}
+	cursor, err = productCollection.Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]*models.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+
+	entries := make([]Entry, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		entries = append(entries, Entry{StockSubscription: subscription, Product: byID[subscription.ProductID]})
+	}
+	return entries, nil
+}
+
+// SetStock sets the product's quantity on hand. When that brings an out of
+// stock product back, its subscribers are notified in the background; the
+// restock job catches any the notification misses.
+func SetStock(ctx context.Context, productID primitive.ObjectID, quantity int) (models.Product, error) {
+	var product models.Product
+	if quantity < 0 {
+		return product, ErrInvalidQuantity
+	}
+
+	err := productCollection.FindOneAndUpdate(ctx, bson.M{"_id": productID},
+		bson.M{"$set": bson.M{"quantity": quantity}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return product, ErrProductNotFound
+	}
+	if err != nil {
+		return product, err
+	}
+
+	restocked := product.Quantity <= 0 && quantity > 0
+	product.Quantity = quantity
+	if restocked {
+		Restocked(product.ID)
+	}
+	return product, nil
+}
+
+// Restocked notifies the subscribers of a product that came back in stock,
+// in the background.
+func Restocked(productID primitive.ObjectID) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if _, err := NotifyRestock(ctx, productID); err != nil {
+			log.Printf("stock: notifying restock of product %s: %v", productID.Hex(), err)
+		}
+	}()
+}
+
+// NotifyRestock notifies every waiting subscriber of the product if it is
+// in stock, and returns how many were notified. Notifications share their
+// key with the wishlist's back-in-stock alert for the day, so a user who
+// both wishlisted and subscribed is told once and a retry repeats none.
+func NotifyRestock(ctx context.Context, productID primitive.ObjectID) (int, error) {
+	var product models.Product
+	if err := productCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&product); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if product.Quantity <= 0 {
+		return 0, nil
+	}
+
+	cursor, err := subscriptionCollection.Find(ctx, bson.M{"productId": productID, "notifiedAt": bson.M{"$exists": false}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	notified := 0
+	for cursor.Next(ctx) {
+		var subscription models.StockSubscription
+		if err := cursor.Decode(&subscription); err != nil {
+			return notified, err
+		}
+
+		err := notificationService.Notify(ctx, models.Notification{
+			UserID:    subscription.UserID,
+			Type:      models.NotificationBackInStock,
+			Title:     "Back in stock",
+			Body:      product.Name + " is back in stock.",
+			ProductID: &productID,
+			Key:       "back_in_stock:" + productID.Hex() + ":" + time.Now().Format("2006-01-02"),
+		})
+		if err != nil {
+			return notified, err
+		}
+
+		now := time.Now()
+		if _, err := subscriptionCollection.UpdateOne(ctx,
+			bson.M{"_id": subscription.ID, "createdAt": subscription.CreatedAt},
+			bson.M{"$set": bson.M{"notifiedAt": now}}); err != nil {
+			return notified, err
+		}
+		notified++
+	}
+	return notified, cursor.Err()
+}
+
+// RestockJob returns the background job that notifies subscribers of
+// products that are in stock again but were not notified, for example
+// because stock came back through an order being cancelled.
+func RestockJob() func(ctx context.Context) (map[string]int, error) {
+	return func(ctx context.Context) (map[string]int, error) {
+		results := map[string]int{}
+		productIDs, err := subscriptionCollection.Distinct(ctx, "productId", bson.M{"notifiedAt": bson.M{"$exists": false}})
+		if err != nil {
+			return results, err
+		}
+
+		for _, value := range productIDs {
+			productID, ok := value.(primitive.ObjectID)
+			if !ok {
+				continue
+			}
+			results["checked"]++
+			notified, err := NotifyRestock(ctx, productID)
+			results["notified"] += notified
+			if err != nil {
+				return results, err
+			}
+		}
+		return results, nil
+	}
+}
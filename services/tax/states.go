@@ -0,0 +1,78 @@
+package taxService
+
+import "strings"
+
+// gstStateCodes maps Indian states and union territories to their GST state
+// codes. Addresses are free text, so common short forms are listed as well.
+var gstStateCodes = map[string]string{
+	"jammu and kashmir": "01",
+	"himachal pradesh":  "02",
+	"punjab":            "03",
+	"chandigarh":        "04",
+	"uttarakhand":       "05",
+	"haryana":           "06",
+	"delhi":             "07",
+	"new delhi":         "07",
+	"rajasthan":         "08",
+	"uttar pradesh":     "09",
+	"up":                "09",
+	"bihar":             "10",
+	"sikkim":            "11",
+	"arunachal pradesh": "12",
+	"nagaland":          "13",
+	"manipur":           "14",
+	"mizoram":           "15",
+	"tripura":           "16",
+	"meghalaya":         "17",
+	"assam":             "18",
+	"west bengal":       "19",
+	"jharkhand":         "20",
+	"odisha":            "21",
+	"orissa":            "21",
+	"chhattisgarh":      "22",
+	"madhya pradesh":    "23",
+	"mp":                "23",
+	"gujarat":           "24",
+	"dadra and nagar haveli and daman and diu": "26",
+	"daman and diu":               "26",
+	"dadra and nagar haveli":      "26",
+	"maharashtra":                 "27",
+	"karnataka":                   "29",
+	"goa":                         "30",
+	"lakshadweep":                 "31",
+	"kerala":                      "32",
+	"tamil nadu":                  "33",
+	"tn":                          "33",
+	"puducherry":                  "34",
+	"pondicherry":                 "34",
+	"andaman and nicobar islands": "35",
+	"telangana":                   "36",
+	"andhra pradesh":              "37",
+	"ap":                          "37",
+	"ladakh":                      "38",
+}
+
+// normalizeState lower-cases a state name, collapses whitespace and spells
+// out "&" so "Jammu & Kashmir" and "jammu and  kashmir" compare equal.
+func normalizeState(state string) string {
+	state = strings.ToLower(strings.ReplaceAll(state, "&", " and "))
+	return strings.Join(strings.Fields(state), " ")
+}
+
+// StateCode returns the GST state code for a state name, or false when the
+// name is not recognised.
+func StateCode(state string) (string, bool) {
+	code, ok := gstStateCodes[normalizeState(state)]
+	return code, ok
+}
+
+// SameState reports whether two state names refer to the same state. Names
+// that are not in the GST table are compared as normalised text.
+func SameState(a, b string) bool {
+	codeA, okA := StateCode(a)
+	codeB, okB := StateCode(b)
+	if okA && okB {
+		return codeA == codeB
+	}
+	return normalizeState(a) == normalizeState(b)
+}
@@ -0,0 +1,175 @@
+package taxService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	currencyService "fiber-mongo-api/services/currency"
+	"fiber-mongo-api/services/pricing"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var taxRateCollection *mongo.Collection = configs.GetCollection(configs.DB, "taxRates")
+
+// TaxableLine is a cart or order line as the tax engine sees it. UnitPrice
+// is the base currency price, which picks the price slab; TaxableValue is
+// the line value after discounts, in minor units of the currency taxed.
+type TaxableLine struct {
+	HSNCode      string
+	Category     string
+	UnitPrice    models.Money
+	TaxableValue int64
+}
+
+// EnsureIndexes makes HSN codes and categories unique among tax rates. It is
+// safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := taxRateCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "hsnCode", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"hsnCode": bson.M{"$exists": true},
+			}),
+		},
+		{
+			Keys: bson.D{{Key: "category", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"category": bson.M{"$exists": true},
+			}),
+		},
+	})
+	return err
+}
+
+// rateTable resolves GST rates by HSN code first, then by category.
+type rateTable struct {
+	byHSN      map[string]models.TaxRate
+	byCategory map[string]models.TaxRate
+}
+
+func loadRates(ctx context.Context) (rateTable, error) {
+	table := rateTable{
+		byHSN:      make(map[string]models.TaxRate),
+		byCategory: make(map[string]models.TaxRate),
+	}
+
+	cursor, err := taxRateCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return table, err
+	}
+	var rates []models.TaxRate
+	if err := cursor.All(ctx, &rates); err != nil {
+		return table, err
+	}
+
+	for _, rate := range rates {
+		if rate.HSNCode != "" {
+			table.byHSN[rate.HSNCode] = rate
+		} else if rate.Category != "" {
+			table.byCategory[strings.ToLower(rate.Category)] = rate
+		}
+	}
+	return table, nil
+}
+
+// rateFor returns the GST percentage for a line and the HSN code to print
+// for it.
+func (t rateTable) rateFor(line TaxableLine) (float64, string) {
+	rate, found := t.byHSN[line.HSNCode]
+	if !found {
+		rate, found = t.byCategory[strings.ToLower(line.Category)]
+	}
+	if !found {
+		return configs.EnvGSTDefaultRate(), line.HSNCode
+	}
+
+	hsnCode := line.HSNCode
+	if hsnCode == "" {
+		hsnCode = rate.HSNCode
+	}
+	if rate.ThresholdPrice > 0 && line.UnitPrice.Amount > currencyService.BaseMoney(rate.ThresholdPrice).Amount {
+		return rate.RateAboveThreshold, hsnCode
+	}
+	return rate.Rate, hsnCode
+}
+
+// Compute works out GST for the lines shipped to destinationState. Within
+// the configured origin state each rate is split evenly into CGST and SGST;
+// anywhere else it is charged as IGST. Without a destination, for example
+// before a guest has entered an address, the tax is estimated as intra-state
+// and flagged as estimated; the total is the same either way. Amounts are
+// in currency.
+func Compute(ctx context.Context, lines []TaxableLine, destinationState, currency string) (models.TaxBreakdown, []models.ItemTax, error) {
+	origin := configs.EnvGSTOriginState()
+	money := func(amount int64) models.Money {
+		return models.Money{Amount: amount, Currency: currency}
+	}
+	breakdown := models.TaxBreakdown{
+		OriginState:      origin,
+		DestinationState: destinationState,
+		TaxableValue:     money(0),
+		Lines:            []models.TaxLine{},
+		Total:            money(0),
+	}
+	if destinationState == "" {
+		breakdown.Estimated = true
+	} else {
+		breakdown.InterState = origin == "" || !SameState(origin, destinationState)
+	}
+
+	itemTaxes := make([]models.ItemTax, len(lines))
+	if len(lines) == 0 {
+		return breakdown, itemTaxes, nil
+	}
+
+	table, err := loadRates(ctx)
+	if err != nil {
+		return breakdown, nil, err
+	}
+
+	// Tax is summed per rate so the breakdown shows one line per component
+	// and rate, the way it is printed on an invoice
+	var rates []float64
+	taxByRate := make(map[float64]int64)
+	for i, line := range lines {
+		rate, hsnCode := table.rateFor(line)
+		amount := pricing.Percent(line.TaxableValue, rate)
+
+		itemTaxes[i] = models.ItemTax{
+			HSNCode:      hsnCode,
+			Rate:         rate,
+			TaxableValue: money(line.TaxableValue),
+			Amount:       money(amount),
+		}
+		breakdown.TaxableValue.Amount += line.TaxableValue
+
+		if _, seen := taxByRate[rate]; !seen {
+			rates = append(rates, rate)
+		}
+		taxByRate[rate] += amount
+	}
+
+	for _, rate := range rates {
+		amount := taxByRate[rate]
+		if rate == 0 {
+			continue
+		}
+		if breakdown.InterState {
+			breakdown.Lines = append(breakdown.Lines, models.TaxLine{Type: models.TaxIGST, Rate: rate, Amount: money(amount)})
+		} else {
+			// Split so the two halves always add back up to the full amount
+			cgst, sgst := pricing.SplitHalves(amount)
+			breakdown.Lines = append(breakdown.Lines,
+				models.TaxLine{Type: models.TaxCGST, Rate: rate / 2, Amount: money(cgst)},
+				models.TaxLine{Type: models.TaxSGST, Rate: rate / 2, Amount: money(sgst)},
+			)
+		}
+		breakdown.Total.Amount += amount
+	}
+
+	return breakdown, itemTaxes, nil
+}
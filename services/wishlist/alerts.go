@@ -0,0 +1,121 @@
+package wishlistService
+
+import (
+	"context"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	notificationService "fiber-mongo-api/services/notifications"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// itemName is the product as a notification names it.
+func itemName(product models.Product, size string) string {
+	if size == "" {
+		return product.Name
+	}
+	return product.Name + " (size " + size + ")"
+}
+
+// alert compares a wishlist item with its product now and notifies the
+// user of a lower price or of the product coming back in stock. It returns
+// the item as the user now knows it.
+func alert(ctx context.Context, userID primitive.ObjectID, item models.WishlistItem, product models.Product, results map[string]int) (models.WishlistItem, error) {
+	productID := product.ID
+	inStock := product.Quantity > 0
+
+	if product.Price < item.Price {
+		err := notificationService.Notify(ctx, models.Notification{
+			UserID:    userID,
+			Type:      models.NotificationPriceDrop,
+			Title:     "Price drop on your wishlist",
+			Body:      fmt.Sprintf("%s is now %s %.2f, down from %.2f.", itemName(product, item.Size), configs.EnvBaseCurrency(), product.Price, item.Price),
+			ProductID: &productID,
+			Size:      item.Size,
+			Key: "price_drop:" + productID.Hex() + ":" + item.Size + ":" +
+				strconv.FormatFloat(product.Price, 'f', 2, 64) + ":" + time.Now().Format("2006-01-02"),
+		})
+		if err != nil {
+			return item, err
+		}
+		item.Price = product.Price
+		results["priceDrops"]++
+	} else if product.Price > item.Price {
+		// A rise is what the user now sees, so a later drop is news again
+		item.Price = product.Price
+	}
+
+	if inStock && !item.InStock {
+		err := notificationService.Notify(ctx, models.Notification{
+			UserID:    userID,
+			Type:      models.NotificationBackInStock,
+			Title:     "Back in stock",
+			Body:      itemName(product, item.Size) + " from your wishlist is back in stock.",
+			ProductID: &productID,
+			Size:      item.Size,
+			Key:       "back_in_stock:" + productID.Hex() + ":" + time.Now().Format("2006-01-02"),
+		})
+		if err != nil {
+			return item, err
+		}
+		results["backInStock"]++
+	}
+	item.InStock = inStock
+	return item, nil
+}
+
+// AlertJob checks every wishlisted item against the catalogue for the job
+// runner and notifies users when one drops below the price they last saw
+// or comes back in stock. Notifications are sent before the item is
+// updated and are keyed by the event and its day, so a run that fails part
+// way neither loses nor repeats one, while a product that rises and drops
+// to the same price again on a later day is notified again.
+func AlertJob() func(ctx context.Context) (map[string]int, error) {
+	return func(ctx context.Context) (map[string]int, error) {
+		results := map[string]int{}
+		cursor, err := wishlistCollection.Find(ctx, bson.M{"items.0": bson.M{"$exists": true}})
+		if err != nil {
+			return results, err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var wishlist models.Wishlist
+			if err := cursor.Decode(&wishlist); err != nil {
+				return results, err
+			}
+			entries, err := Entries(ctx, wishlist)
+			if err != nil {
+				return results, err
+			}
+
+			for _, entry := range entries {
+				if entry.Product == nil {
+					continue
+				}
+				results["items"]++
+				item, err := alert(ctx, wishlist.UserID, entry.WishlistItem, *entry.Product, results)
+				if err != nil {
+					return results, err
+				}
+				if item == entry.WishlistItem {
+					continue
+				}
+				_, err = wishlistCollection.UpdateOne(ctx, bson.M{"_id": wishlist.ID},
+					bson.M{"$set": bson.M{"items.$[i].price": item.Price, "items.$[i].inStock": item.InStock}},
+					options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+						bson.M{"i.productId": item.ProductID, "i.size": item.Size},
+					}}))
+				if err != nil {
+					return results, err
+				}
+			}
+		}
+		return results, cursor.Err()
+	}
+}
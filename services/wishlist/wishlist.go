@@ -0,0 +1,261 @@
+package wishlistService
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fiber-mongo-api/configs"
+	"fiber-mongo-api/models"
+	"fiber-mongo-api/services/sizes"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var wishlistCollection *mongo.Collection = configs.GetCollection(configs.DB, "wishlists")
+var productCollection *mongo.Collection = configs.GetCollection(configs.DB, "products")
+
+// MaxItems caps the products on one wishlist.
+const MaxItems = 200
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrItemNotFound    = errors.New("item is not on the wishlist")
+	ErrWishlistFull    = errors.New("wishlist is full")
+	ErrShareNotFound   = errors.New("shared wishlist not found")
+)
+
+// Entry is a wishlist item with the product as it is now. Product is nil
+// once the product has been removed from the catalogue.
+type Entry struct {
+	models.WishlistItem
+	Product      *models.Product `json:"product"`
+	Available    bool            `json:"available"`
+	PriceDropped bool            `json:"priceDropped"`
+}
+
+// EnsureIndexes gives every user one wishlist and indexes share links. It
+// is safe to call on every start.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := wishlistCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys: bson.D{{Key: "shareToken", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"shareToken": bson.M{"$type": "string"}}),
+		},
+	})
+	return err
+}
+
+// ensure creates the user's wishlist if they have none yet.
+func ensure(ctx context.Context, userID primitive.ObjectID) error {
+	now := time.Now()
+	_, err := wishlistCollection.UpdateOne(ctx, bson.M{"userId": userID},
+		bson.M{"$setOnInsert": bson.M{
+			"_id":       primitive.NewObjectID(),
+			"userId":    userID,
+			"items":     []models.WishlistItem{},
+			"createdAt": now,
+			"updatedAt": now,
+		}},
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Created by a concurrent request
+		return nil
+	}
+	return err
+}
+
+// Get returns the user's wishlist, empty if they have not saved anything.
+func Get(ctx context.Context, userID primitive.ObjectID) (models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := wishlistCollection.FindOne(ctx, bson.M{"userId": userID}).Decode(&wishlist)
+	if err == mongo.ErrNoDocuments {
+		return models.Wishlist{UserID: userID, Items: []models.WishlistItem{}}, nil
+	}
+	if wishlist.Items == nil {
+		wishlist.Items = []models.WishlistItem{}
+	}
+	return wishlist, err
+}
+
+// Find returns the item for the product and size on the user's wishlist.
+func Find(ctx context.Context, userID, productID primitive.ObjectID, size string) (models.WishlistItem, error) {
+	wishlist, err := Get(ctx, userID)
+	if err != nil {
+		return models.WishlistItem{}, err
+	}
+	for _, item := range wishlist.Items {
+		if item.ProductID == productID && item.Size == size {
+			return item, nil
+		}
+	}
+	return models.WishlistItem{}, ErrItemNotFound
+}
+
+// Add saves the product in size to the user's wishlist. Saving an item
+// that is already there leaves it as it was.
+func Add(ctx context.Context, userID, productID primitive.ObjectID, size string) (models.Wishlist, error) {
+	size, err := sizes.Normalize(size)
+	if err != nil {
+		return models.Wishlist{}, err
+	}
+
+	var product models.Product
+	if err := productCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&product); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Wishlist{}, ErrProductNotFound
+		}
+		return models.Wishlist{}, err
+	}
+
+	if err := ensure(ctx, userID); err != nil {
+		return models.Wishlist{}, err
+	}
+	now := time.Now()
+	item := models.WishlistItem{
+		ProductID: productID,
+		Size:      size,
+		Price:     product.Price,
+		InStock:   product.Quantity > 0,
+		AddedAt:   now,
+	}
+	result, err := wishlistCollection.UpdateOne(ctx,
+		bson.M{
+			"userId":                            userID,
+			"items":                             bson.M{"$not": bson.M{"$elemMatch": bson.M{"productId": productID, "size": size}}},
+			"items." + strconv.Itoa(MaxItems-1): bson.M{"$exists": false},
+		},
+		bson.M{
+			"$push": bson.M{"items": item},
+			"$set":  bson.M{"updatedAt": now},
+		})
+	if err != nil {
+		return models.Wishlist{}, err
+	}
+
+	wishlist, err := Get(ctx, userID)
+	if err != nil || result.MatchedCount > 0 {
+		return wishlist, err
+	}
+	for _, saved := range wishlist.Items {
+		if saved.ProductID == productID && saved.Size == size {
+			return wishlist, nil
+		}
+	}
+	return wishlist, ErrWishlistFull
+}
+
+// Remove takes the product in size off the user's wishlist.
+func Remove(ctx context.Context, userID, productID primitive.ObjectID, size string) error {
+	size, err := sizes.Normalize(size)
+	if err != nil {
+		return err
+	}
+	result, err := wishlistCollection.UpdateOne(ctx,
+		bson.M{"userId": userID, "items": bson.M{"$elemMatch": bson.M{"productId": productID, "size": size}}},
+		bson.M{
+			"$pull": bson.M{"items": bson.M{"productId": productID, "size": size}},
+			"$set":  bson.M{"updatedAt": time.Now()},
+		})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrItemNotFound
+	}
+	return nil
+}
+
+// Entries pairs the wishlist's items with their products, newest first.
+func Entries(ctx context.Context, wishlist models.Wishlist) ([]Entry, error) {
+	entries := make([]Entry, 0, len(wishlist.Items))
+	if len(wishlist.Items) == 0 {
+		return entries, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(wishlist.Items))
+	for _, item := range wishlist.Items {
+		ids = append(ids, item.ProductID)
+	}
+	var products []models.Product
+	cursor, err := productCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	for i := len(wishlist.Items) - 1; i >= 0; i-- {
+		entry := Entry{WishlistItem: wishlist.Items[i]}
+		if product, ok := byID[entry.ProductID]; ok {
+			entry.Product = &product
+			entry.Available = product.Quantity > 0
+			entry.PriceDropped = product.Price < entry.Price
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// newShareToken returns a random, URL-safe token for a share link.
+func newShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Share returns the token of the user's share link, creating the link if
+// the wishlist has none.
+func Share(ctx context.Context, userID primitive.ObjectID) (string, error) {
+	if err := ensure(ctx, userID); err != nil {
+		return "", err
+	}
+	token, err := newShareToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = wishlistCollection.UpdateOne(ctx,
+		bson.M{"userId": userID, "shareToken": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"shareToken": token}})
+	if err != nil {
+		return "", err
+	}
+
+	// Another request may have shared it first
+	wishlist, err := Get(ctx, userID)
+	return wishlist.ShareToken, err
+}
+
+// Unshare turns off the user's share link. Sharing again makes a new one.
+func Unshare(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := wishlistCollection.UpdateOne(ctx, bson.M{"userId": userID},
+		bson.M{"$unset": bson.M{"shareToken": ""}})
+	return err
+}
+
+// Shared returns the wishlist a share link points to.
+func Shared(ctx context.Context, token string) (models.Wishlist, error) {
+	var wishlist models.Wishlist
+	if token == "" {
+		return wishlist, ErrShareNotFound
+	}
+	err := wishlistCollection.FindOne(ctx, bson.M{"shareToken": token}).Decode(&wishlist)
+	if err == mongo.ErrNoDocuments {
+		return wishlist, ErrShareNotFound
+	}
+	return wishlist, err
+}